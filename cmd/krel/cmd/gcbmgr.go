@@ -206,15 +206,17 @@ func runGcbmgr() error {
 		gcbSubs["NOMOCK_TAG"] = ""
 		gcbSubs["NOMOCK"] = ""
 
-		bucketPrefix := release.BucketPrefix
-
-		userBucket := fmt.Sprintf("%s%s", bucketPrefix, gcbSubs["GCP_USER_TAG"])
+		// An empty suffix would select the bucket of official releases
+		if gcbSubs["GCP_USER_TAG"] == "" {
+			return errors.New("GCP user tag for the user bucket is empty")
+		}
+		userBucket := release.GetReleaseBucket(gcbSubs["GCP_USER_TAG"])
 		userBucketSetErr := os.Setenv("USER_BUCKET", userBucket)
 		if userBucketSetErr != nil {
 			return userBucketSetErr
 		}
 
-		testBucket := fmt.Sprintf("%s%s", bucketPrefix, "gcb")
+		testBucket := release.GetReleaseBucket("gcb")
 		testBucketSetErr := os.Setenv("BUCKET", testBucket)
 		if testBucketSetErr != nil {
 			return testBucketSetErr
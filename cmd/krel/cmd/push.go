@@ -75,68 +75,6 @@ var pushBuildCmd = &cobra.Command{
 	},
 }
 
-type stageFile struct {
-	srcPath  string
-	dstPath  string
-	required bool
-}
-
-var gcpStageFiles = []stageFile{
-	{
-		srcPath:  filepath.Join(release.GCEPath, "configure-vm.sh"),
-		dstPath:  filepath.Join(release.GCSStagePath, "extra/gce"),
-		required: false,
-	},
-	{
-		srcPath:  filepath.Join(release.GCIPath, "node.yaml"),
-		dstPath:  filepath.Join(release.GCSStagePath, "extra/gce"),
-		required: true,
-	},
-	{
-		srcPath:  filepath.Join(release.GCIPath, "master.yaml"),
-		dstPath:  filepath.Join(release.GCSStagePath, "extra/gce"),
-		required: true,
-	},
-	{
-		srcPath:  filepath.Join(release.GCIPath, "configure.sh"),
-		dstPath:  filepath.Join(release.GCSStagePath, "extra/gce"),
-		required: true,
-	},
-	{
-		srcPath:  filepath.Join(release.GCIPath, "shutdown.sh"),
-		dstPath:  filepath.Join(release.GCSStagePath, "extra/gce"),
-		required: false,
-	},
-}
-
-var windowsStageFiles = []stageFile{
-	{
-		srcPath:  filepath.Join(release.WindowsLocalPath, "configure.ps1"),
-		dstPath:  release.WindowsGCSPath,
-		required: true,
-	},
-	{
-		srcPath:  filepath.Join(release.WindowsLocalPath, "common.psm1"),
-		dstPath:  release.WindowsGCSPath,
-		required: true,
-	},
-	{
-		srcPath:  filepath.Join(release.WindowsLocalPath, "k8s-node-setup.psm1"),
-		dstPath:  release.WindowsGCSPath,
-		required: true,
-	},
-	{
-		srcPath:  filepath.Join(release.WindowsLocalPath, "testonly/install-ssh.psm1"),
-		dstPath:  release.WindowsGCSPath,
-		required: true,
-	},
-	{
-		srcPath:  filepath.Join(release.WindowsLocalPath, "testonly/user-profile.psm1"),
-		dstPath:  release.WindowsGCSPath,
-		required: true,
-	},
-}
-
 func init() {
 	pushBuildCmd.PersistentFlags().BoolVar(
 		&pushBuildOpts.allowDup,
@@ -209,33 +147,14 @@ func init() {
 }
 
 func runPushBuild(opts *pushBuildOptions) error {
-	var latest string
-
-	// Check if latest build uses bazel
 	dir, err := os.Getwd()
 	if err != nil {
 		return errors.Wrap(err, "Unable to get working directory")
 	}
 
-	isBazel, err := release.BuiltWithBazel(dir)
+	latest, err := release.ReadVersion(dir)
 	if err != nil {
-		return errors.Wrap(err, "Unable to identify if release built with Bazel")
-	}
-
-	if isBazel {
-		logrus.Info("Using Bazel build version")
-		version, err := release.ReadBazelVersion(dir)
-		if err != nil {
-			return errors.Wrap(err, "Unable to read Bazel build version")
-		}
-		latest = version
-	} else {
-		logrus.Info("Using Dockerized build version")
-		version, err := release.ReadDockerizedVersion(dir)
-		if err != nil {
-			return errors.Wrap(err, "Unable to read Dockerized build version")
-		}
-		latest = version
+		return errors.Wrap(err, "Unable to read build version")
 	}
 
 	logrus.Infof("Found build version: %s", latest)
@@ -248,9 +167,9 @@ func runPushBuild(opts *pushBuildOptions) error {
 		return errors.Errorf("Build version %s is not valid for release", latest)
 	}
 
-	if opts.ci && release.IsDirtyBuild(latest) {
-		return errors.New(`Refusing to push dirty build with --ci flag given.\n
-			CI builds should always be performed from clean commits`)
+	if dirty, suffix := release.DirtyBuildReason(latest); opts.ci && dirty {
+		return errors.Errorf(`Refusing to push dirty build (suffix %q) with --ci flag given.\n
+			CI builds should always be performed from clean commits`, suffix)
 	}
 
 	if opts.versionSuffix != "" {
@@ -313,17 +232,13 @@ func runPushBuild(opts *pushBuildOptions) error {
 	}
 
 	// Copy helpful GCP scripts to local GCS staging directory for push
-	for _, file := range gcpStageFiles {
-		if err := util.CopyFileLocal(filepath.Join(buildDir, file.srcPath), filepath.Join(buildDir, file.dstPath), file.required); err != nil {
-			return err
-		}
+	if err := release.StageFiles(buildDir, release.GCEStageFiles); err != nil {
+		return errors.Wrap(err, "staging GCP scripts")
 	}
 
 	// Copy helpful Windows scripts to local GCS staging directory for push
-	for _, file := range windowsStageFiles {
-		if err := util.CopyFileLocal(filepath.Join(buildDir, file.srcPath), filepath.Join(buildDir, file.dstPath), file.required); err != nil {
-			return err
-		}
+	if err := release.StageFiles(buildDir, release.WindowsStageFiles); err != nil {
+		return errors.Wrap(err, "staging Windows scripts")
 	}
 
 	// TODO
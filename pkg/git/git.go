@@ -47,8 +47,9 @@ const (
 	DefaultRemote            = "origin"
 	DefaultMasterRef         = "HEAD"
 	Master                   = "master"
+	Main                     = "main"
 
-	branchRE              = `master|release-([0-9]{1,})\.([0-9]{1,})(\.([0-9]{1,}))*$`
+	branchRE              = `master|main|release-([0-9]{1,})\.([0-9]{1,})(\.([0-9]{1,}))*$`
 	defaultGithubAuthRoot = "git@github.com:"
 	gitExecutable         = "git"
 )
@@ -494,6 +495,12 @@ func (r *Repo) Checkout(rev string, args ...string) error {
 		RunSilentSuccess()
 }
 
+// IsDefaultBranch returns true if `branch` is the default branch of a
+// repository, which is either master or main.
+func IsDefaultBranch(branch string) bool {
+	return branch == Master || branch == Main
+}
+
 func IsReleaseBranch(branch string) bool {
 	re := regexp.MustCompile(branchRE)
 	if !re.MatchString(branch) {
@@ -570,6 +577,29 @@ func (r *Repo) DescribeTag(rev string) (string, error) {
 	return result.OutputTrimNL(), nil
 }
 
+// TagDate returns the creation date of the provided tag, which is the tagger
+// date for annotated tags and the committer date of the tagged commit for
+// lightweight tags.
+func (r *Repo) TagDate(tag string) (time.Time, error) {
+	result, err := command.NewWithWorkDir(
+		r.Dir(), gitExecutable, "for-each-ref", "--count=1",
+		"--format=%(creatordate:iso-strict)", "refs/tags/"+tag,
+	).RunSilentSuccessOutput()
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "retrieving date of tag %s", tag)
+	}
+
+	date := result.OutputTrimNL()
+	if date == "" {
+		return time.Time{}, errors.Errorf("tag %s does not exist", tag)
+	}
+	res, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "parsing date %s of tag %s", date, tag)
+	}
+	return res, nil
+}
+
 // Merge does a git merge into the current branch from the provided one
 func (r *Repo) Merge(from string) error {
 	return command.NewWithWorkDir(
@@ -238,6 +238,46 @@ func TestFailureDescribeTag(t *testing.T) {
 	require.NotNil(t, err)
 }
 
+func TestSuccessTagDate(t *testing.T) {
+	testRepo := newTestRepo(t)
+	defer testRepo.cleanup(t)
+
+	committed := time.Date(2020, time.May, 20, 10, 0, 0, 0, time.UTC)
+	tagged := committed.Add(36 * time.Hour)
+	defer os.Unsetenv("GIT_COMMITTER_DATE")
+	for _, step := range []struct {
+		date time.Time
+		args []string
+	}{
+		{committed, []string{"commit", "--allow-empty", "-m", "Dated commit"}},
+		{committed, []string{"tag", "v1.17.1"}},
+		{tagged, []string{"tag", "-a", "v1.17.2", "-m", "v1.17.2"}},
+	} {
+		require.Nil(t, os.Setenv("GIT_COMMITTER_DATE", step.date.Format(time.RFC3339)))
+		require.Nil(t, command.NewWithWorkDir(testRepo.sut.Dir(), "git", append([]string{
+			"-c", "user.name=John Doe", "-c", "user.email=john@doe.org",
+		}, step.args...)...).RunSilentSuccess())
+	}
+
+	// Lightweight tag
+	lightweight, err := testRepo.sut.TagDate("v1.17.1")
+	require.Nil(t, err)
+	require.Equal(t, committed, lightweight.UTC())
+
+	// Annotated tag
+	annotated, err := testRepo.sut.TagDate("v1.17.2")
+	require.Nil(t, err)
+	require.Equal(t, tagged, annotated.UTC())
+}
+
+func TestFailureTagDate(t *testing.T) {
+	testRepo := newTestRepo(t)
+	defer testRepo.cleanup(t)
+
+	_, err := testRepo.sut.TagDate("wrong")
+	require.NotNil(t, err)
+}
+
 func TestSuccessHasRemoteBranch(t *testing.T) {
 	testRepo := newTestRepo(t)
 	defer testRepo.cleanup(t)
@@ -363,6 +403,16 @@ func TestSuccessIsReleaseBranch(t *testing.T) {
 	require.True(t, git.IsReleaseBranch("release-1.17"))
 }
 
+func TestSuccessIsReleaseBranchMain(t *testing.T) {
+	require.True(t, git.IsReleaseBranch(git.Main))
+}
+
+func TestIsDefaultBranch(t *testing.T) {
+	require.True(t, git.IsDefaultBranch(git.Master))
+	require.True(t, git.IsDefaultBranch(git.Main))
+	require.False(t, git.IsDefaultBranch("release-1.17"))
+}
+
 func TestFailureIsReleaseBranch(t *testing.T) {
 	require.False(t, git.IsReleaseBranch("wrong-branch"))
 }
@@ -0,0 +1,446 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/util"
+)
+
+const (
+	// DefaultMirror is the default download location of release artifacts and
+	// version markers.
+	DefaultMirror = "https://dl.k8s.io"
+
+	// ReleaseDownloadURLBase is the base URL for published release artifacts.
+	ReleaseDownloadURLBase = DefaultMirror + "/release"
+
+	// CIDownloadURLBase is the base URL for the version markers of CI builds.
+	CIDownloadURLBase = DefaultMirror + "/ci"
+
+	kubernetesSrcTar       = "kubernetes-src.tar.gz"
+	kubernetesManifestsTar = "kubernetes-manifests.tar.gz"
+	signatureExtension     = ".asc"
+)
+
+var (
+	// DefaultMirrors are the locations GetKubeVersionFromMirrors and the
+	// version getters like GetStableReleaseKubeVersion fetch version markers
+	// from, in the order they are tried.
+	DefaultMirrors = []string{DefaultMirror}
+
+	// ChecksumExtensions are the checksum file extensions published next to
+	// every release artifact.
+	ChecksumExtensions = []string{".sha256", ".sha512"}
+
+	// platformComponents are the tarball components built for every linux
+	// architecture.
+	platformComponents = []string{"client", "server", "node"}
+
+	// downloadURLBase is the base URL used by ReleaseDownloadURL.
+	downloadURLBase = ReleaseDownloadURLBase
+
+	// ciURLBase is the base URL of the CI version markers.
+	ciURLBase = CIDownloadURLBase
+)
+
+// ExpectedArtifacts returns the names of the release tarballs a complete build
+// produces for the provided linux architectures, e.g. "amd64" or "arm64".
+func ExpectedArtifacts(arches []string) []string {
+	artifacts := []string{kubernetesTar, kubernetesSrcTar, kubernetesManifestsTar}
+	for _, arch := range arches {
+		for _, component := range platformComponents {
+			artifacts = append(artifacts,
+				fmt.Sprintf("kubernetes-%s-linux-%s.tar.gz", component, arch),
+			)
+		}
+	}
+	return artifacts
+}
+
+// ExpectedArtifactCount returns the number of release tarballs a complete
+// build produces for the provided linux architectures, which is the length of
+// ExpectedArtifacts. Comparing it to the result of ListReleaseArtifacts is a
+// cheap completeness check.
+func ExpectedArtifactCount(arches []string) int {
+	return len(ExpectedArtifacts(arches))
+}
+
+// ReleaseDownloadURL returns the public download URL of an artifact for the
+// provided release version.
+// Expected: https://dl.k8s.io/release/<version>/<artifact>
+func ReleaseDownloadURL(version, artifact string) (string, error) {
+	return artifactURL(downloadURLBase, version, artifact)
+}
+
+// artifactURL returns the URL of `artifact` of `version` below `base`.
+func artifactURL(base, version, artifact string) (string, error) {
+	valid, err := IsValidReleaseBuild(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "validating version %s", version)
+	}
+	if !valid {
+		return "", errors.Errorf("invalid release version: %s", version)
+	}
+	if artifact == "" {
+		return "", errors.New("artifact name must not be empty")
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse URL base")
+	}
+	u.Path = path.Join(u.Path, version, artifact)
+
+	return u.String(), nil
+}
+
+// ReleaseURLSet returns every downloadable URL of a release: the tarballs
+// from ExpectedArtifacts for the provided architectures together with their
+// checksum and signature files.
+func ReleaseURLSet(version string, arches []string) ([]string, error) {
+	return artifactURLSet(downloadURLBase, version, arches, true)
+}
+
+// artifactURLSet returns the URLs of the tarballs from ExpectedArtifacts of
+// `version` below `base` together with their checksum files and, if
+// `signed` is set, their signatures.
+func artifactURLSet(base, version string, arches []string, signed bool) ([]string, error) {
+	urls := []string{}
+	for _, artifact := range ExpectedArtifacts(arches) {
+		u, err := artifactURL(base, version, artifact)
+		if err != nil {
+			return nil, err
+		}
+
+		urls = append(urls, u)
+		for _, ext := range ChecksumExtensions {
+			urls = append(urls, u+ext)
+		}
+		if signed {
+			urls = append(urls, u+signatureExtension)
+		}
+	}
+	return urls, nil
+}
+
+// VerifyChannelDownloadable resolves the current version of `channel` like
+// ResolveChannelVersions and checks that all URLs of its ReleaseURLSet for
+// the supported linux architectures are available. The resolved version is
+// returned if that is the case. CI builds are not published to the release
+// download location and therefore rejected.
+func VerifyChannelDownloadable(channel ReleaseType) (string, error) {
+	if channel == ReleaseTypeCI {
+		return "", errors.Errorf("%s builds are not published for download", channel)
+	}
+
+	versions, err := ResolveChannelVersions(false, channel)
+	if err != nil {
+		return "", err
+	}
+	version := versions[channel]
+
+	arches, err := supportedLinuxArches(version)
+	if err != nil {
+		return "", err
+	}
+	urls, err := ReleaseURLSet(version, arches)
+	if err != nil {
+		return "", err
+	}
+
+	missing, err := missingURLs(urls)
+	if err != nil {
+		return "", err
+	}
+	if len(missing) > 0 {
+		return "", errors.Errorf(
+			"%s version %s is missing downloads: %s",
+			channel, version, strings.Join(missing, ", "),
+		)
+	}
+
+	logrus.Infof("All %d downloads of %s version %s are available", len(urls), channel, version)
+	return version, nil
+}
+
+// VerifyCIBuildConsistency resolves the CI version of `branch` like
+// GetCIKubeVersion and checks that the published artifacts of that build are
+// complete and that its kubernetes.tar.gz embeds the same version. This
+// catches markers which got updated before the upload of the artifacts
+// finished. CI builds are not signed, which means only the tarballs and their
+// checksums are checked.
+func VerifyCIBuildConsistency(branch string) error {
+	version, err := GetCIKubeVersion(branch, false)
+	if err != nil {
+		return errors.Wrapf(err, "resolving CI version of %s", branch)
+	}
+
+	arches, err := supportedLinuxArches(version)
+	if err != nil {
+		return err
+	}
+	urls, err := artifactURLSet(ciURLBase, version, arches, false)
+	if err != nil {
+		return err
+	}
+
+	missing, err := missingURLs(urls)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return errors.Errorf(
+			"CI build %s of %s is missing artifacts: %s",
+			version, branch, strings.Join(missing, ", "),
+		)
+	}
+
+	tarballURL, err := artifactURL(ciURLBase, version, kubernetesTar)
+	if err != nil {
+		return err
+	}
+	embedded, err := readVersionFromURL(tarballURL)
+	if err != nil {
+		return err
+	}
+	if util.TrimTagPrefix(embedded) != util.TrimTagPrefix(version) {
+		return errors.Errorf(
+			"CI marker of %s points to %s, but %s contains %s",
+			branch, version, tarballURL, embedded,
+		)
+	}
+
+	logrus.Infof("CI build %s of %s is consistent", version, branch)
+	return nil
+}
+
+// supportedLinuxArches returns the architectures of the linux platforms from
+// SupportedPlatforms.
+func supportedLinuxArches(version string) ([]string, error) {
+	platforms, err := SupportedPlatforms(version)
+	if err != nil {
+		return nil, err
+	}
+	arches := []string{}
+	for _, platform := range platforms {
+		if platform.OS == "linux" {
+			arches = append(arches, platform.Arch)
+		}
+	}
+	return arches, nil
+}
+
+// missingURLs returns all `urls` which do not exist according to urlExists,
+// checking up to MaxParallelism URLs at once.
+func missingURLs(urls []string) ([]string, error) {
+	exists := make([]bool, len(urls))
+	if err := runParallel(len(urls), func(i int) (err error) {
+		exists[i], err = urlExists(urls[i])
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for i, u := range urls {
+		if !exists[i] {
+			missing = append(missing, u)
+		}
+	}
+	return missing, nil
+}
+
+// readVersionFromURL streams the release tarball at `u` to read its embedded
+// version like ReadVersionFromTarReader.
+func readVersionFromURL(u string) (string, error) {
+	resp, err := defaultHTTPClient.Get(u)
+	if err != nil {
+		return "", errors.Wrapf(err, "an error occurred GET-ing %s", u)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, u); err != nil {
+		return "", err
+	}
+	version, err := ReadVersionFromTarReader(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading version from %s", u)
+	}
+	return version, nil
+}
+
+// urlExists returns true if a HEAD request on `u` succeeds and false if the
+// server responds with not found.
+func urlExists(u string) (bool, error) {
+	resp, err := defaultHTTPClient.Head(u)
+	if err != nil {
+		return false, errors.Wrapf(err, "an error occurred HEAD-ing %s", u)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err := checkStatus(resp, u); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListReleaseArtifacts returns the paths of all artifacts below the
+// ReleaseTarsPath of the build output directory `workDir`, relative to that
+// path. Checksum and signature files are not considered as artifacts.
+func ListReleaseArtifacts(workDir string) ([]string, error) {
+	releaseTars := filepath.Join(workDir, ReleaseTarsPath)
+
+	artifacts := []string{}
+	if err := filepath.Walk(releaseTars, func(
+		file string, info os.FileInfo, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || isArtifactMetadata(file) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(releaseTars, file)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, rel)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "listing artifacts in %s", releaseTars)
+	}
+
+	sort.Strings(artifacts)
+	return artifacts, nil
+}
+
+// DetectDuplicateArtifacts reports release artifacts which share the same
+// canonical name, for example `kubernetes.tgz` and `kubernetes.tar.gz`. Every
+// returned entry describes one set of duplicates and whether their checksums
+// conflict.
+func DetectDuplicateArtifacts(workDir string) ([]string, error) {
+	artifacts, err := ListReleaseArtifacts(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string][]string{}
+	names := []string{}
+	for _, artifact := range artifacts {
+		name := canonicalArtifactName(artifact)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], artifact)
+	}
+	sort.Strings(names)
+
+	duplicates := []string{}
+	for _, name := range names {
+		set := byName[name]
+		if len(set) < 2 {
+			continue
+		}
+
+		checksums := map[string]bool{}
+		for _, artifact := range set {
+			sha, err := util.SHA256ForFile(
+				filepath.Join(workDir, ReleaseTarsPath, artifact),
+			)
+			if err != nil {
+				return nil, err
+			}
+			checksums[sha] = true
+		}
+
+		kind := "duplicate"
+		if len(checksums) > 1 {
+			kind = "conflicting"
+		}
+		logrus.Warnf("Found %s artifacts for %s: %v", kind, name, set)
+		duplicates = append(duplicates,
+			fmt.Sprintf("%s (%s): %s", name, kind, strings.Join(set, ", ")),
+		)
+	}
+	return duplicates, nil
+}
+
+// canonicalArtifactName returns the file name of an artifact with the
+// tarball extension normalized.
+func canonicalArtifactName(artifact string) string {
+	name := filepath.Base(artifact)
+	if strings.HasSuffix(name, ".tgz") {
+		name = strings.TrimSuffix(name, ".tgz") + ".tar.gz"
+	}
+	return name
+}
+
+// isArtifactMetadata returns true if `file` is a checksum or signature file of
+// another artifact.
+func isArtifactMetadata(file string) bool {
+	if strings.HasSuffix(file, signatureExtension) {
+		return true
+	}
+	for _, ext := range ChecksumExtensions {
+		if strings.HasSuffix(file, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyNonEmptyArtifacts checks that none of the release artifacts in the
+// build output directory `workDir` is empty. The returned error lists all
+// zero-byte artifacts.
+func VerifyNonEmptyArtifacts(workDir string) error {
+	artifacts, err := ListReleaseArtifacts(workDir)
+	if err != nil {
+		return err
+	}
+
+	empty := []string{}
+	for _, artifact := range artifacts {
+		info, err := os.Stat(filepath.Join(workDir, ReleaseTarsPath, artifact))
+		if err != nil {
+			return errors.Wrapf(err, "checking size of %s", artifact)
+		}
+		if info.Size() == 0 {
+			empty = append(empty, artifact)
+		}
+	}
+
+	if len(empty) > 0 {
+		return errors.Errorf("found empty artifacts: %s", strings.Join(empty, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedArtifacts(t *testing.T) {
+	cases := map[string]struct {
+		arches []string
+		want   []string
+	}{
+		"NoArches": {
+			want: []string{
+				"kubernetes.tar.gz",
+				"kubernetes-src.tar.gz",
+				"kubernetes-manifests.tar.gz",
+			},
+		},
+		"MultipleArches": {
+			arches: []string{"amd64", "arm64"},
+			want: []string{
+				"kubernetes.tar.gz",
+				"kubernetes-src.tar.gz",
+				"kubernetes-manifests.tar.gz",
+				"kubernetes-client-linux-amd64.tar.gz",
+				"kubernetes-server-linux-amd64.tar.gz",
+				"kubernetes-node-linux-amd64.tar.gz",
+				"kubernetes-client-linux-arm64.tar.gz",
+				"kubernetes-server-linux-arm64.tar.gz",
+				"kubernetes-node-linux-arm64.tar.gz",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, ExpectedArtifacts(tc.arches))
+		})
+	}
+}
+
+func TestExpectedArtifactCount(t *testing.T) {
+	require.Equal(t, 3, ExpectedArtifactCount(nil))
+	require.Equal(t, 6, ExpectedArtifactCount([]string{"amd64"}))
+	require.Equal(t, 9, ExpectedArtifactCount([]string{"amd64", "arm64"}))
+}
+
+func TestReleaseDownloadURL(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version  string
+		artifact string
+		want     want
+	}{
+		"Valid": {
+			version:  "v1.18.3",
+			artifact: "kubernetes.tar.gz",
+			want: want{
+				r: "https://dl.k8s.io/release/v1.18.3/kubernetes.tar.gz",
+			},
+		},
+		"InvalidVersion": {
+			version:  "1.18.3",
+			artifact: "kubernetes.tar.gz",
+			want:     want{rErr: true},
+		},
+		"EmptyArtifact": {
+			version: "v1.18.3",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ReleaseDownloadURL(tc.version, tc.artifact)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestReleaseURLSet(t *testing.T) {
+	urls, err := ReleaseURLSet("v1.18.3", []string{"amd64"})
+	require.Nil(t, err)
+
+	// Every artifact comes with two checksums and a signature
+	require.Len(t, urls, len(ExpectedArtifacts([]string{"amd64"}))*4)
+	require.Contains(t, urls, "https://dl.k8s.io/release/v1.18.3/kubernetes.tar.gz")
+	require.Contains(t, urls, "https://dl.k8s.io/release/v1.18.3/kubernetes.tar.gz.sha256")
+	require.Contains(t, urls, "https://dl.k8s.io/release/v1.18.3/kubernetes.tar.gz.sha512")
+	require.Contains(t, urls, "https://dl.k8s.io/release/v1.18.3/kubernetes-server-linux-amd64.tar.gz.asc")
+
+	_, err = ReleaseURLSet("invalid", nil)
+	require.NotNil(t, err)
+}
+
+func TestVerifyChannelDownloadable(t *testing.T) {
+	getters := channelGetters
+	defer func() { channelGetters = getters }()
+	channelGetters = map[ReleaseType]func(bool) (string, error){
+		ReleaseTypeStable: func(bool) (string, error) { return "v1.18.3", nil },
+		ReleaseTypeLatest: func(bool) (string, error) { return "v1.19.0-rc.1", nil },
+	}
+
+	// Only the stable release is completely published
+	published, err := ReleaseURLSet("v1.18.3", []string{
+		"amd64", "386", "arm", "arm64", "ppc64le", "s390x",
+	})
+	require.Nil(t, err)
+	available := map[string]bool{}
+	for _, u := range published {
+		parsed, err := url.Parse(u)
+		require.Nil(t, err)
+		available[parsed.Path[len("/release"):]] = true
+	}
+	available["/v1.19.0-rc.1/kubernetes.tar.gz"] = true
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if !available[r.URL.Path] {
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+	defer func(base string) { downloadURLBase = base }(downloadURLBase)
+	downloadURLBase = server.URL
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		channel ReleaseType
+		want    want
+	}{
+		"Downloadable": {
+			channel: ReleaseTypeStable,
+			want:    want{r: "v1.18.3"},
+		},
+		"MissingDownloads": {
+			channel: ReleaseTypeLatest,
+			want:    want{rErr: true},
+		},
+		"CI": {
+			channel: ReleaseTypeCI,
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := VerifyChannelDownloadable(tc.channel)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestVerifyCIBuildConsistency(t *testing.T) {
+	const (
+		complete   = "v1.18.4-rc.0.12+f1a2b3c4d5e6f7"
+		incomplete = "v1.19.0-beta.1.58+e19c4a2b1ec777"
+		mismatch   = "v1.17.7-rc.0.1+a1b2c3d4e5f6a7"
+	)
+	arches := []string{"amd64", "386", "arm", "arm64", "ppc64le", "s390x"}
+
+	available := map[string][]byte{}
+	for _, version := range []string{complete, incomplete, mismatch} {
+		urls, err := artifactURLSet("", version, arches, false)
+		require.Nil(t, err)
+		for _, u := range urls {
+			available["/"+u] = []byte{}
+		}
+	}
+	delete(available, "/"+incomplete+"/kubernetes-node-linux-s390x.tar.gz")
+	available["/"+complete+"/kubernetes.tar.gz"] = testTarball(
+		t, map[string]string{dockerVersionPath: complete + "\n"}, true,
+	).Bytes()
+
+	// The build of the mismatch marker embeds another version
+	available["/"+mismatch+"/kubernetes.tar.gz"] = testTarball(
+		t, map[string]string{dockerVersionPath: "v1.17.6\n"}, true,
+	).Bytes()
+
+	markers := map[string]string{
+		"/latest-1.18.txt": complete,
+		"/latest-1.19.txt": incomplete,
+		"/latest-1.17.txt": mismatch,
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if marker, ok := markers[r.URL.Path]; ok {
+				w.Write([]byte(marker))
+				return
+			}
+			content, ok := available[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(content)
+		},
+	))
+	defer server.Close()
+	defer func(base string) { ciURLBase = base }(ciURLBase)
+	ciURLBase = server.URL
+
+	cases := map[string]struct {
+		branch string
+		rErr   bool
+	}{
+		"Consistent":      {branch: "release-1.18"},
+		"MissingArtifact": {branch: "release-1.19", rErr: true},
+		"VersionMismatch": {branch: "release-1.17", rErr: true},
+		"NoMarker":        {branch: "release-1.16", rErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := VerifyCIBuildConsistency(tc.branch)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
+// writeTestArtifacts creates the provided files with their contents below the
+// ReleaseTarsPath of `workDir`.
+func writeTestArtifacts(t *testing.T, workDir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(workDir, ReleaseTarsPath, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		require.Nil(t, ioutil.WriteFile(file, []byte(content), os.FileMode(0644)))
+	}
+}
+
+func TestListReleaseArtifacts(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	writeTestArtifacts(t, baseTmpDir, map[string]string{
+		"kubernetes.tar.gz":           "test",
+		"kubernetes.tar.gz.sha256":    "test",
+		"kubernetes.tar.gz.asc":       "test",
+		"extra/kubernetes-src.tar.gz": "test",
+	})
+
+	res, err := ListReleaseArtifacts(baseTmpDir)
+	require.Nil(t, err)
+	require.Equal(t, []string{"extra/kubernetes-src.tar.gz", "kubernetes.tar.gz"}, res)
+
+	_, err = ListReleaseArtifacts(filepath.Join(baseTmpDir, "notexisting"))
+	require.NotNil(t, err)
+}
+
+func TestDetectDuplicateArtifacts(t *testing.T) {
+	cases := map[string]struct {
+		files map[string]string
+		want  []string
+	}{
+		"NoDuplicates": {
+			files: map[string]string{
+				"kubernetes.tar.gz":     "test",
+				"kubernetes-src.tar.gz": "test",
+			},
+			want: []string{},
+		},
+		"Duplicates": {
+			files: map[string]string{
+				"kubernetes.tar.gz":     "test",
+				"old/kubernetes.tar.gz": "test",
+			},
+			want: []string{
+				"kubernetes.tar.gz (duplicate): kubernetes.tar.gz, old/kubernetes.tar.gz",
+			},
+		},
+		"Conflicting": {
+			files: map[string]string{
+				"kubernetes.tar.gz": "test",
+				"kubernetes.tgz":    "other",
+			},
+			want: []string{
+				"kubernetes.tar.gz (conflicting): kubernetes.tar.gz, kubernetes.tgz",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+			writeTestArtifacts(t, baseTmpDir, tc.files)
+
+			res, err := DetectDuplicateArtifacts(baseTmpDir)
+			require.Nil(t, err)
+			require.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestVerifyNonEmptyArtifacts(t *testing.T) {
+	cases := map[string]struct {
+		files map[string]string
+		rErr  bool
+	}{
+		"NonEmpty": {
+			files: map[string]string{
+				"kubernetes.tar.gz":     "test",
+				"kubernetes-src.tar.gz": "test",
+			},
+		},
+		"Empty": {
+			files: map[string]string{
+				"kubernetes.tar.gz":     "test",
+				"kubernetes-src.tar.gz": "",
+			},
+			rErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+			writeTestArtifacts(t, baseTmpDir, tc.files)
+
+			err = VerifyNonEmptyArtifacts(baseTmpDir)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
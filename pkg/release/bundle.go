@@ -0,0 +1,220 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GetKubeVersionFromFile retrieves the Kubernetes version from the local
+// marker file at `markerPath` like GetKubeVersion does for remote markers,
+// including the KubeVersionOverrideEnv and the SemVer normalization.
+func GetKubeVersionFromFile(markerPath string, useSemver bool) (string, error) {
+	return GetKubeVersionFromFileWithOptions(markerPath, useSemver, nil)
+}
+
+// GetKubeVersionFromFileWithOptions is GetKubeVersionFromFile, where `opts`
+// customize how the marker is normalized and logged like for
+// GetKubeVersionWithOptions.
+func GetKubeVersionFromFileWithOptions(markerPath string, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	log := opts.logger()
+	version, overridden, err := kubeVersionOverride(log)
+	if err != nil {
+		return "", err
+	}
+
+	if !overridden {
+		log.Infof("Retrieving Kubernetes build version from file %s...", markerPath)
+		content, err := ioutil.ReadFile(markerPath)
+		if err != nil {
+			return "", errors.Wrapf(err, "reading version marker %s", markerPath)
+		}
+		version = string(content)
+	}
+
+	version, err = normalizeMarker(version, useSemver, opts.trim())
+	if err != nil {
+		return "", errors.Wrapf(err, "version marker %s", markerPath)
+	}
+
+	log.Infof("Retrieved Kubernetes version: %s", version)
+	return version, nil
+}
+
+// LoadMarkerBundle loads the version markers of the bundle at `bundlePath`
+// into the directory `markerDir`. Using it as the MarkerDir of the
+// KubeVersionOptions makes GetKubeVersionWithOptions and friends resolve the
+// markers of the DefaultMirror without network access. Every marker is stored
+// under the CacheKey of its URL on the DefaultMirror. The bundle is either
+// a directory or an optionally gzipped tarball mirroring the layout of
+// dl.k8s.io, which means it contains the markers of the URL paths, for
+// example:
+//
+//	release/stable.txt
+//	release/stable-1.18.txt
+//	release/latest.txt
+//	ci/latest-1.19.txt
+//
+// All other files of the bundle are ignored.
+func LoadMarkerBundle(bundlePath, markerDir string) error {
+	_, err := LoadMarkerBundleWithOptions(bundlePath, markerDir, nil)
+	return err
+}
+
+// LoadMarkerBundleWithOptions is LoadMarkerBundle, which returns the files
+// the markers have been written to. The bundle is still read if `opts` enable
+// the dry run mode, but the markers are only logged.
+func LoadMarkerBundleWithOptions(bundlePath, markerDir string, opts *StageOptions) ([]string, error) {
+	if markerDir == "" {
+		return nil, errors.New("loading marker bundle: no marker directory provided")
+	}
+	mirror, err := url.Parse(DefaultMirror)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing default mirror %s", DefaultMirror)
+	}
+	host := strings.ToLower(mirror.Host)
+
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading marker bundle %s", bundlePath)
+	}
+
+	log := opts.logger()
+	loaded := []string{}
+	load := func(name string, r io.Reader) error {
+		markerPath, ok := bundleMarkerPath(name)
+		if !ok {
+			return nil
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return errors.Wrapf(err, "reading marker %s", name)
+		}
+
+		target := markerDirFile(markerDir, host, markerPath)
+		loaded = append(loaded, target)
+		if opts.dryRun() {
+			log.Infof("Dry run: would write marker %s to %s", markerPath, target)
+			return nil
+		}
+		if err := os.MkdirAll(markerDir, os.ModePerm); err != nil {
+			return errors.Wrapf(err, "creating cache directory for %s", markerPath)
+		}
+		if err := ioutil.WriteFile(target, content, os.FileMode(0644)); err != nil {
+			return errors.Wrapf(err, "caching marker %s", markerPath)
+		}
+		return nil
+	}
+
+	if info.IsDir() {
+		err = filepath.Walk(bundlePath, func(file string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(bundlePath, file)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return load(filepath.ToSlash(rel), f)
+		})
+	} else {
+		var f *os.File
+		f, err = os.Open(bundlePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading marker bundle %s", bundlePath)
+		}
+		defer f.Close()
+		err = walkTarReader(f, func(h *tar.Header, tr io.Reader) (bool, error) {
+			if h.Typeflag != tar.TypeReg {
+				return false, nil
+			}
+			return false, load(h.Name, tr)
+		})
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading marker bundle %s", bundlePath)
+	}
+
+	log.Infof("Loaded %d markers from bundle %s into %s", len(loaded), bundlePath, markerDir)
+	return loaded, nil
+}
+
+// localMarker returns the content of the marker for `markerURL` in the
+// MarkerDir of `opts` together with its file, and false if no MarkerDir is
+// configured or it does not contain an up to date marker.
+func localMarker(markerURL string, opts *KubeVersionOptions) (content, file string, ok bool) {
+	if opts == nil || opts.MarkerDir == "" {
+		return "", "", false
+	}
+	u, err := url.Parse(markerURL)
+	if err != nil {
+		return "", "", false
+	}
+	markerPath, ok := bundleMarkerPath(u.Path)
+	if !ok {
+		return "", "", false
+	}
+
+	file = markerDirFile(opts.MarkerDir, u.Host, markerPath)
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", "", false
+	}
+	if opts.MarkerMaxAge > 0 && time.Since(info.ModTime()) > opts.MarkerMaxAge {
+		opts.logger().Infof("Ignoring outdated local marker %s", file)
+		return "", "", false
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", "", false
+	}
+	return string(raw), file, true
+}
+
+// markerDirFile returns the file of the marker at `markerPath` on `host` in
+// `markerDir`. The markers are stored as published, which makes their key
+// independent of the conversion to SemVer.
+func markerDirFile(markerDir, host, markerPath string) string {
+	host = strings.ToLower(host)
+	markerURL := (&url.URL{Scheme: "https", Host: host, Path: "/" + markerPath}).String()
+	return filepath.Join(markerDir, CacheKey(markerURL, false, host)+".txt")
+}
+
+// bundleMarkerPath returns the cleaned relative path of the marker `name` and
+// false if it is no marker or escapes the bundle.
+func bundleMarkerPath(name string) (string, bool) {
+	markerPath := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if path.Ext(markerPath) != ".txt" || markerPath == ".txt" {
+		return "", false
+	}
+	return markerPath, true
+}
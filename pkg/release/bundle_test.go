@@ -0,0 +1,275 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMarkerBundle(t *testing.T) {
+	bundle := map[string]string{
+		"release/stable.txt":      "v1.18.3\n",
+		"release/stable-1.18.txt": "v1.18.3\n",
+		"./ci/latest-1.19.txt":    "v1.19.0-beta.1.58+e19c4a2b1ec777\n",
+		"README.md":               "ignored",
+	}
+
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	bundleDir := filepath.Join(baseTmpDir, "bundle")
+	for name, content := range bundle {
+		file := filepath.Join(bundleDir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		require.Nil(t, ioutil.WriteFile(file, []byte(content), os.FileMode(0644)))
+	}
+	bundleTar := filepath.Join(baseTmpDir, "bundle.tar.gz")
+	writeTestTarball(t, bundleTar, bundle)
+
+	require.NotNil(t, LoadMarkerBundle(bundleDir, ""))
+
+	// A dry run only returns the files of the markers
+	dryRunDir := filepath.Join(baseTmpDir, "markers-dry-run")
+	loaded, err := LoadMarkerBundleWithOptions(bundleTar, dryRunDir, &StageOptions{DryRun: true})
+	require.Nil(t, err)
+	require.Len(t, loaded, 3)
+	require.Contains(t, loaded, markerDirFile(dryRunDir, "dl.k8s.io", "release/stable.txt"))
+	_, err = os.Stat(dryRunDir)
+	require.True(t, os.IsNotExist(err))
+
+	for name, bundlePath := range map[string]string{
+		"Directory": bundleDir,
+		"Tarball":   bundleTar,
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &KubeVersionOptions{
+				MarkerDir: filepath.Join(baseTmpDir, "markers-"+name),
+			}
+			require.Nil(t, LoadMarkerBundle(bundlePath, opts.MarkerDir))
+
+			res, err := GetKubeVersionWithOptions(context.Background(), "https://dl.k8s.io/release/stable.txt", false, opts)
+			require.Nil(t, err)
+			require.Equal(t, "v1.18.3", res)
+
+			res, err = GetKubeVersionWithOptions(context.Background(), "https://dl.k8s.io/ci/latest-1.19.txt", true, opts)
+			require.Nil(t, err)
+			require.Equal(t, "1.19.0-beta.1.58+e19c4a2b1ec777", res)
+
+			_, _, ok := localMarker("https://dl.k8s.io/README.md", opts)
+			require.False(t, ok)
+			_, err = os.Stat(markerDirFile(opts.MarkerDir, "dl.k8s.io", "release/stable-1.18.txt"))
+			require.Nil(t, err)
+		})
+	}
+
+	require.NotNil(t, LoadMarkerBundle(
+		filepath.Join(baseTmpDir, "notexisting"), filepath.Join(baseTmpDir, "markers"),
+	))
+}
+
+func TestLocalMarker(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("v1.19.0-rc.1"))
+		},
+	))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.Nil(t, err)
+	file := markerDirFile(baseTmpDir, "dl.k8s.io", "release/stable.txt")
+	require.Nil(t, ioutil.WriteFile(file, []byte("v1.18.3\n"), os.FileMode(0644)))
+
+	opts := &KubeVersionOptions{MarkerDir: baseTmpDir}
+	res, resFile, ok := localMarker("https://dl.k8s.io/release/stable.txt", opts)
+	require.True(t, ok)
+	require.Equal(t, "v1.18.3\n", res)
+	require.Equal(t, file, resFile)
+
+	// Markers of other hosts are not taken from the directory
+	version, err := GetKubeVersionWithOptions(context.Background(), server.URL+"/release/stable.txt", false, opts)
+	require.Nil(t, err)
+	require.Equal(t, "v1.19.0-rc.1", version)
+
+	_, _, ok = localMarker("https://"+serverURL.Host+"/release/stable.txt", opts)
+	require.False(t, ok)
+
+	// Outdated markers are ignored
+	opts.MarkerMaxAge = time.Hour
+	_, _, ok = localMarker("https://dl.k8s.io/release/stable.txt", opts)
+	require.True(t, ok)
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.Nil(t, os.Chtimes(file, old, old))
+	_, _, ok = localMarker("https://dl.k8s.io/release/stable.txt", opts)
+	require.False(t, ok)
+
+	// The directory is disabled by default
+	_, _, ok = localMarker("https://dl.k8s.io/release/stable.txt", nil)
+	require.False(t, ok)
+	_, _, ok = localMarker("https://dl.k8s.io/release/stable.txt", &KubeVersionOptions{})
+	require.False(t, ok)
+}
+
+func TestBundleMarkerPath(t *testing.T) {
+	cases := map[string]struct {
+		name string
+		want string
+		ok   bool
+	}{
+		"Marker":     {name: "release/stable.txt", want: "release/stable.txt", ok: true},
+		"DotPrefix":  {name: "./ci/latest.txt", want: "ci/latest.txt", ok: true},
+		"URLPath":    {name: "/release/latest-1.19.txt", want: "release/latest-1.19.txt", ok: true},
+		"Escaping":   {name: "../../etc/stable.txt", want: "etc/stable.txt", ok: true},
+		"NoMarker":   {name: "release/v1.18.3/kubernetes.tar.gz"},
+		"EmptyName":  {name: ".txt"},
+		"EmptyInput": {name: ""},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, ok := bundleMarkerPath(tc.name)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestGetKubeVersionFromFile(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	writeMarker := func(name, content string) string {
+		file := filepath.Join(baseTmpDir, name)
+		require.Nil(t, ioutil.WriteFile(file, []byte(content), os.FileMode(0644)))
+		return file
+	}
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		path      string
+		useSemver bool
+		want      want
+	}{
+		"Release": {
+			path: writeMarker("stable.txt", "v1.18.3\n"),
+			want: want{r: "v1.18.3"},
+		},
+		"Semver": {
+			path:      writeMarker("latest.txt", "v1.19.0-beta.1.58+e19c4a2b1ec777\n"),
+			useSemver: true,
+			want:      want{r: "1.19.0-beta.1.58+e19c4a2b1ec777"},
+		},
+		"InvalidSemver": {
+			path:      writeMarker("wrong.txt", "wrong"),
+			useSemver: true,
+			want:      want{rErr: true},
+		},
+		"Empty": {
+			path: writeMarker("empty.txt", "\n"),
+			want: want{r: ""},
+		},
+		"NotExisting": {
+			path: filepath.Join(baseTmpDir, "notexisting.txt"),
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := GetKubeVersionFromFile(tc.path, tc.useSemver)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestGetKubeVersionFromFileWithOptions(t *testing.T) {
+	marker, err := ioutil.TempFile("", "marker-")
+	require.Nil(t, err)
+	defer os.Remove(marker.Name())
+	_, err = marker.WriteString("v1.19.0-beta.1.58+e19c4a2b1ec777\nbuilt from master\n")
+	require.Nil(t, err)
+	require.Nil(t, marker.Close())
+
+	logger := logrus.New()
+	logs := &bytes.Buffer{}
+	logger.SetOutput(logs)
+
+	res, err := GetKubeVersionFromFileWithOptions(
+		marker.Name(), true, &KubeVersionOptions{NoTrim: true, Logger: logger},
+	)
+	require.Nil(t, err)
+	require.Equal(t, "1.19.0-beta.1.58+e19c4a2b1ec777\nbuilt from master\n", res)
+	require.Contains(t, logs.String(), marker.Name())
+}
+
+func TestMarkerDir(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	requested := []string{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requested = append(requested, r.URL.Path)
+			w.Write([]byte("v1.19.0-rc.1"))
+		},
+	))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.Nil(t, err)
+	file := markerDirFile(baseTmpDir, serverURL.Host, "release/stable.txt")
+	require.Nil(t, ioutil.WriteFile(file, []byte("v1.18.3\n"), os.FileMode(0644)))
+
+	defer func(mirrors []string) { DefaultMirrors = mirrors }(DefaultMirrors)
+	DefaultMirrors = []string{server.URL}
+	opts := &KubeVersionOptions{MarkerDir: baseTmpDir}
+
+	res, err := GetStableReleaseKubeVersionWithOptions(context.Background(), true, opts)
+	require.Nil(t, err)
+	require.Equal(t, "1.18.3", res)
+	require.Empty(t, requested)
+
+	// Markers missing locally are fetched from the network
+	res, err = GetStablePrereleaseKubeVersionWithOptions(context.Background(), false, opts)
+	require.Nil(t, err)
+	require.Equal(t, "v1.19.0-rc.1", res)
+	require.Equal(t, []string{"/release/latest.txt"}, requested)
+}
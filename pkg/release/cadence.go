@@ -0,0 +1,183 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/git"
+	"k8s.io/release/pkg/util"
+)
+
+// Position is the place of a release within the cadence of its minor.
+type Position struct {
+	// Minor is the minor release line, for example "1.21".
+	Minor string
+
+	// PatchIndex is the number of patch releases since the .0 release of the
+	// minor, which means it is 3 for v1.21.3.
+	PatchIndex uint64
+
+	// Released is the publication time of the release, zero if unknown.
+	Released time.Time
+
+	// MinorReleased is the publication time of the .0 release of the minor,
+	// zero if unknown.
+	MinorReleased time.Time
+}
+
+// SinceMinor returns the time between the .0 release of the minor and the
+// release, and false if any of them is unknown.
+func (p Position) SinceMinor() (time.Duration, bool) {
+	if p.Released.IsZero() || p.MinorReleased.IsZero() {
+		return 0, false
+	}
+	return p.Released.Sub(p.MinorReleased), true
+}
+
+// String returns a human readable summary of the position, for example
+// "patch 3 of 1.21, released 5w0d after 1.21.0".
+func (p Position) String() string {
+	res := fmt.Sprintf("patch %d of %s", p.PatchIndex, p.Minor)
+	if since, ok := p.SinceMinor(); ok {
+		days := int(since.Hours() / 24)
+		res += fmt.Sprintf(", released %dw%dd after %s.0", days/7, days%7, p.Minor)
+	}
+	return res
+}
+
+// CadencePosition returns the position of the official release `version`
+// within its minor. The publication times are taken from the release
+// artifacts and left empty if they are not available.
+func CadencePosition(version string) (Position, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return Position{}, errors.Wrapf(err, "parsing version %s", version)
+	}
+
+	position, err := patchPosition(sem)
+	if err != nil {
+		return Position{}, errors.Wrapf(err, "computing position of %s", version)
+	}
+
+	minorVersion := util.SemverToTagString(semver.Version{
+		Major: sem.Major, Minor: sem.Minor,
+	})
+	for target, v := range map[*time.Time]string{
+		&position.Released:      util.SemverToTagString(sem),
+		&position.MinorReleased: minorVersion,
+	} {
+		released, err := releaseTime(v)
+		if err != nil {
+			logrus.Warnf("Unable to retrieve release time of %s: %v", v, err)
+			continue
+		}
+		*target = released
+	}
+	return position, nil
+}
+
+// patchPosition returns the position of `sem` without any release times.
+func patchPosition(sem semver.Version) (Position, error) {
+	if len(sem.Pre) > 0 || len(sem.Build) > 0 {
+		return Position{}, errors.New("not an official release")
+	}
+	return Position{
+		Minor:      fmt.Sprintf("%d.%d", sem.Major, sem.Minor),
+		PatchIndex: sem.Patch,
+	}, nil
+}
+
+// releaseTime returns the publication time of `version` based on the
+// modification time of its kubernetes.tar.gz.
+func releaseTime(version string) (time.Time, error) {
+	artifactURL, err := ReleaseDownloadURL(version, kubernetesTar)
+	if err != nil {
+		return time.Time{}, err
+	}
+	modified, err := headLastModified(context.Background(), defaultHTTPClient, artifactURL)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if modified.IsZero() {
+		return time.Time{}, errors.Errorf("no modification time available for %s", artifactURL)
+	}
+	return modified, nil
+}
+
+// ReleaseDate returns the date `version` has been tagged in the GitHub
+// repository `org`/`repo`, for example kubernetes/kubernetes, which gets
+// cloned into a temporary directory. For annotated tags this is the tagger
+// date, for lightweight tags the committer date of the tagged commit.
+func ReleaseDate(version, org, repo string) (time.Time, error) {
+	tag, err := releaseDateTag(version)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	r, err := git.CloneOrOpenGitHubRepo("", org, repo, false)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "cloning repository %s/%s", org, repo)
+	}
+	defer func() {
+		if err := r.Cleanup(); err != nil {
+			logrus.Warnf("Unable to remove clone of %s/%s: %v", org, repo, err)
+		}
+	}()
+
+	date, err := r.TagDate(tag)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "in repository %s/%s", org, repo)
+	}
+	return date, nil
+}
+
+// ReleaseDateFromRepo is ReleaseDate, which uses the existing git repository
+// at `repoPath` instead of cloning it.
+func ReleaseDateFromRepo(version, repoPath string) (time.Time, error) {
+	tag, err := releaseDateTag(version)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	r, err := git.OpenRepo(repoPath)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "opening repository %s", repoPath)
+	}
+
+	date, err := r.TagDate(tag)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "in repository %s", repoPath)
+	}
+	return date, nil
+}
+
+// releaseDateTag returns the tag of `version` looked up by ReleaseDate.
+func releaseDateTag(version string) (string, error) {
+	tag := util.AddTagPrefix(strings.TrimSpace(version))
+	if _, err := util.TagStringToSemver(tag); err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+	return tag, nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/release/pkg/command"
+)
+
+func TestPatchPosition(t *testing.T) {
+	type want struct {
+		r    Position
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Patch": {
+			version: "1.21.3",
+			want:    want{r: Position{Minor: "1.21", PatchIndex: 3}},
+		},
+		"Minor": {
+			version: "1.21.0",
+			want:    want{r: Position{Minor: "1.21"}},
+		},
+		"PreRelease": {
+			version: "1.21.0-rc.1",
+			want:    want{rErr: true},
+		},
+		"CI": {
+			version: "1.21.3-rc.0.12+f1a2b3c4d5e6f7",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := patchPosition(semver.MustParse(tc.version))
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestCadencePosition(t *testing.T) {
+	minorReleased := time.Date(2021, time.April, 8, 0, 0, 0, 0, time.UTC)
+	released := minorReleased.Add(5*7*24*time.Hour + 2*24*time.Hour)
+	modified := map[string]time.Time{
+		"/v1.21.0/kubernetes.tar.gz": minorReleased,
+		"/v1.21.3/kubernetes.tar.gz": released,
+		"/v1.20.5/kubernetes.tar.gz": released,
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			m, ok := modified[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Last-Modified", m.Format(http.TimeFormat))
+		},
+	))
+	defer server.Close()
+	defer func(base string) { downloadURLBase = base }(downloadURLBase)
+	downloadURLBase = server.URL
+
+	res, err := CadencePosition("v1.21.3")
+	require.Nil(t, err)
+	require.Equal(t, Position{
+		Minor:         "1.21",
+		PatchIndex:    3,
+		Released:      released,
+		MinorReleased: minorReleased,
+	}, res)
+	since, ok := res.SinceMinor()
+	require.True(t, ok)
+	require.Equal(t, 37*24*time.Hour, since)
+	require.Equal(t, "patch 3 of 1.21, released 5w2d after 1.21.0", res.String())
+
+	// The .0 release is not available
+	res, err = CadencePosition("v1.20.5")
+	require.Nil(t, err)
+	require.Equal(t, released, res.Released)
+	require.True(t, res.MinorReleased.IsZero())
+	_, ok = res.SinceMinor()
+	require.False(t, ok)
+	require.Equal(t, "patch 5 of 1.20", res.String())
+
+	_, err = CadencePosition("v1.21.0-rc.1")
+	require.NotNil(t, err)
+	_, err = CadencePosition("wrong")
+	require.NotNil(t, err)
+}
+
+func TestReleaseDateFromRepo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-test-")
+	require.Nil(t, err)
+	defer cleanupTmps(t, dir)
+
+	committed := time.Date(2020, time.May, 20, 10, 0, 0, 0, time.UTC)
+	tagged := committed.Add(36 * time.Hour)
+	defer os.Unsetenv("GIT_AUTHOR_DATE")
+	defer os.Unsetenv("GIT_COMMITTER_DATE")
+	for _, step := range []struct {
+		date time.Time
+		args []string
+	}{
+		{committed, []string{"init"}},
+		{committed, []string{"commit", "--allow-empty", "-m", "First commit"}},
+		{committed, []string{"tag", "v1.18.3"}},
+		{tagged, []string{"tag", "-a", "v1.18.4", "-m", "v1.18.4"}},
+	} {
+		date := step.date.Format(time.RFC3339)
+		require.Nil(t, os.Setenv("GIT_AUTHOR_DATE", date))
+		require.Nil(t, os.Setenv("GIT_COMMITTER_DATE", date))
+		require.Nil(t, command.NewWithWorkDir(dir, "git", append([]string{
+			"-c", "user.name=John Doe", "-c", "user.email=john@doe.org",
+		}, step.args...)...).RunSilentSuccess())
+	}
+
+	type want struct {
+		r    time.Time
+		rErr bool
+	}
+	cases := map[string]struct {
+		version  string
+		repoPath string
+		want     want
+	}{
+		"Lightweight": {
+			version: "v1.18.3", repoPath: dir, want: want{r: committed},
+		},
+		"Annotated": {
+			version: "v1.18.4", repoPath: dir, want: want{r: tagged},
+		},
+		"NoPrefix": {
+			version: "1.18.4", repoPath: dir, want: want{r: tagged},
+		},
+		"MissingTag": {
+			version: "v1.18.5", repoPath: dir, want: want{rErr: true},
+		},
+		"InvalidVersion": {
+			version: "wrong", repoPath: dir, want: want{rErr: true},
+		},
+		"NoRepository": {
+			version:  "v1.18.3",
+			repoPath: filepath.Join(dir, "notexisting"),
+			want:     want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ReleaseDateFromRepo(tc.version, tc.repoPath)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res.UTC())
+		})
+	}
+}
+
+func TestReleaseDateInvalidVersion(t *testing.T) {
+	// The version is validated before cloning the repository
+	_, err := ReleaseDate("wrong", "kubernetes", "kubernetes")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "parsing version wrong")
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// channelGetters are the functions retrieving the published version of a
+// channel.
+var channelGetters = map[ReleaseType]func(useSemver bool) (string, error){
+	ReleaseTypeStable: GetStableReleaseKubeVersion,
+	ReleaseTypeLatest: GetStablePrereleaseKubeVersion,
+	ReleaseTypeCI:     GetLatestCIKubeVersion,
+}
+
+// ChannelOverrideEnv returns the name of the environment variable which
+// overrides the version of `channel`, for example K8S_STABLE_OVERRIDE.
+func ChannelOverrideEnv(channel ReleaseType) string {
+	return fmt.Sprintf("K8S_%s_OVERRIDE", strings.ToUpper(string(channel)))
+}
+
+// ResolveChannelVersions retrieves the versions of the provided channels. A
+// channel is not fetched if its ChannelOverrideEnv environment variable is
+// set, in which case the override is validated and used instead.
+func ResolveChannelVersions(useSemver bool, channels ...ReleaseType) (map[ReleaseType]string, error) {
+	versions := map[ReleaseType]string{}
+	for _, channel := range channels {
+		getter, ok := channelGetters[channel]
+		if !ok {
+			return nil, errors.Errorf("unknown release type %q", channel)
+		}
+
+		version, err := channelOverride(channel)
+		if err != nil {
+			return nil, err
+		}
+
+		if version == "" {
+			version, err = getter(useSemver)
+			if err != nil {
+				return nil, errors.Wrapf(err, "retrieving %s version", channel)
+			}
+		} else {
+			logrus.Infof(
+				"Using %s version %s from %s", channel, version,
+				ChannelOverrideEnv(channel),
+			)
+			version, err = NormalizeKubeVersion(version, useSemver)
+			if err != nil {
+				return nil, errors.Wrapf(err, "normalizing %s override", channel)
+			}
+		}
+
+		versions[channel] = version
+	}
+	return versions, nil
+}
+
+// channelOverride returns the validated override of `channel` or an empty
+// string if none is set.
+func channelOverride(channel ReleaseType) (string, error) {
+	env := ChannelOverrideEnv(channel)
+	version := strings.TrimSpace(os.Getenv(env))
+	if version == "" {
+		return "", nil
+	}
+
+	valid, err := IsValidReleaseBuild(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "validating %s override %s", env, version)
+	}
+	if !valid {
+		return "", errors.Errorf("%s is not a valid release version: %s", env, version)
+	}
+	return version, nil
+}
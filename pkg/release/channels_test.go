@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelOverrideEnv(t *testing.T) {
+	require.Equal(t, "K8S_STABLE_OVERRIDE", ChannelOverrideEnv(ReleaseTypeStable))
+	require.Equal(t, "K8S_LATEST_OVERRIDE", ChannelOverrideEnv(ReleaseTypeLatest))
+	require.Equal(t, "K8S_CI_OVERRIDE", ChannelOverrideEnv(ReleaseTypeCI))
+}
+
+func TestResolveChannelVersions(t *testing.T) {
+	getters := channelGetters
+	defer func() { channelGetters = getters }()
+	channelGetters = map[ReleaseType]func(bool) (string, error){
+		ReleaseTypeStable: func(bool) (string, error) { return "v1.18.3", nil },
+		ReleaseTypeLatest: func(bool) (string, error) { return "v1.19.0-beta.1", nil },
+		ReleaseTypeCI: func(bool) (string, error) {
+			return "", errors.New("not available")
+		},
+	}
+
+	type want struct {
+		r    map[ReleaseType]string
+		rErr bool
+	}
+	cases := map[string]struct {
+		overrides map[ReleaseType]string
+		useSemver bool
+		channels  []ReleaseType
+		want      want
+	}{
+		"Fetched": {
+			channels: []ReleaseType{ReleaseTypeStable, ReleaseTypeLatest},
+			want: want{r: map[ReleaseType]string{
+				ReleaseTypeStable: "v1.18.3",
+				ReleaseTypeLatest: "v1.19.0-beta.1",
+			}},
+		},
+		"Overridden": {
+			overrides: map[ReleaseType]string{
+				ReleaseTypeStable: "v1.18.2",
+				ReleaseTypeCI:     "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			},
+			channels: []ReleaseType{ReleaseTypeStable, ReleaseTypeLatest, ReleaseTypeCI},
+			want: want{r: map[ReleaseType]string{
+				ReleaseTypeStable: "v1.18.2",
+				ReleaseTypeLatest: "v1.19.0-beta.1",
+				ReleaseTypeCI:     "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			}},
+		},
+		"OverriddenSemver": {
+			overrides: map[ReleaseType]string{ReleaseTypeStable: "v1.18.2"},
+			useSemver: true,
+			channels:  []ReleaseType{ReleaseTypeStable},
+			want:      want{r: map[ReleaseType]string{ReleaseTypeStable: "1.18.2"}},
+		},
+		"InvalidOverride": {
+			overrides: map[ReleaseType]string{ReleaseTypeStable: "wrong"},
+			channels:  []ReleaseType{ReleaseTypeStable},
+			want:      want{rErr: true},
+		},
+		"FetchFailure": {
+			channels: []ReleaseType{ReleaseTypeCI},
+			want:     want{rErr: true},
+		},
+		"UnknownChannel": {
+			channels: []ReleaseType{ReleaseType("wrong")},
+			want:     want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			for channel, version := range tc.overrides {
+				env := ChannelOverrideEnv(channel)
+				require.Nil(t, os.Setenv(env, version))
+				defer os.Unsetenv(env)
+			}
+
+			res, err := ResolveChannelVersions(tc.useSemver, tc.channels...)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+// checksumFuncs are the functions computing the content of the file with the
+// respective checksum extension.
+var checksumFuncs = map[string]func(string) (string, error){
+	".sha256": util.SHA256ForFile,
+	".sha512": util.SHA512ForFile,
+}
+
+// VerifyExistingChecksums checks that the checksum files next to every
+// artifact below `dir` still match the artifact, which catches corruption
+// after the checksums have been generated. The returned error lists both
+// artifacts without any checksum file and checksum mismatches.
+func VerifyExistingChecksums(dir string) error {
+	artifacts := []string{}
+	if err := filepath.Walk(dir, func(
+		file string, info os.FileInfo, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && !isArtifactMetadata(file) {
+			artifacts = append(artifacts, file)
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "listing files in %s", dir)
+	}
+	sort.Strings(artifacts)
+
+	missing := []string{}
+	mismatch := []string{}
+	for _, artifact := range artifacts {
+		found := false
+		for _, ext := range ChecksumExtensions {
+			checksumFile := artifact + ext
+			if !util.Exists(checksumFile) {
+				continue
+			}
+			found = true
+
+			content, err := ioutil.ReadFile(checksumFile)
+			if err != nil {
+				return errors.Wrapf(err, "reading checksum file %s", checksumFile)
+			}
+			expected := strings.Fields(string(content))
+
+			actual, err := checksumFuncs[ext](artifact)
+			if err != nil {
+				return err
+			}
+			if len(expected) == 0 || expected[0] != actual {
+				mismatch = append(mismatch, checksumFile)
+			}
+		}
+		if !found {
+			missing = append(missing, artifact)
+		}
+	}
+
+	problems := []string{}
+	if len(missing) > 0 {
+		problems = append(problems, "missing checksum: "+strings.Join(missing, ", "))
+	}
+	if len(mismatch) > 0 {
+		problems = append(problems, "checksum mismatch: "+strings.Join(mismatch, ", "))
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("checksum verification failed (%s)", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// VerifyTarballChecksum checks that the SHA256 of the file at `tarballPath`
+// is `sha256`. The file is streamed, which means it is not loaded into memory
+// at once.
+func VerifyTarballChecksum(tarballPath, sha256 string) error {
+	actual, err := util.SHA256ForFile(tarballPath)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, strings.TrimSpace(sha256)) {
+		return errors.Errorf(
+			"checksum mismatch for %s: expected SHA256 %s, got %s",
+			tarballPath, sha256, actual,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyExistingChecksums(t *testing.T) {
+	const (
+		testSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+		testSHA512 = "ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff"
+	)
+
+	cases := map[string]struct {
+		files    map[string]string
+		rErr     bool
+		contains string
+	}{
+		"Valid": {
+			files: map[string]string{
+				"kubernetes.tar.gz":            "test",
+				"kubernetes.tar.gz.sha256":     testSHA256,
+				"kubernetes.tar.gz.sha512":     testSHA512 + "  kubernetes.tar.gz\n",
+				"bin/kubectl":                  "test",
+				"bin/kubectl.sha256":           testSHA256 + "\n",
+				"bin/kubectl.sha256.asc":       "signature",
+				"kubernetes-src.tar.gz":        "test",
+				"kubernetes-src.tar.gz.sha512": testSHA512,
+			},
+		},
+		"Missing": {
+			files: map[string]string{
+				"kubernetes.tar.gz":     "test",
+				"kubernetes.tar.gz.asc": "signature",
+			},
+			rErr:     true,
+			contains: "missing checksum",
+		},
+		"Mismatch": {
+			files: map[string]string{
+				"kubernetes.tar.gz":        "corrupted",
+				"kubernetes.tar.gz.sha256": testSHA256,
+			},
+			rErr:     true,
+			contains: "checksum mismatch",
+		},
+		"EmptyChecksum": {
+			files: map[string]string{
+				"kubernetes.tar.gz":        "test",
+				"kubernetes.tar.gz.sha512": "",
+			},
+			rErr:     true,
+			contains: "checksum mismatch",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+			writeTestArtifacts(t, baseTmpDir, tc.files)
+
+			err = VerifyExistingChecksums(filepath.Join(baseTmpDir, ReleaseTarsPath))
+			require.Equal(t, tc.rErr, err != nil)
+			if tc.rErr {
+				require.Contains(t, err.Error(), tc.contains)
+			}
+		})
+	}
+}
+
+func TestVerifyTarballChecksum(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	tarball := filepath.Join(baseTmpDir, kubernetesTar)
+	require.Nil(t, ioutil.WriteFile(tarball, []byte("test"), 0644))
+
+	cases := map[string]struct {
+		path   string
+		sha256 string
+		rErr   bool
+	}{
+		"Match": {
+			path:   tarball,
+			sha256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		},
+		"MatchUpperCase": {
+			path:   tarball,
+			sha256: "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08\n",
+		},
+		"Mismatch": {
+			path:   tarball,
+			sha256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			rErr:   true,
+		},
+		"NotExisting": {
+			path:   filepath.Join(baseTmpDir, "notexisting"),
+			sha256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			rErr:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := VerifyTarballChecksum(tc.path, tc.sha256)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
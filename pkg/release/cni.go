@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+// cniConfigureScript is the path of the node configuration script below
+// GCEPath, which pins the CNI plugins version.
+const cniConfigureScript = "gci/configure.sh"
+
+// cniVersionRE matches the pinned CNI plugins version of the configure
+// script, for example DEFAULT_CNI_VERSION="v0.8.6".
+var cniVersionRE = regexp.MustCompile(`(?m)^\s*DEFAULT_CNI_VERSION=["']?([^"'\s]+)["']?`)
+
+// GetRequiredCNIVersion returns the CNI plugins version pinned by the staged
+// GCE cluster scripts in the build output directory `workDir`, for example
+// v0.8.6.
+func GetRequiredCNIVersion(workDir string) (string, error) {
+	script := filepath.Join(workDir, GCEPath, filepath.FromSlash(cniConfigureScript))
+	content, err := ioutil.ReadFile(script)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading CNI configure script %s", script)
+	}
+
+	match := cniVersionRE.FindSubmatch(content)
+	if match == nil {
+		return "", errors.Errorf("unable to find CNI version pin in %s", script)
+	}
+
+	version := string(match[1])
+	if _, err := util.TagStringToSemver(version); err != nil {
+		return "", errors.Errorf(
+			"invalid CNI version %q pinned in %s", version, script,
+		)
+	}
+	return util.AddTagPrefix(version), nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRequiredCNIVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		script string
+		want   want
+	}{
+		"Version": {
+			script: `DEFAULT_CNI_VERSION="v0.8.6"
+DEFAULT_CNI_SHA1="a31251105250279fe57b4474d91d2db1d4d48b5a"
+`,
+			want: want{r: "v0.8.6"},
+		},
+		"Indented": {
+			script: `function install-cni-binaries {
+  DEFAULT_CNI_VERSION='0.8.7'
+}`,
+			want: want{r: "v0.8.7"},
+		},
+		"InvalidVersion": {
+			script: `DEFAULT_CNI_VERSION="latest"`,
+			want:   want{rErr: true},
+		},
+		"NoPin": {
+			script: `CNI_VERSION="${CNI_VERSION:-${DEFAULT_CNI_VERSION}}"`,
+			want:   want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+
+			script := filepath.Join(baseTmpDir, GCEPath, cniConfigureScript)
+			require.Nil(t, os.MkdirAll(filepath.Dir(script), os.ModePerm))
+			require.Nil(t, ioutil.WriteFile(
+				script, []byte(tc.script), os.FileMode(0644),
+			))
+
+			res, err := GetRequiredCNIVersion(baseTmpDir)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestGetRequiredCNIVersionMissingScript(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	_, err = GetRequiredCNIVersion(baseTmpDir)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/util"
+)
+
+// DifferenceKind is the type of a Difference.
+type DifferenceKind string
+
+const (
+	// DifferenceAdded is an artifact which exists only locally.
+	DifferenceAdded DifferenceKind = "added"
+
+	// DifferenceRemoved is an artifact which exists only in the published
+	// release.
+	DifferenceRemoved DifferenceKind = "removed"
+
+	// DifferenceMismatch is an artifact whose local checksum does not match
+	// the published one.
+	DifferenceMismatch DifferenceKind = "mismatch"
+)
+
+// Difference is a single difference between a local build and a published
+// release. The checksums are empty if the artifact does not exist on the
+// respective side.
+type Difference struct {
+	Artifact        string
+	Kind            DifferenceKind
+	LocalSHA256     string
+	PublishedSHA256 string
+}
+
+// CompareWithPublished compares the release artifacts in the build output
+// directory `workDir` with the published release of `version` based on their
+// SHA256 checksums. The published side covers the artifacts expected for the
+// locally built linux architectures and every other local artifact. The
+// returned differences are sorted by the artifact name.
+func CompareWithPublished(workDir, version string) ([]Difference, error) {
+	artifacts, err := ListReleaseArtifacts(workDir)
+	if err != nil {
+		return nil, err
+	}
+	platforms, err := DetectBuiltPlatforms(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	local := map[string]string{}
+	for _, artifact := range artifacts {
+		sha, err := util.SHA256ForFile(filepath.Join(workDir, ReleaseTarsPath, artifact))
+		if err != nil {
+			return nil, err
+		}
+		local[filepath.Base(artifact)] = sha
+	}
+
+	arches := []string{}
+	for _, platform := range platforms {
+		if platform.OS == "linux" {
+			arches = append(arches, platform.Arch)
+		}
+	}
+	urls, err := ReleaseURLSet(version, arches)
+	if err != nil {
+		return nil, err
+	}
+	checksumURLs := map[string]string{}
+	for _, u := range urls {
+		if strings.HasSuffix(u, ".sha256") {
+			checksumURLs[path.Base(strings.TrimSuffix(u, ".sha256"))] = u
+		}
+	}
+	for name := range local {
+		if _, ok := checksumURLs[name]; ok {
+			continue
+		}
+		u, err := ReleaseDownloadURL(version, name)
+		if err != nil {
+			return nil, err
+		}
+		checksumURLs[name] = u + ".sha256"
+	}
+
+	differences := []Difference{}
+	for name, checksumURL := range checksumURLs {
+		published, err := getPublishedChecksum(checksumURL)
+		if err != nil {
+			return nil, err
+		}
+
+		localSHA, isLocal := local[name]
+		diff := Difference{
+			Artifact:        name,
+			LocalSHA256:     localSHA,
+			PublishedSHA256: published,
+		}
+		switch {
+		case !isLocal && published == "":
+			continue
+		case !isLocal:
+			diff.Kind = DifferenceRemoved
+		case published == "":
+			diff.Kind = DifferenceAdded
+		case localSHA != published:
+			diff.Kind = DifferenceMismatch
+		default:
+			continue
+		}
+
+		logrus.Warnf("Artifact %s differs from published release: %s", name, diff.Kind)
+		differences = append(differences, diff)
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Artifact < differences[j].Artifact
+	})
+	return differences, nil
+}
+
+// getPublishedChecksum returns the checksum published at `checksumURL` or an
+// empty string if it does not exist.
+func getPublishedChecksum(checksumURL string) (string, error) {
+	resp, err := defaultHTTPClient.Get(checksumURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "an error occurred GET-ing %s", checksumURL)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if err := checkStatus(resp, checksumURL); err != nil {
+		return "", err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not handle the response body for %s", checksumURL)
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", errors.Errorf("empty checksum at %s", checksumURL)
+	}
+	return fields[0], nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareWithPublished(t *testing.T) {
+	const (
+		testSHA  = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+		otherSHA = "4a809e5bd9b6dc3f19ca5b2ffa2ed2d54ff2c0dc8a4cc7cd10b5e34e1bc4a05c"
+	)
+	published := map[string]string{
+		"/v1.18.3/kubernetes.tar.gz.sha256":                    testSHA,
+		"/v1.18.3/kubernetes-src.tar.gz.sha256":                otherSHA,
+		"/v1.18.3/kubernetes-manifests.tar.gz.sha256":          testSHA + "  kubernetes-manifests.tar.gz",
+		"/v1.18.3/kubernetes-client-linux-amd64.tar.gz.sha256": testSHA,
+		"/v1.18.3/kubernetes-server-linux-amd64.tar.gz.sha256": testSHA,
+		"/v1.18.3/kubernetes-node-linux-amd64.tar.gz.sha256":   testSHA,
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			sha, ok := published[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintln(w, sha)
+		},
+	))
+	defer server.Close()
+
+	base := downloadURLBase
+	downloadURLBase = server.URL
+	defer func() { downloadURLBase = base }()
+
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	writeTestArtifacts(t, baseTmpDir, map[string]string{
+		"kubernetes.tar.gz":                     "test",
+		"kubernetes-src.tar.gz":                 "test",
+		"kubernetes-manifests.tar.gz":           "test",
+		"kubernetes-client-linux-amd64.tar.gz":  "test",
+		"kubernetes-server-linux-amd64.tar.gz":  "test",
+		"kubernetes-client-darwin-amd64.tar.gz": "test",
+	})
+
+	res, err := CompareWithPublished(baseTmpDir, "v1.18.3")
+	require.Nil(t, err)
+	require.Equal(t, []Difference{
+		{
+			Artifact:    "kubernetes-client-darwin-amd64.tar.gz",
+			Kind:        DifferenceAdded,
+			LocalSHA256: testSHA,
+		},
+		{
+			Artifact:        "kubernetes-node-linux-amd64.tar.gz",
+			Kind:            DifferenceRemoved,
+			PublishedSHA256: testSHA,
+		},
+		{
+			Artifact:        "kubernetes-src.tar.gz",
+			Kind:            DifferenceMismatch,
+			LocalSHA256:     testSHA,
+			PublishedSHA256: otherSHA,
+		},
+	}, res)
+
+	_, err = CompareWithPublished(baseTmpDir, "wrong")
+	require.NotNil(t, err)
+}
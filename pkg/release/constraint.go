@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/util"
+)
+
+// ErrVersionNotFound is returned if no version matches the requested
+// criteria.
+var ErrVersionNotFound = errors.New("version not found")
+
+// constraintVersionRegex matches the versions of a semver range, where the
+// patch version is missing for wildcards like 1.20.x.
+var constraintVersionRegex = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// LatestMatchingConstraint returns the newest stable version satisfying the
+// semver range `constraint`, for example ">=1.20.0 <1.22.0". The candidates
+// are all patch releases of the minor releases the constraint allows, so
+// only their markers get fetched. The returned error wraps
+// ErrVersionNotFound if no candidate matches.
+func LatestMatchingConstraint(constraint string, useSemver bool) (string, error) {
+	matches, err := semver.ParseRange(constraint)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing constraint %q", constraint)
+	}
+
+	candidates, err := stableVersionCandidates(func(major, minor uint64) bool {
+		return constraintAllowsMinor(constraint, matches, major, minor)
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Sort(sort.Reverse(semver.Versions(candidates)))
+
+	for _, candidate := range candidates {
+		if matches(candidate) {
+			version := util.SemverToTagString(candidate)
+			logrus.Infof("Found version %s matching %q", version, constraint)
+			return NormalizeKubeVersion(version, useSemver)
+		}
+	}
+	return "", errors.Wrapf(ErrVersionNotFound, "no version matching %q", constraint)
+}
+
+// stableVersionCandidates returns all patch releases of the minor releases
+// of the latest major version selected by `allowed`, as listed by
+// ListPatchVersions. Minors without a stable marker are skipped.
+func stableVersionCandidates(allowed func(major, minor uint64) bool) ([]semver.Version, error) {
+	latest, err := fetchMarker(context.Background(), downloadURLBase+"/stable.txt", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving latest stable version")
+	}
+	latestSem, err := util.TagStringToSemver(latest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing latest stable version %s", latest)
+	}
+
+	minors := []int{}
+	for minor := uint64(0); minor <= latestSem.Minor; minor++ {
+		if allowed(latestSem.Major, minor) {
+			minors = append(minors, int(minor))
+		}
+	}
+
+	major := int(latestSem.Major)
+	patches := make([][]string, len(minors))
+	if err := runParallel(len(minors), func(i int) error {
+		minor := minors[i]
+		versions, err := ListPatchVersions(major, minor)
+		if statusErr, ok := errors.Cause(err).(*statusError); ok && statusErr.code == http.StatusNotFound {
+			logrus.Debugf("Skipping unreleased minor %d.%d: %v", major, minor, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		patches[i] = versions
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	candidates := []semver.Version{}
+	for _, versions := range patches {
+		for _, version := range versions {
+			sem, err := util.TagStringToSemver(version)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing version %s", version)
+			}
+			candidates = append(candidates, sem)
+		}
+	}
+	return candidates, nil
+}
+
+// constraintAllowsMinor returns whether any patch release of `major`.`minor`
+// satisfies `matches`, the parsed range of `constraint`. Within a minor the
+// result of a range only changes at the patch releases the constraint
+// mentions, so checking those, their neighbours, the first and a very high
+// patch release is sufficient.
+func constraintAllowsMinor(constraint string, matches semver.Range, major, minor uint64) bool {
+	patches := []uint64{0, math.MaxUint32}
+	for _, match := range constraintVersionRegex.FindAllStringSubmatch(constraint, -1) {
+		if match[1] != strconv.FormatUint(major, 10) || match[2] != strconv.FormatUint(minor, 10) {
+			continue
+		}
+		patch, err := strconv.ParseUint(match[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		patches = append(patches, patch, patch+1)
+		if patch > 0 {
+			patches = append(patches, patch-1)
+		}
+	}
+
+	for _, patch := range patches {
+		if matches(semver.Version{Major: major, Minor: minor, Patch: patch}) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// newStableMarkersServer returns a test server serving the provided release
+// markers, e.g. "stable-1.20.txt", and the kubernetes.tar.gz of every release
+// except the `unpublished` ones. downloadURLBase is pointed to the server
+// until the returned function is called.
+func newStableMarkersServer(markers map[string]string, unpublished ...string) func() {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if version := strings.TrimSuffix(r.URL.Path[1:], "/"+kubernetesTar); version != r.URL.Path[1:] {
+				for _, missing := range unpublished {
+					if version == missing {
+						http.NotFound(w, r)
+						return
+					}
+				}
+				return
+			}
+			version, ok := markers[r.URL.Path[1:]]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintln(w, version)
+		},
+	))
+
+	base := downloadURLBase
+	downloadURLBase = server.URL
+	return func() {
+		downloadURLBase = base
+		server.Close()
+	}
+}
+
+func TestLatestMatchingConstraint(t *testing.T) {
+	defer newStableMarkersServer(map[string]string{
+		"stable.txt":      "v1.22.1",
+		"stable-1.22.txt": "v1.22.1",
+		"stable-1.21.txt": "v1.21.4",
+		"stable-1.20.txt": "v1.20.10",
+		"stable-1.19.txt": "v1.19.14",
+	})()
+
+	type want struct {
+		r        string
+		notFound bool
+		rErr     bool
+	}
+	cases := map[string]struct {
+		constraint string
+		useSemver  bool
+		want       want
+	}{
+		"Latest": {
+			constraint: ">=1.19.0",
+			want:       want{r: "v1.22.1"},
+		},
+		"Range": {
+			constraint: ">=1.20.0 <1.22.0",
+			want:       want{r: "v1.21.4"},
+		},
+		"OlderPatch": {
+			constraint: ">=1.20.0 <1.20.5",
+			want:       want{r: "v1.20.4"},
+		},
+		"RangeSemver": {
+			constraint: ">=1.20.0 <1.22.0",
+			useSemver:  true,
+			want:       want{r: "1.21.4"},
+		},
+		"Or": {
+			constraint: "<1.20.0 || >2.0.0",
+			want:       want{r: "v1.19.14"},
+		},
+		"NotFound": {
+			constraint: ">=2.0.0",
+			want:       want{rErr: true, notFound: true},
+		},
+		"InvalidConstraint": {
+			constraint: ">=wrong",
+			want:       want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := LatestMatchingConstraint(tc.constraint, tc.useSemver)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.notFound, errors.Cause(err) == ErrVersionNotFound)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestLatestMatchingConstraintFetchedMarkers(t *testing.T) {
+	requested := map[string]bool{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, ".txt") {
+				mu.Lock()
+				requested[r.URL.Path] = true
+				mu.Unlock()
+			}
+			switch r.URL.Path {
+			case "/stable.txt", "/stable-1.22.txt":
+				fmt.Fprintln(w, "v1.22.1")
+			case "/stable-1.21.txt":
+				fmt.Fprintln(w, "v1.21.4")
+			default:
+				fmt.Fprintln(w, "v1.20.10")
+			}
+		},
+	))
+	defer server.Close()
+	defer func(base string) { downloadURLBase = base }(downloadURLBase)
+	downloadURLBase = server.URL
+
+	res, err := LatestMatchingConstraint(">=1.21.0 <1.22.0", false)
+	require.Nil(t, err)
+	require.Equal(t, "v1.21.4", res)
+	require.Equal(t, map[string]bool{
+		"/stable.txt": true, "/stable-1.21.txt": true,
+	}, requested)
+}
+
+func TestConstraintAllowsMinor(t *testing.T) {
+	cases := map[string]struct {
+		constraint string
+		allowed    []uint64
+	}{
+		"Range":    {constraint: ">=1.20.0 <1.22.0", allowed: []uint64{20, 21}},
+		"Patch":    {constraint: ">1.20.10 <=1.22.0", allowed: []uint64{20, 21, 22}},
+		"Exact":    {constraint: "1.21.3", allowed: []uint64{21}},
+		"Excluded": {constraint: ">=1.21.0 !=1.21.3", allowed: []uint64{21, 22}},
+		"Below":    {constraint: "<1.21.0", allowed: []uint64{19, 20}},
+		"Or":       {constraint: "<1.20.0 || >2.0.0", allowed: []uint64{19}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			matches, err := semver.ParseRange(tc.constraint)
+			require.Nil(t, err)
+			allowed := []uint64{}
+			for minor := uint64(19); minor <= 22; minor++ {
+				if constraintAllowsMinor(tc.constraint, matches, 1, minor) {
+					allowed = append(allowed, minor)
+				}
+			}
+			require.Equal(t, tc.allowed, allowed)
+		})
+	}
+}
+
+func TestLatestMatchingConstraintServerError(t *testing.T) {
+	defer func(retry *RetryOptions) { defaultRetryOptions = retry }(defaultRetryOptions)
+	defaultRetryOptions = &RetryOptions{}
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/stable.txt", "/stable-1.1.txt":
+				fmt.Fprintln(w, "v1.1.2")
+			case "/stable-1.0.txt":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+	defer func(base string) { downloadURLBase = base }(downloadURLBase)
+	downloadURLBase = server.URL
+
+	res, err := LatestMatchingConstraint(">=1.0.0", false)
+	require.NotNil(t, err)
+	require.NotEqual(t, ErrVersionNotFound, errors.Cause(err))
+	require.Empty(t, res)
+}
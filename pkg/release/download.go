@@ -0,0 +1,188 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+// DownloadReleaseTarball downloads the kubernetes.tar.gz of the release
+// `version` from dl.k8s.io into `destDir` and returns its local path. The
+// tarball is verified against its published SHA256 checksum. A tarball which
+// already exists in `destDir` is kept if it matches the checksum, otherwise
+// it gets replaced. The download is written to a partial file of the version
+// first, which gets resumed by the next call if the download is interrupted
+// and the server supports it. In dry run mode, the path of the tarball is
+// returned without downloading it.
+func DownloadReleaseTarball(version, destDir string, opts *StageOptions) (string, error) {
+	tarballURL, err := ReleaseDownloadURL(version, kubernetesTar)
+	if err != nil {
+		return "", err
+	}
+
+	log := opts.logger()
+	dst := filepath.Join(destDir, kubernetesTar)
+	if opts.dryRun() {
+		log.Infof("Dry run: would download %s to %s", tarballURL, dst)
+		return dst, nil
+	}
+
+	checksumURL := tarballURL + ".sha256"
+	checksum, err := getPublishedChecksum(checksumURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "retrieving checksum of %s", tarballURL)
+	}
+	if checksum == "" {
+		return "", errors.Errorf("no checksum published at %s", checksumURL)
+	}
+
+	if info, err := os.Stat(dst); err == nil {
+		if err := VerifyTarballChecksum(dst, checksum); err == nil {
+			log.Infof("Tarball %s of %s is already downloaded", dst, version)
+			if progress := opts.progress(); progress != nil {
+				progress(info.Size(), info.Size())
+			}
+			return dst, nil
+		}
+		log.Infof("Replacing tarball %s, which is not the one of %s", dst, version)
+	}
+
+	if err := os.MkdirAll(destDir, os.FileMode(0755)); err != nil {
+		return "", errors.Wrapf(err, "creating destination directory %s", destDir)
+	}
+
+	partial := filepath.Join(destDir, kubernetesTar+"."+version+".partial")
+	if err := downloadPartial(tarballURL, partial, opts); err != nil {
+		return "", err
+	}
+
+	if err := VerifyTarballChecksum(partial, checksum); err != nil {
+		if removeErr := os.Remove(partial); removeErr != nil {
+			log.Warnf("Unable to remove download %s: %v", partial, removeErr)
+		}
+		return "", errors.Wrapf(err, "verifying download of %s", tarballURL)
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		return "", errors.Wrapf(err, "moving download %s to %s", partial, dst)
+	}
+	return dst, nil
+}
+
+// downloadPartial downloads `tarballURL` into the file `partial`. Existing
+// content of the file is considered as the start of the tarball, which gets
+// resumed if the server supports range requests.
+func downloadPartial(tarballURL, partial string, opts *StageOptions) error {
+	log, progress := opts.logger(), opts.progress()
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, tarballURL, nil)
+	if err != nil {
+		return errors.Wrapf(err, "creating request for %s", tarballURL)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := opts.httpClient().Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "an error occurred GET-ing %s", tarballURL)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	total := resp.ContentLength
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial download is at least as large as the remote tarball
+		size, err := remoteSize(resp)
+		if err != nil || size != offset {
+			log.Infof("Partial download %s does not match %s, restarting it", partial, tarballURL)
+			if err := os.Remove(partial); err != nil {
+				return errors.Wrapf(err, "removing partial download %s", partial)
+			}
+			return downloadPartial(tarballURL, partial, opts)
+		}
+		log.Infof("Partial download %s is already complete", partial)
+		if progress != nil {
+			progress(offset, size)
+		}
+		return nil
+
+	case resp.StatusCode == http.StatusPartialContent:
+		log.Infof("Resuming partial download %s at %d bytes", partial, offset)
+		flags = os.O_WRONLY | os.O_APPEND
+		if total >= 0 {
+			total += offset
+		}
+
+	default:
+		if err := checkStatus(resp, tarballURL); err != nil {
+			return err
+		}
+		if offset > 0 {
+			log.Infof("Server does not support resuming, restarting download %s", partial)
+		}
+		offset = 0
+	}
+
+	file, err := os.OpenFile(partial, flags, os.FileMode(0644))
+	if err != nil {
+		return errors.Wrapf(err, "opening file %s", partial)
+	}
+	defer file.Close()
+
+	log.Infof("Downloading %s to %s", tarballURL, partial)
+	var w io.Writer = file
+	if progress != nil {
+		w = util.NewProgressWriter(file, offset, total, progress)
+	}
+	written, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "downloading %s to %s", tarballURL, partial)
+	}
+	if total >= 0 && offset+written != total {
+		return errors.Errorf(
+			"incomplete download of %s: got %d of %d bytes", tarballURL, offset+written, total,
+		)
+	}
+
+	return errors.Wrapf(file.Close(), "closing file %s", partial)
+}
+
+// remoteSize returns the complete size of the resource from the
+// Content-Range header of a range response, like "bytes */1234".
+func remoteSize(resp *http.Response) (int64, error) {
+	contentRange := resp.Header.Get("Content-Range")
+	sizeStr := contentRange[strings.LastIndex(contentRange, "/")+1:]
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parsing content range %q", contentRange)
+	}
+	return size, nil
+}
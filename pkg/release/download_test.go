@@ -0,0 +1,178 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadReleaseTarball(t *testing.T) {
+	content := strings.Repeat("test", 1024)
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	ranges := []string{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1.18.3/kubernetes.tar.gz":
+				ranges = append(ranges, r.Header.Get("Range"))
+				http.ServeContent(
+					w, r, kubernetesTar, time.Time{}, bytes.NewReader([]byte(content)),
+				)
+			case "/v1.18.2/kubernetes.tar.gz", "/v1.18.1/kubernetes.tar.gz":
+				// No support for range requests
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				fmt.Fprint(w, content)
+			case "/v1.18.3/kubernetes.tar.gz.sha256", "/v1.18.2/kubernetes.tar.gz.sha256":
+				fmt.Fprintln(w, checksum)
+			case "/v1.18.1/kubernetes.tar.gz.sha256":
+				fmt.Fprintln(w, strings.Repeat("0", 64))
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+	defer func(base string) { downloadURLBase = base }(downloadURLBase)
+	downloadURLBase = server.URL
+
+	cases := map[string]struct {
+		version  string
+		existing string
+		partial  string
+		ranges   []string
+		rErr     bool
+	}{
+		"Download": {
+			version: "v1.18.3",
+			ranges:  []string{""},
+		},
+		"AlreadyDownloaded": {
+			version:  "v1.18.3",
+			existing: content,
+			ranges:   []string{},
+		},
+		"OtherVersionDownloaded": {
+			version:  "v1.18.3",
+			existing: strings.Repeat("test", 1025),
+			ranges:   []string{""},
+		},
+		"Resume": {
+			version: "v1.18.3",
+			partial: content[:100],
+			ranges:  []string{"bytes=100-"},
+		},
+		"CompletePartial": {
+			version: "v1.18.3",
+			partial: content,
+			ranges:  []string{fmt.Sprintf("bytes=%d-", len(content))},
+		},
+		"LargerPartial": {
+			version: "v1.18.3",
+			partial: content + "more",
+			ranges:  []string{fmt.Sprintf("bytes=%d-", len(content)+4), ""},
+		},
+		"NoResumeSupport": {
+			version: "v1.18.2",
+			partial: content[:100],
+			ranges:  []string{},
+		},
+		"ChecksumMismatch": {
+			version: "v1.18.1",
+			ranges:  []string{},
+			rErr:    true,
+		},
+		"NotFound": {
+			version: "v1.17.0",
+			ranges:  []string{},
+			rErr:    true,
+		},
+		"InvalidVersion": {
+			version: "wrong",
+			ranges:  []string{},
+			rErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+			destDir := filepath.Join(baseTmpDir, "dest")
+			partial := filepath.Join(destDir, kubernetesTar+"."+tc.version+".partial")
+			require.Nil(t, os.MkdirAll(destDir, os.ModePerm))
+			for file, content := range map[string]string{
+				filepath.Join(destDir, kubernetesTar): tc.existing,
+				partial:                               tc.partial,
+			} {
+				if content != "" {
+					require.Nil(t, ioutil.WriteFile(file, []byte(content), os.FileMode(0644)))
+				}
+			}
+			ranges = []string{}
+
+			var read, total int64
+			res, err := DownloadReleaseTarball(tc.version, destDir, &StageOptions{
+				Progress: func(r, t int64) { read, total = r, t },
+			})
+			require.Equal(t, tc.ranges, ranges)
+			require.Equal(t, tc.rErr, err != nil)
+			_, statErr := os.Stat(partial)
+			require.True(t, os.IsNotExist(statErr))
+			if tc.rErr {
+				return
+			}
+
+			require.Equal(t, filepath.Join(destDir, kubernetesTar), res)
+			downloaded, err := ioutil.ReadFile(res)
+			require.Nil(t, err)
+			require.Equal(t, content, string(downloaded))
+			require.EqualValues(t, len(content), read)
+			require.EqualValues(t, len(content), total)
+		})
+	}
+
+	// A dry run neither downloads nor writes anything
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+	destDir := filepath.Join(baseTmpDir, "dest")
+	ranges = []string{}
+
+	res, err := DownloadReleaseTarball("v1.18.3", destDir, &StageOptions{DryRun: true})
+	require.Nil(t, err)
+	require.Equal(t, filepath.Join(destDir, kubernetesTar), res)
+	require.Empty(t, ranges)
+	_, err = os.Stat(destDir)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = DownloadReleaseTarball("wrong", destDir, &StageOptions{DryRun: true})
+	require.NotNil(t, err)
+}
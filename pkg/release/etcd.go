@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// etcdManifest is the path of the etcd static pod manifest below GCEPath.
+const etcdManifest = "manifests/etcd.manifest"
+
+var (
+	// etcdVersionRE matches the default etcd version of the manifest, for
+	// example {{ pillar.get('etcd_version', '3.4.9') }}.
+	etcdVersionRE = regexp.MustCompile(`pillar\.get\('etcd_version',\s*'([^']+)'\)`)
+
+	// etcdImageTagRE matches the default etcd image tag of the manifest, for
+	// example {{ pillar.get('etcd_docker_tag', '3.4.9-1') }}.
+	etcdImageTagRE = regexp.MustCompile(`pillar\.get\('etcd_docker_tag',\s*'([^']+)'\)`)
+
+	etcdVersionFormatRE = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-[0-9]+)?$`)
+)
+
+// GetRequiredEtcdVersion returns the etcd version pinned by the staged GCE
+// cluster scripts in the build output directory `workDir`. The etcd_version
+// of the manifest is preferred over its image tag.
+func GetRequiredEtcdVersion(workDir string) (string, error) {
+	manifest := filepath.Join(workDir, GCEPath, filepath.FromSlash(etcdManifest))
+	content, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading etcd manifest %s", manifest)
+	}
+
+	for _, re := range []*regexp.Regexp{etcdVersionRE, etcdImageTagRE} {
+		match := re.FindSubmatch(content)
+		if match == nil {
+			continue
+		}
+
+		version := string(match[1])
+		if !etcdVersionFormatRE.MatchString(version) {
+			return "", errors.Errorf(
+				"invalid etcd version %q pinned in %s", version, manifest,
+			)
+		}
+		return version, nil
+	}
+
+	return "", errors.Errorf("unable to find etcd version pin in %s", manifest)
+}
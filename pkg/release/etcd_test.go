@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRequiredEtcdVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		manifest string
+		want     want
+	}{
+		"Version": {
+			manifest: `{
+"image": "{{ pillar.get('etcd_docker_repository', 'k8s.gcr.io/etcd') }}:{{ pillar.get('etcd_docker_tag', '3.4.9-1') }}",
+"env": [{"name": "TARGET_VERSION", "value": "{{ pillar.get('etcd_version', '3.4.9') }}"}]
+}`,
+			want: want{r: "3.4.9"},
+		},
+		"ImageTagOnly": {
+			manifest: `"image": "k8s.gcr.io/etcd:{{ pillar.get('etcd_docker_tag', '3.4.9-1') }}"`,
+			want:     want{r: "3.4.9-1"},
+		},
+		"InvalidVersion": {
+			manifest: `"value": "{{ pillar.get('etcd_version', 'latest') }}"`,
+			want:     want{rErr: true},
+		},
+		"NoPin": {
+			manifest: `"image": "k8s.gcr.io/etcd"`,
+			want:     want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+
+			manifest := filepath.Join(baseTmpDir, GCEPath, etcdManifest)
+			require.Nil(t, os.MkdirAll(filepath.Dir(manifest), os.ModePerm))
+			require.Nil(t, ioutil.WriteFile(
+				manifest, []byte(tc.manifest), os.FileMode(0644),
+			))
+
+			res, err := GetRequiredEtcdVersion(baseTmpDir)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestGetRequiredEtcdVersionMissingManifest(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	_, err = GetRequiredEtcdVersion(baseTmpDir)
+	require.NotNil(t, err)
+}
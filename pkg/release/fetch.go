@@ -0,0 +1,472 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// KubeVersionOptions are the options for GetKubeVersionWithOptions.
+type KubeVersionOptions struct {
+	// OriginURL is the location of the marker on the origin behind the CDN,
+	// for example
+	// https://storage.googleapis.com/kubernetes-release/release/stable.txt.
+	// If set, the marker is fetched from the origin whenever the CDN result
+	// is older than the origin one.
+	OriginURL string
+
+	// PreferOrigin skips the CDN and fetches the marker from OriginURL.
+	PreferOrigin bool
+
+	// ExperimentalMarkers enables trying the experimental variant of the
+	// marker first, which lives in the experimental directory next to it, for
+	// example https://dl.k8s.io/release/experimental/latest.txt. The standard
+	// marker is used if the experimental one is not available.
+	ExperimentalMarkers bool
+
+	// Client is the HTTP client used for fetching the markers, which allows
+	// to configure timeouts, proxies and TLS settings. A client with a 30
+	// second timeout is used if not set.
+	Client *http.Client
+
+	// Retry configures the retries of failed fetches. Three retries with
+	// exponential backoff are done if not set.
+	Retry *RetryOptions
+
+	// Logger receives the log messages of the fetch, which allows to add
+	// fields like request IDs or to silence them by raising its level. The
+	// global logrus logger is used if not set.
+	Logger logrus.FieldLogger
+
+	// NoTrim keeps the content of the marker as it is. By default, the
+	// whitespace surrounding the content gets removed, like the trailing
+	// newline of the marker and any empty lines following it. Setting NoTrim
+	// preserves content after the version like additional lines, in which
+	// case only the version on the first line gets converted to SemVer.
+	NoTrim bool
+
+	// MarkerDir is a local directory containing version markers, which are
+	// used instead of fetching them, for example a bundle or a copy of the
+	// release artifacts in an air-gapped environment loaded by
+	// LoadMarkerBundle. The markers are stored under the CacheKey of their
+	// URL, which keeps markers of the same path on different hosts apart.
+	// Markers missing in there are fetched. It is disabled if empty.
+	MarkerDir string
+
+	// MarkerMaxAge is the time after which a marker of the MarkerDir is
+	// outdated and gets fetched instead. Zero keeps using the markers
+	// forever.
+	MarkerMaxAge time.Duration
+
+	// KubecrossRepoPath is a local kubernetes/kubernetes checkout, whose
+	// kube-cross version is returned by GetKubecrossVersionWithOptions
+	// instead of fetching it from GitHub. It overrides the version of all
+	// requested branches, regardless of the branch checked out, so it has to
+	// match the branches being built. The branches are fetched if the
+	// checkout has no kube-cross version. It is disabled if empty.
+	KubecrossRepoPath string
+
+	// Concurrency is the number of versions looked up at once by functions
+	// resolving many of them, like GetCIKubeVersionsForBranchesWithOptions.
+	// It is capped at MaxParallelism, which is also the default if not set.
+	Concurrency int
+}
+
+// RetryOptions configure how often and when failed fetches are retried. Only
+// network errors and server side (5xx) errors are retried.
+type RetryOptions struct {
+	// MaxRetries is the number of retries after the first attempt. Zero
+	// disables retries.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry, which gets doubled for
+	// every further retry.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between two attempts.
+	MaxDelay time.Duration
+}
+
+const (
+	// experimentalMarkerDir is the directory containing the experimental
+	// variant of a marker.
+	experimentalMarkerDir = "experimental"
+
+	defaultHTTPTimeout = 30 * time.Second
+)
+
+var (
+	// defaultHTTPClient is used to fetch markers if no client is configured.
+	defaultHTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+
+	// defaultRetryOptions are used if no retries are configured.
+	defaultRetryOptions = &RetryOptions{
+		MaxRetries: 3,
+		BaseDelay:  time.Second,
+		MaxDelay:   10 * time.Second,
+	}
+)
+
+// httpClient returns the configured client of the options or the
+// defaultHTTPClient.
+func (o *KubeVersionOptions) httpClient() *http.Client {
+	if o == nil || o.Client == nil {
+		return defaultHTTPClient
+	}
+	return o.Client
+}
+
+// retryOptions returns the configured retries of the options or the
+// defaultRetryOptions.
+func (o *KubeVersionOptions) retryOptions() *RetryOptions {
+	if o == nil || o.Retry == nil {
+		return defaultRetryOptions
+	}
+	return o.Retry
+}
+
+// trim returns false if the options disable trimming the marker content.
+func (o *KubeVersionOptions) trim() bool {
+	return o == nil || !o.NoTrim
+}
+
+// logger returns the configured logger of the options or the global logrus
+// logger.
+func (o *KubeVersionOptions) logger() logrus.FieldLogger {
+	if o == nil || o.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return o.Logger
+}
+
+// concurrency returns the configured concurrency of the options, which is
+// limited to MaxParallelism and defaults to it.
+func (o *KubeVersionOptions) concurrency() int {
+	limit := MaxParallelism()
+	if o == nil || o.Concurrency <= 0 || o.Concurrency > limit {
+		return limit
+	}
+	return o.Concurrency
+}
+
+// delay returns the backoff before retry number `retry`, starting at zero.
+func (r *RetryOptions) delay(retry int) time.Duration {
+	delay := r.BaseDelay
+	for i := 0; i < retry && (r.MaxDelay <= 0 || delay < r.MaxDelay); i++ {
+		delay *= 2
+	}
+	if r.MaxDelay > 0 && delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+	return delay
+}
+
+// markerResponse is the content of a fetched marker together with its
+// modification time, which is zero if the server did not provide it.
+type markerResponse struct {
+	content      string
+	lastModified time.Time
+
+	// source is the URL the marker has been fetched from, which is empty if
+	// it has been read from the MarkerDir.
+	source string
+}
+
+// fetchMarker retrieves the content of the marker at `markerURL` like
+// resolveMarker.
+func fetchMarker(ctx context.Context, markerURL string, opts *KubeVersionOptions) (string, error) {
+	marker, err := resolveMarker(ctx, markerURL, opts)
+	if err != nil {
+		return "", err
+	}
+	return marker.content, nil
+}
+
+// resolveMarker retrieves the marker at `markerURL` like fetchRawMarker,
+// whose content gets trimmed unless disabled by the options.
+func resolveMarker(ctx context.Context, markerURL string, opts *KubeVersionOptions) (*markerResponse, error) {
+	marker, err := fetchRawMarker(ctx, markerURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.trim() {
+		marker.content = strings.TrimSpace(marker.content)
+	}
+	return marker, nil
+}
+
+// fetchRawMarker retrieves the unmodified marker at `markerURL`, consulting
+// the origin of the options if required. Markers available in the MarkerDir
+// of the options are not fetched at all.
+func fetchRawMarker(ctx context.Context, markerURL string, opts *KubeVersionOptions) (*markerResponse, error) {
+	log := opts.logger()
+	if version, file, ok := localMarker(markerURL, opts); ok {
+		log.Infof("Using marker %s from %s", markerURL, file)
+		return &markerResponse{content: version}, nil
+	}
+
+	client, retry := opts.httpClient(), opts.retryOptions()
+	get := func(u string) (*markerResponse, error) {
+		marker, err := getMarkerWithRetry(ctx, client, u, retry, log)
+		if err != nil {
+			return nil, err
+		}
+		marker.source = u
+		return marker, nil
+	}
+
+	if opts != nil && opts.ExperimentalMarkers {
+		experimentalURL, err := experimentalMarkerURL(markerURL)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("Experimental markers enabled, trying %s", experimentalURL)
+		experimental, err := get(experimentalURL)
+		if err == nil {
+			log.Infof("Using experimental marker %s", experimentalURL)
+			return experimental, nil
+		}
+		log.Infof(
+			"Experimental marker %s not available, using %s: %v",
+			experimentalURL, markerURL, err,
+		)
+	}
+
+	if opts == nil || opts.OriginURL == "" {
+		marker, err := get(markerURL)
+		if err != nil {
+			return nil, err
+		}
+		return marker, nil
+	}
+
+	if opts.PreferOrigin {
+		log.Infof("Bypassing the CDN, using origin %s", opts.OriginURL)
+		origin, err := get(opts.OriginURL)
+		if err != nil {
+			return nil, err
+		}
+		return origin, nil
+	}
+
+	cdn, err := get(markerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	originModified, err := headLastModified(ctx, client, opts.OriginURL)
+	if err != nil {
+		log.Warnf("Unable to check origin %s, using CDN result: %v", opts.OriginURL, err)
+		return cdn, nil
+	}
+
+	if !cdn.lastModified.IsZero() && originModified.After(cdn.lastModified) {
+		log.Infof(
+			"CDN marker %s is stale (modified %s, origin %s), using origin %s",
+			markerURL, cdn.lastModified, originModified, opts.OriginURL,
+		)
+		origin, err := get(opts.OriginURL)
+		if err != nil {
+			return nil, err
+		}
+		return origin, nil
+	}
+
+	return cdn, nil
+}
+
+// experimentalMarkerURL returns the URL of the experimental variant of the
+// marker at `markerURL`.
+func experimentalMarkerURL(markerURL string) (string, error) {
+	u, err := url.Parse(markerURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing marker URL %s", markerURL)
+	}
+	dir, name := path.Split(u.Path)
+	u.Path = path.Join(dir, experimentalMarkerDir, name)
+	return u.String(), nil
+}
+
+// getMarkerWithRetry calls getMarker and retries it according to `retry` if
+// it fails because of a network or server side error. The last error is
+// returned if all attempts fail. Retries are logged to `log`.
+func getMarkerWithRetry(
+	ctx context.Context, client *http.Client, url string, retry *RetryOptions,
+	log logrus.FieldLogger,
+) (*markerResponse, error) {
+	for attempt := 0; ; attempt++ {
+		marker, err := getMarker(ctx, client, url)
+		if err == nil {
+			return marker, nil
+		}
+		if ctx.Err() != nil || !isRetryable(err) || attempt >= retry.MaxRetries {
+			return nil, err
+		}
+
+		delay := retry.delay(attempt)
+		log.Warnf(
+			"Fetching %s failed (attempt %d of %d), retrying in %v: %v",
+			url, attempt+1, retry.MaxRetries+1, delay, err,
+		)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isRetryable returns false if `err` will not change when retrying, like a
+// 404 Not Found response, an unknown host or an invalid URL.
+func isRetryable(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case *statusError:
+		return cause.code >= 500
+
+	case *url.Error:
+		if opErr, ok := cause.Err.(*net.OpError); ok {
+			if dnsErr, ok := opErr.Err.(*net.DNSError); ok && dnsErr.IsNotFound {
+				return false
+			}
+		}
+		_, isNetErr := cause.Err.(net.Error)
+		return isNetErr
+	}
+	return true
+}
+
+// getMarker does a GET request on `url` using `client` and returns the body
+// along with its Last-Modified header. The request is aborted if `ctx`
+// gets cancelled.
+func getMarker(ctx context.Context, client *http.Client, url string) (*markerResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating request for %s", url)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "an error occurred GET-ing %s", url)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, url); err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not handle the response body for %s", url)
+	}
+
+	return &markerResponse{
+		content:      string(body),
+		lastModified: lastModified(resp),
+	}, nil
+}
+
+// headLastModified returns the Last-Modified header of `url` without
+// downloading its content.
+func headLastModified(ctx context.Context, client *http.Client, url string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "creating request for %s", url)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "an error occurred HEAD-ing %s", url)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, url); err != nil {
+		return time.Time{}, err
+	}
+	return lastModified(resp), nil
+}
+
+// statusError is returned for responses without a 2xx status code.
+type statusError struct {
+	url  string
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP status not OK (%v) for %s", e.code, e.url)
+}
+
+func checkStatus(resp *http.Response, url string) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.WithStack(&statusError{url: url, code: resp.StatusCode})
+	}
+	return nil
+}
+
+func lastModified(resp *http.Response) time.Time {
+	modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+	return modified
+}
+
+// CacheKey returns a stable key for caching the result of a version fetch of
+// the marker at `markerURL` from `downloadHost`. Every combination of the
+// parameters results in a distinct key, which is safe to be used as file
+// name.
+func CacheKey(markerURL string, useSemver bool, downloadHost string) string {
+	hasher := sha256.New()
+	for _, part := range []string{
+		markerURL, fmt.Sprint(useSemver), strings.ToLower(downloadHost),
+	} {
+		// Length prefixes avoid collisions like ("ab", "c") and ("a", "bc")
+		fmt.Fprintf(hasher, "%d:%s;", len(part), part)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// ValidateHTTPSConfiguration checks up front that the configured download
+// locations of the package, like the GCSURLBase, and all `additional` URLs,
+// for example the OriginURL of a KubeVersionOptions or a mirror, use HTTPS.
+// The returned error lists all URLs that do not.
+func ValidateHTTPSConfiguration(additional ...string) error {
+	insecure := []string{}
+	for _, u := range append([]string{downloadURLBase, GCSURLBase}, additional...) {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			insecure = append(insecure, u)
+		}
+	}
+
+	if len(insecure) > 0 {
+		return errors.Errorf(
+			"URLs not using HTTPS: %s", strings.Join(insecure, ", "),
+		)
+	}
+	return nil
+}
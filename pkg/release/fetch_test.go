@@ -0,0 +1,830 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// newMarkerServer returns a test server serving `content` for every request,
+// optionally with the provided modification time.
+func newMarkerServer(content string, modified time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if !modified.IsZero() {
+				w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+			}
+			fmt.Fprintln(w, content)
+		},
+	))
+}
+
+func TestGetKubeVersionWithOptions(t *testing.T) {
+	now := time.Now()
+	older := now.Add(-time.Hour)
+
+	testcases := []struct {
+		name         string
+		cdnModified  time.Time
+		origModified time.Time
+		preferOrigin bool
+		expected     string
+	}{
+		{
+			name:         "CDN up to date",
+			cdnModified:  now,
+			origModified: now,
+			expected:     "v1.18.2",
+		},
+		{
+			name:         "CDN stale",
+			cdnModified:  older,
+			origModified: now,
+			expected:     "v1.18.3",
+		},
+		{
+			name:     "No modification times",
+			expected: "v1.18.2",
+		},
+		{
+			name:         "Prefer origin",
+			cdnModified:  now,
+			origModified: now,
+			preferOrigin: true,
+			expected:     "v1.18.3",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+
+		cdn := newMarkerServer("v1.18.2", tc.cdnModified)
+		origin := newMarkerServer("v1.18.3", tc.origModified)
+
+		actual, err := GetKubeVersionWithOptions(context.Background(), cdn.URL, false, &KubeVersionOptions{
+			OriginURL:    origin.URL,
+			PreferOrigin: tc.preferOrigin,
+		})
+		require.Nil(t, err)
+		require.Equal(t, tc.expected, actual)
+
+		cdn.Close()
+		origin.Close()
+	}
+}
+
+func TestGetKubeVersionWithOptionsNoOrigin(t *testing.T) {
+	cdn := newMarkerServer("v1.18.2", time.Time{})
+	defer cdn.Close()
+
+	actual, err := GetKubeVersionWithOptions(context.Background(), cdn.URL, true, &KubeVersionOptions{})
+	require.Nil(t, err)
+	require.Equal(t, "1.18.2", actual)
+}
+
+func TestGetKubeVersionWithOptionsExperimental(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/release/experimental/latest.txt":
+				fmt.Fprintln(w, "v1.19.0-alpha.1")
+			case "/release/latest.txt", "/release/stable.txt":
+				fmt.Fprintln(w, "v1.18.3")
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+
+	testcases := []struct {
+		name         string
+		marker       string
+		experimental bool
+		expected     string
+	}{
+		{
+			name:     "disabled",
+			marker:   "/release/latest.txt",
+			expected: "v1.18.3",
+		},
+		{
+			name:         "enabled",
+			marker:       "/release/latest.txt",
+			experimental: true,
+			expected:     "v1.19.0-alpha.1",
+		},
+		{
+			name:         "experimental marker absent",
+			marker:       "/release/stable.txt",
+			experimental: true,
+			expected:     "v1.18.3",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+
+		actual, err := GetKubeVersionWithOptions(context.Background(), server.URL+tc.marker, false, &KubeVersionOptions{
+			ExperimentalMarkers: tc.experimental,
+		})
+		require.Nil(t, err)
+		require.Equal(t, tc.expected, actual)
+	}
+}
+
+func TestGetKubeVersionWithClient(t *testing.T) {
+	cdn := newMarkerServer(" v1.18.2\n", time.Time{})
+	defer cdn.Close()
+
+	for _, useSemver := range []bool{false, true} {
+		expected, err := GetKubeVersion(cdn.URL, useSemver)
+		require.Nil(t, err)
+
+		actual, err := GetKubeVersionWithClient(cdn.URL, useSemver, &http.Client{})
+		require.Nil(t, err)
+		require.Equal(t, expected, actual)
+	}
+
+	defer func(retry *RetryOptions) { defaultRetryOptions = retry }(defaultRetryOptions)
+	defaultRetryOptions = &RetryOptions{MaxRetries: 1, BaseDelay: time.Millisecond}
+
+	slow := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(500 * time.Millisecond)
+			fmt.Fprintln(w, "v1.18.2")
+		},
+	))
+	defer slow.Close()
+
+	_, err := GetKubeVersionWithClient(slow.URL, false, &http.Client{
+		Timeout: 50 * time.Millisecond,
+	})
+	require.NotNil(t, err)
+}
+
+func TestGetKubeVersionBoth(t *testing.T) {
+	for _, version := range []string{"v1.18.2", "v1.19.0-beta.1.58+e19c4a2b1ec777"} {
+		server := newMarkerServer(version+"\n", time.Time{})
+		defer server.Close()
+
+		original, sem, err := GetKubeVersionBoth(server.URL)
+		require.Nil(t, err)
+		require.Equal(t, version, original)
+
+		semverVersion, err := GetKubeVersion(server.URL, true)
+		require.Nil(t, err)
+		require.Equal(t, semverVersion, sem.String())
+	}
+
+	invalid := newMarkerServer("wrong", time.Time{})
+	defer invalid.Close()
+	_, _, err := GetKubeVersionBoth(invalid.URL)
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrInvalidVersion))
+}
+
+func TestGetKubeVersionInvalidVersion(t *testing.T) {
+	invalid := newMarkerServer("<html>wrong</html>", time.Time{})
+	defer invalid.Close()
+
+	_, err := GetKubeVersion(invalid.URL, true)
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrInvalidVersion))
+	require.Equal(t, ErrInvalidVersion, errors.Cause(err))
+	require.Contains(t, err.Error(), invalid.URL)
+	require.Contains(t, err.Error(), "<html>wrong</html>")
+
+	// Network errors are no invalid versions
+	invalid.Close()
+	_, err = GetKubeVersionWithOptions(context.Background(), invalid.URL, true, &KubeVersionOptions{
+		Retry: &RetryOptions{},
+	})
+	require.NotNil(t, err)
+	require.False(t, errors.Is(err, ErrInvalidVersion))
+}
+
+func TestGetKubeVersionFromMirrors(t *testing.T) {
+	defer func(retry *RetryOptions) { defaultRetryOptions = retry }(defaultRetryOptions)
+	defaultRetryOptions = &RetryOptions{}
+
+	mirror := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/release/stable.txt" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintln(w, "v1.18.3")
+		},
+	))
+	defer mirror.Close()
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	defer func(mirrors []string) { DefaultMirrors = mirrors }(DefaultMirrors)
+	DefaultMirrors = []string{mirror.URL}
+
+	testcases := []struct {
+		name       string
+		markerPath string
+		mirrors    []string
+		expected   string
+		shouldErr  bool
+	}{
+		{
+			name:       "first mirror",
+			markerPath: "release/stable.txt",
+			mirrors:    []string{mirror.URL, down.URL},
+			expected:   "v1.18.3",
+		},
+		{
+			name:       "fallback mirrors",
+			markerPath: "/release/stable.txt",
+			mirrors:    []string{down.URL, empty.URL + "/", mirror.URL},
+			expected:   "v1.18.3",
+		},
+		{
+			name:       "default mirrors",
+			markerPath: "release/stable.txt",
+			expected:   "v1.18.3",
+		},
+		{
+			name:       "all mirrors fail",
+			markerPath: "release/stable.txt",
+			mirrors:    []string{down.URL, empty.URL},
+			shouldErr:  true,
+		},
+		{
+			name:       "missing marker",
+			markerPath: "release/latest.txt",
+			shouldErr:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+
+		actual, err := GetKubeVersionFromMirrors(tc.markerPath, tc.mirrors, false)
+		require.Equal(t, tc.shouldErr, err != nil)
+		require.Equal(t, tc.expected, actual)
+	}
+
+	actual, err := GetStableReleaseKubeVersion(false)
+	require.Nil(t, err)
+	require.Equal(t, "v1.18.3", actual)
+}
+
+func TestGetCIKubeVersionResolved(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/ci/latest.txt":
+				fmt.Fprintln(w, "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7")
+			case "/ci/latest-1.18.txt":
+				fmt.Fprintln(w, "v1.18.4-rc.0.12+f1a2b3c4d5e6f7")
+			case "/ci/experimental/latest-1.18.txt":
+				fmt.Fprintln(w, "v1.18.4-rc.0.13+a1b2c3d4e5f6a7")
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+	defer func(base string) { ciURLBase = base }(ciURLBase)
+	ciURLBase = server.URL + "/ci"
+
+	res, err := GetCIKubeVersionResolved("release-1.18", true)
+	require.Nil(t, err)
+	require.Equal(t, &ResolvedVersion{
+		Version:   "1.18.4-rc.0.12+f1a2b3c4d5e6f7",
+		MarkerURL: server.URL + "/ci/latest-1.18.txt",
+	}, res)
+
+	res, err = GetLatestCIKubeVersionResolved(false)
+	require.Nil(t, err)
+	require.Equal(t, &ResolvedVersion{
+		Version:   "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7",
+		MarkerURL: server.URL + "/ci/latest.txt",
+	}, res)
+
+	// The URL of the variant of the marker in use is reported
+	res, err = resolveKubeVersion(context.Background(), server.URL+"/ci/latest-1.18.txt", false,
+		&KubeVersionOptions{ExperimentalMarkers: true},
+	)
+	require.Nil(t, err)
+	require.Equal(t, server.URL+"/ci/experimental/latest-1.18.txt", res.MarkerURL)
+
+	// No URL is reported without a fetch
+	markerDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, markerDir)
+	serverURL, err := url.Parse(server.URL)
+	require.Nil(t, err)
+	localFile := markerDirFile(markerDir, serverURL.Host, "ci/latest.txt")
+	require.Nil(t, ioutil.WriteFile(localFile, []byte("v1.20.0-alpha.0.1+a1b2c3d4e5f6a7\n"), os.FileMode(0644)))
+	res, err = resolveKubeVersion(context.Background(), server.URL+"/ci/latest.txt", false,
+		&KubeVersionOptions{MarkerDir: markerDir},
+	)
+	require.Nil(t, err)
+	require.Equal(t, &ResolvedVersion{Version: "v1.20.0-alpha.0.1+a1b2c3d4e5f6a7"}, res)
+
+	const overrideEnv = "TEST_K8S_VERSION"
+	KubeVersionOverrideEnv = overrideEnv
+	defer func() {
+		KubeVersionOverrideEnv = ""
+		os.Unsetenv(overrideEnv)
+	}()
+	require.Nil(t, os.Setenv(overrideEnv, "v1.18.5"))
+	res, err = GetCIKubeVersionResolved("release-1.18", false)
+	require.Nil(t, err)
+	require.Equal(t, &ResolvedVersion{Version: "v1.18.5"}, res)
+	KubeVersionOverrideEnv = ""
+
+	_, err = GetCIKubeVersionResolved("release-1.17", false)
+	require.NotNil(t, err)
+
+	_, err = GetCIKubeVersionResolved("realese-1.18", false)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "invalid branch")
+}
+
+func TestGetCIKubeVersionsForBranches(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			switch r.URL.Path {
+			case "/latest.txt":
+				fmt.Fprintln(w, "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7")
+			case "/latest-1.19.txt":
+				fmt.Fprintln(w, "v1.19.0-beta.1.58+e19c4a2b1ec777")
+			case "/latest-1.18.txt":
+				fmt.Fprintln(w, "v1.18.4-rc.0.12+f1a2b3c4d5e6f7")
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+	defer func(base string) { ciURLBase = base }(ciURLBase)
+	ciURLBase = server.URL
+	defer func(retry *RetryOptions) { defaultRetryOptions = retry }(defaultRetryOptions)
+	defaultRetryOptions = &RetryOptions{}
+
+	res, err := GetCIKubeVersionsForBranchesWithOptions(
+		context.Background(), []string{"master", "release-1.19", "release-1.18"}, false,
+		&KubeVersionOptions{Concurrency: 2},
+	)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"master":       "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7",
+		"release-1.19": "v1.19.0-beta.1.58+e19c4a2b1ec777",
+		"release-1.18": "v1.18.4-rc.0.12+f1a2b3c4d5e6f7",
+	}, res)
+	require.True(t, maxRunning <= 2)
+
+	// The concurrency is capped at the parallelism of the package
+	defer SetMaxParallelism(0)
+	SetMaxParallelism(1)
+	maxRunning = 0
+	_, err = GetCIKubeVersionsForBranchesWithOptions(
+		context.Background(), []string{"master", "release-1.19", "release-1.18"}, false,
+		&KubeVersionOptions{Concurrency: 2},
+	)
+	require.Nil(t, err)
+	require.Equal(t, 1, maxRunning)
+	SetMaxParallelism(0)
+
+	// Failed lookups do not abort the other ones
+	res, err = GetCIKubeVersionsForBranches(
+		[]string{"release-1.17", "release-1.18", "wrong"}, true,
+	)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "release-1.17: ")
+	require.Contains(t, err.Error(), "wrong: ")
+	require.Equal(t, map[string]string{
+		"release-1.18": "1.18.4-rc.0.12+f1a2b3c4d5e6f7",
+	}, res)
+
+	branchErrs := BranchErrors{}
+	require.True(t, errors.As(err, &branchErrs))
+	require.Len(t, branchErrs, 2)
+	require.Contains(t, branchErrs["wrong"].Error(), "invalid branch")
+	require.False(t, errors.Is(err, ErrInvalidVersion))
+
+	// The causes of the branches survive
+	server.Config.Handler = http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "<html></html>")
+		},
+	)
+	_, err = GetCIKubeVersionsForBranches([]string{"release-1.18"}, true)
+	require.True(t, errors.Is(err, ErrInvalidVersion))
+}
+
+func TestGetCIKubeVersionForMarker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/ci/latest-fast.txt" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintln(w, "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7")
+		},
+	))
+	defer server.Close()
+	defer func(base string) { ciURLBase = base }(ciURLBase)
+	ciURLBase = server.URL + "/ci"
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		marker string
+		want   want
+	}{
+		"Fast": {
+			marker: "latest-fast.txt",
+			want:   want{r: "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7"},
+		},
+		"NotExisting": {
+			marker: "latest-1.17.txt",
+			want:   want{rErr: true},
+		},
+		"NoTxtSuffix": {
+			marker: "latest-fast",
+			want:   want{rErr: true},
+		},
+		"OnlySuffix": {
+			marker: ".txt",
+			want:   want{rErr: true},
+		},
+		"Path": {
+			marker: "ci/latest-fast.txt",
+			want:   want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := GetCIKubeVersionForMarker(tc.marker, false)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestKubeVersionOptionsLogger(t *testing.T) {
+	server := newMarkerServer("v1.18.3", time.Time{})
+	defer server.Close()
+	defer func(mirrors []string) { DefaultMirrors = mirrors }(DefaultMirrors)
+	DefaultMirrors = []string{server.URL}
+	defer func(base string) { ciURLBase = base }(ciURLBase)
+	ciURLBase = server.URL
+	defer func(u string) { kubecrossRepoURL = u }(kubecrossRepoURL)
+	kubecrossRepoURL = server.URL + "/%s"
+	defer ClearKubecrossCache()
+	ClearKubecrossCache()
+
+	output := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(output)
+	opts := &KubeVersionOptions{Logger: logger}
+
+	res, err := GetStableReleaseKubeVersionWithOptions(context.Background(), false, opts)
+	require.Nil(t, err)
+	require.Equal(t, "v1.18.3", res)
+	require.Contains(t, output.String(), "Retrieving Kubernetes release version")
+	require.Contains(t, output.String(), server.URL)
+
+	// Raising the level silences the info messages
+	output.Reset()
+	logger.SetLevel(logrus.WarnLevel)
+	for _, fetch := range []func() (string, error){
+		func() (string, error) {
+			return GetStableReleaseKubeVersionWithOptions(context.Background(), false, opts)
+		},
+		func() (string, error) {
+			return GetStablePrereleaseKubeVersionWithOptions(context.Background(), false, opts)
+		},
+		func() (string, error) { return GetLatestCIKubeVersionWithOptions(context.Background(), false, opts) },
+		func() (string, error) {
+			return GetKubeVersionWithOptions(context.Background(), server.URL, false, opts)
+		},
+		func() (string, error) {
+			return GetCIKubeVersionWithOptions(context.Background(), "release-1.18", false, opts)
+		},
+		func() (string, error) {
+			return GetCIKubeVersionForMarkerWithOptions(context.Background(), "latest-fast.txt", false, opts)
+		},
+		func() (string, error) {
+			return GetKubecrossVersionWithOptions(context.Background(), opts, "release-1.18")
+		},
+	} {
+		res, err := fetch()
+		require.Nil(t, err)
+		require.Equal(t, "v1.18.3", res)
+	}
+	require.Empty(t, output.String())
+}
+
+func TestKubeVersionOptionsNoTrim(t *testing.T) {
+	server := newMarkerServer("v1.18.3\nbuild-date: 2020-05-20", time.Time{})
+	defer server.Close()
+
+	cases := map[string]struct {
+		useSemver bool
+		noTrim    bool
+		want      string
+		rErr      bool
+	}{
+		"Trimmed": {
+			want: "v1.18.3\nbuild-date: 2020-05-20",
+		},
+		"TrimmedSemver": {
+			useSemver: true,
+			rErr:      true,
+		},
+		"NoTrim": {
+			noTrim: true,
+			want:   "v1.18.3\nbuild-date: 2020-05-20\n",
+		},
+		"NoTrimSemver": {
+			useSemver: true,
+			noTrim:    true,
+			want:      "1.18.3\nbuild-date: 2020-05-20\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := GetKubeVersionWithOptions(
+				context.Background(), server.URL, tc.useSemver, &KubeVersionOptions{NoTrim: tc.noTrim},
+			)
+			require.Equal(t, tc.rErr, err != nil)
+			require.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestGetKubeVersionWithContextCancelled(t *testing.T) {
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			close(cancelled)
+		},
+	))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := GetKubeVersionWithContext(ctx, server.URL+"/stable.txt", false)
+	require.NotNil(t, err)
+
+	// The in-flight request got cancelled on the server side as well
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request has not been cancelled")
+	}
+}
+
+func TestVersionFetchesWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, fetch := range map[string]func() (string, error){
+		"Stable": func() (string, error) {
+			return GetStableReleaseKubeVersionWithContext(ctx, false)
+		},
+		"Prerelease": func() (string, error) {
+			return GetStablePrereleaseKubeVersionWithContext(ctx, false)
+		},
+		"LatestCI": func() (string, error) {
+			return GetLatestCIKubeVersionWithContext(ctx, false)
+		},
+		"CI": func() (string, error) {
+			return GetCIKubeVersionWithContext(ctx, "release-1.18", false)
+		},
+		"CIMarker": func() (string, error) {
+			return GetCIKubeVersionForMarkerWithContext(ctx, "latest-fast.txt", false)
+		},
+		"Kubecross": func() (string, error) {
+			return GetKubecrossVersionWithContext(ctx, "release-1.18", "master")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := fetch()
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), context.Canceled.Error())
+		})
+	}
+}
+
+func TestGetKubeVersionRetry(t *testing.T) {
+	testcases := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		expected     string
+		shouldErr    bool
+		wantAttempts int
+	}{
+		{
+			name:         "success",
+			statuses:     []int{http.StatusOK},
+			maxRetries:   3,
+			expected:     "v1.18.3",
+			wantAttempts: 1,
+		},
+		{
+			name: "transient errors",
+			statuses: []int{
+				http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK,
+			},
+			maxRetries:   3,
+			expected:     "v1.18.3",
+			wantAttempts: 3,
+		},
+		{
+			name: "giving up",
+			statuses: []int{
+				http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+				http.StatusInternalServerError, http.StatusOK,
+			},
+			maxRetries:   2,
+			shouldErr:    true,
+			wantAttempts: 3,
+		},
+		{
+			name:         "not found",
+			statuses:     []int{http.StatusNotFound, http.StatusOK},
+			maxRetries:   3,
+			shouldErr:    true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retries disabled",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusOK},
+			shouldErr:    true,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[attempts])
+				fmt.Fprintln(w, "v1.18.3")
+				attempts++
+			},
+		))
+
+		actual, err := GetKubeVersionWithOptions(context.Background(), server.URL, false, &KubeVersionOptions{
+			Retry: &RetryOptions{
+				MaxRetries: tc.maxRetries,
+				BaseDelay:  time.Millisecond,
+				MaxDelay:   2 * time.Millisecond,
+			},
+		})
+		require.Equal(t, tc.shouldErr, err != nil)
+		require.Equal(t, tc.expected, actual)
+		require.Equal(t, tc.wantAttempts, attempts)
+		if tc.shouldErr {
+			// The last error is returned
+			require.Contains(t, err.Error(), fmt.Sprint(tc.statuses[attempts-1]))
+		}
+
+		server.Close()
+	}
+}
+
+func TestGetKubeVersionRetryNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	))
+	server.Close()
+
+	start := time.Now()
+	_, err := GetKubeVersionWithOptions(context.Background(), server.URL, false, &KubeVersionOptions{
+		Retry: &RetryOptions{MaxRetries: 2, BaseDelay: 20 * time.Millisecond},
+	})
+	require.NotNil(t, err)
+
+	// Two retries with a delay of 20ms and 40ms
+	require.True(t, time.Since(start) >= 60*time.Millisecond)
+}
+
+func TestRetryOptionsDelay(t *testing.T) {
+	retry := &RetryOptions{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	require.Equal(t, time.Second, retry.delay(0))
+	require.Equal(t, 2*time.Second, retry.delay(1))
+	require.Equal(t, 4*time.Second, retry.delay(2))
+	require.Equal(t, 5*time.Second, retry.delay(3))
+	require.Equal(t, 5*time.Second, retry.delay(100))
+
+	unlimited := &RetryOptions{BaseDelay: time.Second}
+	require.Equal(t, 8*time.Second, unlimited.delay(3))
+}
+
+func TestKubeVersionOptionsConcurrency(t *testing.T) {
+	defer SetMaxParallelism(0)
+	SetMaxParallelism(4)
+
+	var opts *KubeVersionOptions
+	require.Equal(t, 4, opts.concurrency())
+	require.Equal(t, 4, (&KubeVersionOptions{}).concurrency())
+	require.Equal(t, 2, (&KubeVersionOptions{Concurrency: 2}).concurrency())
+	require.Equal(t, 4, (&KubeVersionOptions{Concurrency: 8}).concurrency())
+}
+
+func TestCacheKey(t *testing.T) {
+	const marker = "https://dl.k8s.io/release/stable.txt"
+	key := CacheKey(marker, false, "dl.k8s.io")
+
+	// Stable
+	require.Equal(t, key, CacheKey(marker, false, "dl.k8s.io"))
+	require.Equal(t, key, CacheKey(marker, false, "DL.K8S.IO"))
+	require.Len(t, key, 64)
+
+	// Distinct
+	keys := map[string]bool{key: true}
+	for _, other := range []string{
+		CacheKey(marker, true, "dl.k8s.io"),
+		CacheKey(marker, false, "storage.googleapis.com"),
+		CacheKey("https://dl.k8s.io/release/latest.txt", false, "dl.k8s.io"),
+		CacheKey(marker+"false", false, ""),
+		CacheKey("", false, "dl.k8s.io"),
+	} {
+		require.False(t, keys[other])
+		keys[other] = true
+	}
+}
+
+func TestValidateHTTPSConfiguration(t *testing.T) {
+	require.Nil(t, ValidateHTTPSConfiguration())
+	require.Nil(t, ValidateHTTPSConfiguration(
+		"https://storage.googleapis.com/kubernetes-release/release/stable.txt",
+	))
+
+	err := ValidateHTTPSConfiguration("http://mirror.local/release", "dl.k8s.io", "https://")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "http://mirror.local/release, dl.k8s.io, https://")
+
+	base := GCSURLBase
+	GCSURLBase = "http://storage.googleapis.com"
+	defer func() { GCSURLBase = base }()
+	require.NotNil(t, ValidateHTTPSConfiguration())
+}
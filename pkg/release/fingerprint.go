@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// FingerprintSkipList contains the patterns of artifacts which are not built
+// reproducibly and therefore ignored by BuildFingerprint. Patterns use the
+// syntax of path.Match and are matched against the artifact path relative to
+// the ReleaseTarsPath as well as its file name.
+var FingerprintSkipList = []string{}
+
+// BuildFingerprint returns a single hash summarizing the content of the
+// release artifacts in the build output directory `workDir`. It is computed
+// over the sorted artifact paths and their checksums, excluding the artifacts
+// of the FingerprintSkipList, which means that two identical builds result in
+// the same fingerprint.
+func BuildFingerprint(workDir string) (string, error) {
+	manifest, err := GenerateArtifactManifest(workDir)
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	for _, entry := range manifest.Artifacts {
+		skip, err := skipFingerprint(entry.Path)
+		if err != nil {
+			return "", err
+		}
+		if skip {
+			logrus.Debugf("Excluding %s from the build fingerprint", entry.Path)
+			continue
+		}
+		fmt.Fprintf(hasher, "%s  %s\n", entry.SHA256, entry.Path)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// skipFingerprint returns true if `artifact` matches a pattern of the
+// FingerprintSkipList.
+func skipFingerprint(artifact string) (bool, error) {
+	for _, pattern := range FingerprintSkipList {
+		for _, name := range []string{artifact, path.Base(artifact)} {
+			match, err := path.Match(pattern, name)
+			if err != nil {
+				return false, errors.Wrapf(err, "invalid skip pattern %q", pattern)
+			}
+			if match {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildFingerprint(t *testing.T) {
+	defer func(skipList []string) { FingerprintSkipList = skipList }(FingerprintSkipList)
+
+	fingerprint := func(files map[string]string) string {
+		baseTmpDir, err := ioutil.TempDir("", "")
+		require.Nil(t, err)
+		defer cleanupTmps(t, baseTmpDir)
+		writeTestArtifacts(t, baseTmpDir, files)
+
+		res, err := BuildFingerprint(baseTmpDir)
+		require.Nil(t, err)
+		return res
+	}
+
+	build := map[string]string{
+		"kubernetes.tar.gz":        "kubernetes",
+		"kubernetes.tar.gz.sha256": "ignored",
+		"bin/kubectl":              "kubectl",
+		"kubernetes-src.tar.gz":    "src",
+	}
+	reference := fingerprint(build)
+	require.Len(t, reference, 64)
+
+	// Identical builds
+	require.Equal(t, reference, fingerprint(build))
+
+	// Differing content
+	build["bin/kubectl"] = "changed"
+	changed := fingerprint(build)
+	require.NotEqual(t, reference, changed)
+
+	// Skipped artifacts do not influence the fingerprint
+	FingerprintSkipList = []string{"kubernetes-src.tar.gz"}
+	skipped := fingerprint(build)
+	build["kubernetes-src.tar.gz"] = "nondeterministic"
+	require.Equal(t, skipped, fingerprint(build))
+	FingerprintSkipList = []string{"bin/*"}
+	require.NotEqual(t, changed, fingerprint(build))
+
+	// Invalid patterns
+	FingerprintSkipList = []string{"["}
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+	writeTestArtifacts(t, baseTmpDir, build)
+	_, err = BuildFingerprint(baseTmpDir)
+	require.NotNil(t, err)
+
+	_, err = BuildFingerprint(filepath.Join(baseTmpDir, "notexisting"))
+	require.NotNil(t, err)
+}
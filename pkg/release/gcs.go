@@ -0,0 +1,281 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GCSPrefix is the scheme prefix of Google Cloud Storage URLs.
+	GCSPrefix = "gs://"
+
+	// CIBucketSuffix is the GetReleaseBucket suffix of the bucket CI builds
+	// are pushed to. Objects below its ci/ directory are subject to a
+	// retention lifecycle.
+	CIBucketSuffix = "dev"
+
+	ciObjectDir      = "ci"
+	releaseObjectDir = "release"
+)
+
+var (
+	// GCSURLBase is the HTTPS endpoint serving publicly readable GCS objects.
+	GCSURLBase = "https://storage.googleapis.com"
+
+	// ErrObjectNotFound is returned if a GCS object does not exist.
+	ErrObjectNotFound = errors.New("object not found")
+
+	// gcsClient reads the GCS objects, which is created on first use if it
+	// has not been set via SetGCSClient.
+	gcsClient = struct {
+		sync.Mutex
+		objects gcsObjects
+	}{}
+)
+
+// gcsObjects is the part of the GCS API used to read objects, which allows to
+// replace the storage client in tests.
+type gcsObjects interface {
+	NewReader(ctx context.Context, bucket, object string) (io.ReadCloser, error)
+	Attrs(ctx context.Context, bucket, object string) (*storage.ObjectAttrs, error)
+}
+
+// storageObjects are the gcsObjects of a storage client.
+type storageObjects struct {
+	client *storage.Client
+}
+
+func (s *storageObjects) NewReader(ctx context.Context, bucket, object string) (io.ReadCloser, error) {
+	return s.client.Bucket(bucket).Object(object).NewReader(ctx)
+}
+
+func (s *storageObjects) Attrs(ctx context.Context, bucket, object string) (*storage.ObjectAttrs, error) {
+	return s.client.Bucket(bucket).Object(object).Attrs(ctx)
+}
+
+// SetGCSClient sets the storage client used to read GCS objects, for example
+// one using specific credentials. By default, a client using the application
+// default credentials is created on first use, which is able to read the
+// private buckets the credentials have access to.
+func SetGCSClient(client *storage.Client) {
+	gcsClient.Lock()
+	defer gcsClient.Unlock()
+	gcsClient.objects = &storageObjects{client}
+}
+
+// getGCSObjects returns the gcsObjects of the configured storage client,
+// which gets created if not set yet.
+func getGCSObjects(ctx context.Context) (gcsObjects, error) {
+	gcsClient.Lock()
+	defer gcsClient.Unlock()
+	if gcsClient.objects == nil {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating GCS client")
+		}
+		gcsClient.objects = &storageObjects{client}
+	}
+	return gcsClient.objects, nil
+}
+
+// splitGCSPath returns the bucket and object name of the gs:// `gcsPath`.
+func splitGCSPath(gcsPath string) (bucket, object string, err error) {
+	if !strings.HasPrefix(gcsPath, GCSPrefix) {
+		return "", "", errors.Errorf("%s is not a GCS path", gcsPath)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(JoinGCSPath(gcsPath), GCSPrefix), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", errors.Errorf("%s does not point to an object", gcsPath)
+	}
+	return parts[0], parts[1], nil
+}
+
+// JoinGCSPath returns the gs:// URL of the object `elems` in `bucket`. The
+// bucket may be provided with or without the gs:// prefix and the object path
+// gets cleaned, for example JoinGCSPath("gs://bucket", "ci/", "v1.18.3")
+// returns gs://bucket/ci/v1.18.3.
+func JoinGCSPath(bucket string, elems ...string) string {
+	bucket = strings.Trim(strings.TrimPrefix(bucket, GCSPrefix), "/")
+	object := strings.TrimPrefix(path.Join(append([]string{"/"}, elems...)...), "/")
+	if object == "" {
+		return GCSPrefix + bucket
+	}
+	return GCSPrefix + path.Join(bucket, object)
+}
+
+// CIObjectPath returns the staging location of a CI build in the CI bucket of
+// GetReleaseBucket, for example
+// gs://kubernetes-release-dev/ci/v1.19.0-beta.1.58+e19c4a2b1ec777. CI builds
+// live below ci/, apart from the permanent release/ paths, so that lifecycle
+// rules only apply to them. An empty string is returned if `version` is not a
+// valid build version or the result would not be located below ci/.
+func CIObjectPath(version string) string {
+	valid, err := IsValidReleaseBuild(version)
+	if err != nil || !valid {
+		return ""
+	}
+
+	bucket := GetReleaseBucket(CIBucketSuffix)
+	ciPath := JoinGCSPath(bucket, ciObjectDir, version)
+	ciRoot := JoinGCSPath(bucket, ciObjectDir) + "/"
+	if !strings.HasPrefix(ciPath, ciRoot) || ciPath == ciRoot {
+		return ""
+	}
+	return ciPath
+}
+
+// GetReleaseArtifactGCSPath returns the location of `artifact` of the release
+// `version` in the release bucket, for example
+// gs://kubernetes-release/release/v1.20.3/kubernetes.tar.gz. The release
+// bucket is the one of GetReleaseBucket without suffix, which follows
+// 'RELEASE_BUCKET_PREFIX' if set.
+func GetReleaseArtifactGCSPath(version, artifact string) (string, error) {
+	if _, err := ParseReleaseVersion(version); err != nil {
+		return "", errors.Wrap(err, "invalid release version")
+	}
+	if strings.Trim(path.Clean("/"+artifact), "/") == "" {
+		return "", errors.New("artifact name must not be empty")
+	}
+
+	bucket := GetReleaseBucket("")
+	versionRoot := JoinGCSPath(bucket, releaseObjectDir) + "/" + version + "/"
+	artifactPath := JoinGCSPath(bucket, releaseObjectDir, version, artifact)
+	if !strings.HasPrefix(artifactPath, versionRoot) {
+		return "", errors.Errorf(
+			"artifact %s of %s is not located below %s", artifact, version, versionRoot,
+		)
+	}
+	return artifactPath, nil
+}
+
+// GCSObjectURL returns the public HTTPS URL of the object at the gs://
+// `gcsPath`, for example gs://bucket/ci/latest.txt becomes
+// https://storage.googleapis.com/bucket/ci/latest.txt.
+func GCSObjectURL(gcsPath string) (string, error) {
+	if !strings.HasPrefix(gcsPath, GCSPrefix) {
+		return "", errors.Errorf("%s is not a GCS path", gcsPath)
+	}
+	object := strings.TrimPrefix(JoinGCSPath(gcsPath), GCSPrefix)
+	if !strings.Contains(object, "/") {
+		return "", errors.Errorf("%s does not point to an object", gcsPath)
+	}
+
+	u, err := url.Parse(GCSURLBase)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse URL base")
+	}
+	u.Path = path.Join(u.Path, object)
+	return u.String(), nil
+}
+
+// ReadGCSObject returns the content of the object at the gs:// `gcsPath`,
+// which is read using the GCS client. The returned error wraps
+// ErrObjectNotFound if the object does not exist.
+func ReadGCSObject(gcsPath string) ([]byte, error) {
+	return readGCSObject(context.Background(), gcsPath)
+}
+
+func readGCSObject(ctx context.Context, gcsPath string) ([]byte, error) {
+	bucket, object, err := splitGCSPath(gcsPath)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := getGCSObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := objects.NewReader(ctx, bucket, object)
+	if err == storage.ErrObjectNotExist {
+		return nil, errors.Wrap(ErrObjectNotFound, gcsPath)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", gcsPath)
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading content of %s", gcsPath)
+	}
+	return content, nil
+}
+
+// GCSObjectExists returns true if the object at the gs:// `gcsPath` exists,
+// without downloading its content.
+func GCSObjectExists(gcsPath string) (bool, error) {
+	_, err := gcsObjectAttrs(context.Background(), gcsPath)
+	if errors.Cause(err) == ErrObjectNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// gcsObjectAttrs returns the attributes of the object at the gs:// `gcsPath`.
+// The returned error wraps ErrObjectNotFound if the object does not exist.
+func gcsObjectAttrs(ctx context.Context, gcsPath string) (*storage.ObjectAttrs, error) {
+	bucket, object, err := splitGCSPath(gcsPath)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := getGCSObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := objects.Attrs(ctx, bucket, object)
+	if err == storage.ErrObjectNotExist {
+		return nil, errors.Wrap(ErrObjectNotFound, gcsPath)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading attributes of %s", gcsPath)
+	}
+	return attrs, nil
+}
+
+// ObjectMetadata is the HTTP metadata of a GCS object.
+type ObjectMetadata struct {
+	ContentType  string
+	CacheControl string
+}
+
+// ReadGCSObjectMetadata returns the metadata of the object at the gs://
+// `gcsPath`, which is taken from its attributes read using the GCS client.
+// The returned error wraps ErrObjectNotFound if the object does not exist.
+func ReadGCSObjectMetadata(gcsPath string) (*ObjectMetadata, error) {
+	attrs, err := gcsObjectAttrs(context.Background(), gcsPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectMetadata{
+		ContentType:  attrs.ContentType,
+		CacheControl: attrs.CacheControl,
+	}, nil
+}
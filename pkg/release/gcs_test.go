@@ -0,0 +1,299 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinGCSPath(t *testing.T) {
+	cases := map[string]struct {
+		bucket string
+		elems  []string
+		want   string
+	}{
+		"BucketOnly": {
+			bucket: "bucket",
+			want:   "gs://bucket",
+		},
+		"WithPrefix": {
+			bucket: "gs://bucket/",
+			elems:  []string{"ci/", "v1.18.3"},
+			want:   "gs://bucket/ci/v1.18.3",
+		},
+		"Unclean": {
+			bucket: "bucket",
+			elems:  []string{"ci//", "./v1.18.3/"},
+			want:   "gs://bucket/ci/v1.18.3",
+		},
+		"EscapingBucket": {
+			bucket: "bucket",
+			elems:  []string{"..", "..", "other"},
+			want:   "gs://bucket/other",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, JoinGCSPath(tc.bucket, tc.elems...))
+		})
+	}
+}
+
+func TestCIObjectPath(t *testing.T) {
+	cases := map[string]struct {
+		version string
+		want    string
+	}{
+		"CIBuild": {
+			version: "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			want:    "gs://kubernetes-release-dev/ci/v1.19.0-beta.1.58+e19c4a2b1ec777",
+		},
+		"Release": {
+			version: "v1.18.3",
+			want:    "gs://kubernetes-release-dev/ci/v1.18.3",
+		},
+		"Empty": {
+			version: "",
+		},
+		"Invalid": {
+			version: "wrong",
+		},
+		"Escaping": {
+			version: "v1.18.3/../../release/v1.18.3",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, CIObjectPath(tc.version))
+		})
+	}
+
+	defer os.Unsetenv("RELEASE_BUCKET_PREFIX")
+	os.Setenv("RELEASE_BUCKET_PREFIX", "my-mirror-")
+	require.Equal(t, "gs://my-mirror-dev/ci/v1.18.3", CIObjectPath("v1.18.3"))
+}
+
+func TestGetReleaseArtifactGCSPath(t *testing.T) {
+	defer os.Unsetenv("RELEASE_BUCKET_PREFIX")
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version  string
+		artifact string
+		prefix   string
+		want     want
+	}{
+		"Tarball": {
+			version:  "v1.20.3",
+			artifact: "kubernetes.tar.gz",
+			want:     want{r: "gs://kubernetes-release/release/v1.20.3/kubernetes.tar.gz"},
+		},
+		"NodeTarball": {
+			version:  "v1.20.3",
+			artifact: "kubernetes-node-linux-amd64.tar.gz",
+			want:     want{r: "gs://kubernetes-release/release/v1.20.3/kubernetes-node-linux-amd64.tar.gz"},
+		},
+		"CustomPrefix": {
+			version:  "v1.20.3",
+			artifact: "kubernetes.tar.gz",
+			prefix:   "my-mirror-",
+			want:     want{r: "gs://my-mirror/release/v1.20.3/kubernetes.tar.gz"},
+		},
+		"InvalidVersion": {
+			version:  "1.20.3",
+			artifact: "kubernetes.tar.gz",
+			want:     want{rErr: true},
+		},
+		"PartialVersion": {
+			version:  "xv1.20.3junk",
+			artifact: "kubernetes.tar.gz",
+			want:     want{rErr: true},
+		},
+		"EmptyArtifact": {
+			version: "v1.20.3",
+			want:    want{rErr: true},
+		},
+		"EscapingArtifact": {
+			version:  "v1.20.3",
+			artifact: "../v1.20.2/kubernetes.tar.gz",
+			want:     want{rErr: true},
+		},
+		"EscapingVersion": {
+			version:  "v1.20.3/../v1.20.2",
+			artifact: "kubernetes.tar.gz",
+			want:     want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv("RELEASE_BUCKET_PREFIX", tc.prefix)
+			res, err := GetReleaseArtifactGCSPath(tc.version, tc.artifact)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+// fakeGCSObject is an object of fakeGCSObjects. Reading it fails with `err`
+// if set.
+type fakeGCSObject struct {
+	content string
+	attrs   storage.ObjectAttrs
+	err     error
+}
+
+// fakeGCSObjects are gcsObjects keyed by "<bucket>/<object>".
+type fakeGCSObjects map[string]*fakeGCSObject
+
+func (f fakeGCSObjects) object(bucket, object string) (*fakeGCSObject, error) {
+	o, ok := f[bucket+"/"+object]
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	return o, o.err
+}
+
+func (f fakeGCSObjects) NewReader(ctx context.Context, bucket, object string) (io.ReadCloser, error) {
+	o, err := f.object(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(o.content)), nil
+}
+
+func (f fakeGCSObjects) Attrs(ctx context.Context, bucket, object string) (*storage.ObjectAttrs, error) {
+	o, err := f.object(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	attrs := o.attrs
+	attrs.Bucket, attrs.Name, attrs.Size = bucket, object, int64(len(o.content))
+	return &attrs, nil
+}
+
+// useGCSObjects replaces the GCS client with `objects` until the returned
+// function is called.
+func useGCSObjects(objects gcsObjects) func() {
+	gcsClient.Lock()
+	defer gcsClient.Unlock()
+	former := gcsClient.objects
+	gcsClient.objects = objects
+	return func() {
+		gcsClient.Lock()
+		defer gcsClient.Unlock()
+		gcsClient.objects = former
+	}
+}
+
+// useFakeGCS replaces the GCS client with one serving the content of
+// `objects`, keyed by "<bucket>/<object>", until the returned function is
+// called.
+func useFakeGCS(objects map[string]string) func() {
+	fake := fakeGCSObjects{}
+	for name, content := range objects {
+		fake[name] = &fakeGCSObject{content: content}
+	}
+	return useGCSObjects(fake)
+}
+
+func TestGCSObjectURL(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		gcsPath string
+		want    want
+	}{
+		"Object": {
+			gcsPath: "gs://bucket/ci/latest.txt",
+			want:    want{r: "https://storage.googleapis.com/bucket/ci/latest.txt"},
+		},
+		"BucketOnly": {
+			gcsPath: "gs://bucket/",
+			want:    want{rErr: true},
+		},
+		"NoGCSPath": {
+			gcsPath: "bucket/ci/latest.txt",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := GCSObjectURL(tc.gcsPath)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestReadGCSObject(t *testing.T) {
+	defer useGCSObjects(fakeGCSObjects{
+		"bucket/ci/latest.txt": {content: "v1.19.0-beta.1.58+e19c4a2b1ec777\n"},
+		"private/ci/latest.txt": {
+			err: errors.New("googleapi: Error 403: Access denied, forbidden"),
+		},
+	})()
+
+	res, err := ReadGCSObject("gs://bucket/ci/latest.txt")
+	require.Nil(t, err)
+	require.Equal(t, "v1.19.0-beta.1.58+e19c4a2b1ec777\n", string(res))
+
+	_, err = ReadGCSObject("gs://bucket/ci/notexisting.txt")
+	require.NotNil(t, err)
+	require.Equal(t, ErrObjectNotFound, errors.Cause(err))
+
+	// Errors of the client are not mistaken for missing objects
+	_, err = ReadGCSObject("gs://private/ci/latest.txt")
+	require.NotNil(t, err)
+	require.NotEqual(t, ErrObjectNotFound, errors.Cause(err))
+	require.Contains(t, err.Error(), "403")
+
+	_, err = ReadGCSObject("gs://bucket")
+	require.NotNil(t, err)
+}
+
+func TestGCSObjectExists(t *testing.T) {
+	defer useFakeGCS(map[string]string{"bucket/ci/latest.txt": "test"})()
+
+	exists, err := GCSObjectExists("gs://bucket/ci/latest.txt")
+	require.Nil(t, err)
+	require.True(t, exists)
+
+	exists, err = GCSObjectExists("gs://bucket/ci/notexisting.txt")
+	require.Nil(t, err)
+	require.False(t, exists)
+
+	_, err = GCSObjectExists("bucket/ci/latest.txt")
+	require.NotNil(t, err)
+}
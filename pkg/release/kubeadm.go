@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const kubeadmClusterConfiguration = "ClusterConfiguration"
+
+var (
+	// kubeadmDocumentSeparatorRE splits multi document YAML files.
+	kubeadmDocumentSeparatorRE = regexp.MustCompile(`(?m)^---\s*$`)
+
+	// releaseLabelRE matches the version labels kubeadm accepts instead of a
+	// version, for example stable or latest-1.18.
+	releaseLabelRE = regexp.MustCompile(`^(stable|latest)(-[0-9]+(\.[0-9]+)?)?$`)
+)
+
+// kubeadmConfig is the part of a kubeadm configuration document which
+// declares the Kubernetes version.
+type kubeadmConfig struct {
+	Kind              string `json:"kind"`
+	KubernetesVersion string `json:"kubernetesVersion"`
+}
+
+// ReadVersionFromKubeadmConfig returns the kubernetesVersion of the
+// ClusterConfiguration in the kubeadm configuration file at `path`, which
+// may contain multiple YAML documents. Release labels like "stable" or
+// "latest-1.18" get resolved from their version markers. The result is
+// normalized using NormalizeVersion.
+func ReadVersionFromKubeadmConfig(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading kubeadm config %s", path)
+	}
+
+	for _, document := range kubeadmDocumentSeparatorRE.Split(string(content), -1) {
+		if strings.TrimSpace(document) == "" {
+			continue
+		}
+
+		config := &kubeadmConfig{}
+		if err := yaml.Unmarshal([]byte(document), config); err != nil {
+			return "", errors.Wrapf(err, "parsing kubeadm config %s", path)
+		}
+		if config.Kind != kubeadmClusterConfiguration {
+			continue
+		}
+		if config.KubernetesVersion == "" {
+			return "", errors.Errorf("no kubernetesVersion set in %s", path)
+		}
+
+		version := config.KubernetesVersion
+		if releaseLabelRE.MatchString(version) {
+			logrus.Infof("Resolving kubeadm version label %s", version)
+			resolved, err := GetKubeVersion(downloadURLBase+"/"+version+".txt", false)
+			if err != nil {
+				return "", errors.Wrapf(err, "resolving version label %s", version)
+			}
+			version = resolved
+		}
+		return NormalizeVersion(version)
+	}
+
+	return "", errors.Errorf("no %s found in %s", kubeadmClusterConfiguration, path)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadVersionFromKubeadmConfig(t *testing.T) {
+	defer newStableMarkersServer(map[string]string{
+		"stable.txt":      "v1.22.1",
+		"latest-1.22.txt": "v1.22.2-rc.0",
+	})()
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		config string
+		want   want
+	}{
+		"Version": {
+			config: "apiVersion: kubeadm.k8s.io/v1beta2\n" +
+				"kind: ClusterConfiguration\n" +
+				"kubernetesVersion: v1.21.4\n",
+			want: want{r: "v1.21.4"},
+		},
+		"WithoutPrefix": {
+			config: "kind: ClusterConfiguration\nkubernetesVersion: 1.21.4\n",
+			want:   want{r: "v1.21.4"},
+		},
+		"MultipleDocuments": {
+			config: "apiVersion: kubeadm.k8s.io/v1beta2\n" +
+				"kind: InitConfiguration\n" +
+				"---\n" +
+				"apiVersion: kubeadm.k8s.io/v1beta2\n" +
+				"kind: ClusterConfiguration\n" +
+				"kubernetesVersion: v1.20.10\n",
+			want: want{r: "v1.20.10"},
+		},
+		"Stable": {
+			config: "kind: ClusterConfiguration\nkubernetesVersion: stable\n",
+			want:   want{r: "v1.22.1"},
+		},
+		"LatestMinor": {
+			config: "kind: ClusterConfiguration\nkubernetesVersion: latest-1.22\n",
+			want:   want{r: "v1.22.2-rc.0"},
+		},
+		"UnavailableLabel": {
+			config: "kind: ClusterConfiguration\nkubernetesVersion: stable-1.10\n",
+			want:   want{rErr: true},
+		},
+		"NoVersion": {
+			config: "kind: ClusterConfiguration\n",
+			want:   want{rErr: true},
+		},
+		"InvalidVersion": {
+			config: "kind: ClusterConfiguration\nkubernetesVersion: wrong\n",
+			want:   want{rErr: true},
+		},
+		"NoClusterConfiguration": {
+			config: "kind: InitConfiguration\n",
+			want:   want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+
+			config := filepath.Join(baseTmpDir, "kubeadm.yaml")
+			require.Nil(t, ioutil.WriteFile(config, []byte(tc.config), os.FileMode(0644)))
+
+			res, err := ReadVersionFromKubeadmConfig(config)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+
+	_, err := ReadVersionFromKubeadmConfig("notexisting.yaml")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+// releaseManifestFile is the name of the manifest published next to the
+// release artifacts.
+const releaseManifestFile = "manifest.json"
+
+// ArtifactManifest records the release artifacts of a build.
+type ArtifactManifest struct {
+	// Version is the version of the build, which is set for published
+	// releases.
+	Version   string                  `json:"version,omitempty"`
+	Artifacts []ArtifactManifestEntry `json:"artifacts"`
+}
+
+// ArtifactManifestEntry is a single artifact of an ArtifactManifest, where
+// Path is relative to the ReleaseTarsPath.
+type ArtifactManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// GenerateArtifactManifest returns the manifest of all release artifacts in
+// the build output directory `workDir`, sorted by their path.
+func GenerateArtifactManifest(workDir string) (*ArtifactManifest, error) {
+	artifacts, err := ListReleaseArtifacts(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ArtifactManifestEntry, len(artifacts))
+	if err := runParallel(len(artifacts), func(i int) error {
+		file := filepath.Join(workDir, ReleaseTarsPath, artifacts[i])
+		info, err := os.Stat(file)
+		if err != nil {
+			return errors.Wrapf(err, "checking size of %s", artifacts[i])
+		}
+		sha, err := util.SHA256ForFile(file)
+		if err != nil {
+			return errors.Wrapf(err, "generating checksum of %s", artifacts[i])
+		}
+
+		entries[i] = ArtifactManifestEntry{
+			Path:   artifacts[i],
+			Size:   info.Size(),
+			SHA256: sha,
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &ArtifactManifest{Artifacts: entries}, nil
+}
+
+// WriteArtifactManifest records the manifest of the release artifacts in
+// `workDir` as JSON to `manifestPath`.
+func WriteArtifactManifest(workDir, manifestPath string) error {
+	manifest, err := GenerateArtifactManifest(workDir)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshalling artifact manifest")
+	}
+	return errors.Wrapf(
+		ioutil.WriteFile(manifestPath, content, os.FileMode(0644)),
+		"writing artifact manifest %s", manifestPath,
+	)
+}
+
+// VerifyAgainstManifest checks that the release artifacts in `workDir` still
+// match the manifest previously recorded at `manifestPath`. The returned
+// error lists all missing, unexpected and modified artifacts.
+func VerifyAgainstManifest(workDir, manifestPath string) error {
+	content, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return errors.Wrapf(err, "reading artifact manifest %s", manifestPath)
+	}
+	recorded := &ArtifactManifest{}
+	if err := json.Unmarshal(content, recorded); err != nil {
+		return errors.Wrapf(err, "parsing artifact manifest %s", manifestPath)
+	}
+
+	current, err := GenerateArtifactManifest(workDir)
+	if err != nil {
+		return err
+	}
+
+	currentEntries := map[string]ArtifactManifestEntry{}
+	for _, entry := range current.Artifacts {
+		currentEntries[entry.Path] = entry
+	}
+
+	differences := []string{}
+	for _, want := range recorded.Artifacts {
+		got, ok := currentEntries[want.Path]
+		delete(currentEntries, want.Path)
+
+		switch {
+		case !ok:
+			differences = append(differences, want.Path+" is missing")
+		case got.Size != want.Size:
+			differences = append(differences, fmt.Sprintf(
+				"%s has size %d, expected %d", want.Path, got.Size, want.Size,
+			))
+		case got.SHA256 != want.SHA256:
+			differences = append(differences, fmt.Sprintf(
+				"%s has checksum %s, expected %s", want.Path, got.SHA256, want.SHA256,
+			))
+		}
+	}
+	for _, entry := range current.Artifacts {
+		if _, ok := currentEntries[entry.Path]; ok {
+			differences = append(differences, entry.Path+" is not in the manifest")
+		}
+	}
+
+	if len(differences) > 0 {
+		return errors.Errorf(
+			"artifacts do not match manifest %s: %s",
+			manifestPath, strings.Join(differences, "; "),
+		)
+	}
+	return nil
+}
+
+// GetReleaseManifest returns the artifact manifest published for the release
+// `version` on the download host, for example
+// https://dl.k8s.io/release/v1.18.3/manifest.json.
+func GetReleaseManifest(version string) (*ArtifactManifest, error) {
+	return GetReleaseManifestWithOptions(context.Background(), version, nil)
+}
+
+// GetReleaseManifestWithOptions is GetReleaseManifest, where the request gets
+// aborted if `ctx` is cancelled and `opts` customize how the manifest is
+// fetched and logged.
+func GetReleaseManifestWithOptions(ctx context.Context, version string, opts *KubeVersionOptions) (*ArtifactManifest, error) {
+	manifestURL, err := ReleaseDownloadURL(version, releaseManifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	log := opts.logger()
+	log.Infof("Retrieving release manifest %s", manifestURL)
+	resp, err := getMarkerWithRetry(
+		ctx, opts.httpClient(), manifestURL, opts.retryOptions(), log,
+	)
+	if statusErr, ok := errors.Cause(err).(*statusError); ok && statusErr.code == http.StatusNotFound {
+		return nil, errors.Errorf("no release manifest published for %s at %s", version, manifestURL)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "retrieving release manifest of %s", version)
+	}
+
+	manifest := &ArtifactManifest{}
+	if err := json.Unmarshal([]byte(resp.content), manifest); err != nil {
+		return nil, errors.Wrapf(err, "parsing release manifest %s", manifestURL)
+	}
+	return manifest, nil
+}
+
+// ReadVersionFromReleaseManifest returns the version recorded in the release
+// manifest published for `version`. It is an authoritative cross-check for
+// the version markers, which means an error is returned if the manifest does
+// not record a version or a different one.
+func ReadVersionFromReleaseManifest(version string) (string, error) {
+	manifest, err := GetReleaseManifest(version)
+	if err != nil {
+		return "", err
+	}
+	if manifest.Version == "" {
+		return "", errors.Errorf("release manifest of %s does not contain a version", version)
+	}
+	if manifest.Version != version {
+		return "", errors.Errorf(
+			"release manifest of %s records version %s", version, manifest.Version,
+		)
+	}
+	return manifest.Version, nil
+}
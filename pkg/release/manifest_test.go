@@ -0,0 +1,189 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateArtifactManifest(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	writeTestArtifacts(t, baseTmpDir, map[string]string{
+		"kubernetes.tar.gz":        "test",
+		"kubernetes.tar.gz.sha256": "test",
+		"kubernetes-src.tar.gz":    "",
+	})
+
+	res, err := GenerateArtifactManifest(baseTmpDir)
+	require.Nil(t, err)
+	require.Equal(t, &ArtifactManifest{Artifacts: []ArtifactManifestEntry{
+		{
+			Path:   "kubernetes-src.tar.gz",
+			Size:   0,
+			SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{
+			Path:   "kubernetes.tar.gz",
+			Size:   4,
+			SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		},
+	}}, res)
+
+	_, err = GenerateArtifactManifest(filepath.Join(baseTmpDir, "notexisting"))
+	require.NotNil(t, err)
+}
+
+func TestVerifyAgainstManifest(t *testing.T) {
+	recordedFiles := map[string]string{
+		"kubernetes.tar.gz":     "test",
+		"kubernetes-src.tar.gz": "test",
+	}
+
+	cases := map[string]struct {
+		modify func(t *testing.T, workDir string)
+		rErr   bool
+	}{
+		"Unchanged": {
+			modify: func(*testing.T, string) {},
+		},
+		"Missing": {
+			modify: func(t *testing.T, workDir string) {
+				require.Nil(t, os.Remove(
+					filepath.Join(workDir, ReleaseTarsPath, "kubernetes.tar.gz"),
+				))
+			},
+			rErr: true,
+		},
+		"Unexpected": {
+			modify: func(t *testing.T, workDir string) {
+				writeTestArtifacts(t, workDir, map[string]string{"extra.tar.gz": "test"})
+			},
+			rErr: true,
+		},
+		"DifferentSize": {
+			modify: func(t *testing.T, workDir string) {
+				writeTestArtifacts(t, workDir, map[string]string{"kubernetes.tar.gz": "longer"})
+			},
+			rErr: true,
+		},
+		"DifferentChecksum": {
+			modify: func(t *testing.T, workDir string) {
+				writeTestArtifacts(t, workDir, map[string]string{"kubernetes.tar.gz": "tset"})
+			},
+			rErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+			writeTestArtifacts(t, baseTmpDir, recordedFiles)
+
+			manifestPath := filepath.Join(baseTmpDir, "manifest.json")
+			require.Nil(t, WriteArtifactManifest(baseTmpDir, manifestPath))
+
+			tc.modify(t, baseTmpDir)
+			err = VerifyAgainstManifest(baseTmpDir, manifestPath)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
+func TestVerifyAgainstManifestInvalid(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+	writeTestArtifacts(t, baseTmpDir, map[string]string{"kubernetes.tar.gz": "test"})
+
+	manifestPath := filepath.Join(baseTmpDir, "manifest.json")
+	require.NotNil(t, VerifyAgainstManifest(baseTmpDir, manifestPath))
+
+	require.Nil(t, ioutil.WriteFile(manifestPath, []byte("invalid"), os.FileMode(0644)))
+	require.NotNil(t, VerifyAgainstManifest(baseTmpDir, manifestPath))
+}
+
+func TestGetReleaseManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1.18.3/manifest.json":
+				w.Write([]byte(`{"version":"v1.18.3","artifacts":[` +
+					`{"path":"kubernetes.tar.gz","size":4,"sha256":"abc"}]}`))
+			case "/v1.18.2/manifest.json":
+				w.Write([]byte(`{"version":"v1.18.1","artifacts":[]}`))
+			case "/v1.18.1/manifest.json":
+				w.Write([]byte(`{"artifacts":[]}`))
+			case "/v1.18.0/manifest.json":
+				w.Write([]byte(`invalid`))
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer server.Close()
+	defer func(base string) { downloadURLBase = base }(downloadURLBase)
+	downloadURLBase = server.URL
+
+	manifest, err := GetReleaseManifest("v1.18.3")
+	require.Nil(t, err)
+	require.Equal(t, &ArtifactManifest{
+		Version: "v1.18.3",
+		Artifacts: []ArtifactManifestEntry{
+			{Path: "kubernetes.tar.gz", Size: 4, SHA256: "abc"},
+		},
+	}, manifest)
+
+	_, err = GetReleaseManifest("v1.17.0")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "no release manifest published")
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Success":         {version: "v1.18.3", want: want{r: "v1.18.3"}},
+		"VersionMismatch": {version: "v1.18.2", want: want{rErr: true}},
+		"NoVersion":       {version: "v1.18.1", want: want{rErr: true}},
+		"InvalidManifest": {version: "v1.18.0", want: want{rErr: true}},
+		"Missing":         {version: "v1.17.0", want: want{rErr: true}},
+		"InvalidVersion":  {version: "wrong", want: want{rErr: true}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ReadVersionFromReleaseManifest(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
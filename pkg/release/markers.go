@@ -0,0 +1,332 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/git"
+	"k8s.io/release/pkg/util"
+)
+
+// ReleaseType is the channel a version marker belongs to.
+type ReleaseType string
+
+const (
+	// ReleaseTypeStable is the channel of official releases (release/stable*.txt).
+	ReleaseTypeStable ReleaseType = "stable"
+
+	// ReleaseTypeLatest is the channel of all releases including pre-releases
+	// (release/latest*.txt).
+	ReleaseTypeLatest ReleaseType = "latest"
+
+	// ReleaseTypeCI is the channel of CI builds (ci/latest*.txt).
+	ReleaseTypeCI ReleaseType = "ci"
+
+	releaseMarkerDir = "release"
+	ciMarkerDir      = "ci"
+
+	// ciMarkerMinor is replaced by the version of a release branch in the
+	// names of the ciMarkers, for example by 1.18 for release-1.18.
+	ciMarkerMinor = "{minor}"
+)
+
+// ciMarkers are the names of the CI version markers updated by the builds of
+// a branch, from the most generic to the most specific one. Markers
+// containing ciMarkerMinor only exist for release branches.
+var ciMarkers = []string{"latest", "latest-" + ciMarkerMinor}
+
+// MarkerUpdate is a single version marker write, where Marker is the path of
+// the marker file relative to the bucket root, e.g. "release/stable-1.18.txt".
+type MarkerUpdate struct {
+	Marker  string
+	Version string
+}
+
+// VersionMarker is a published version marker together with the version it
+// points to.
+type VersionMarker struct {
+	// Name is the marker without extension, e.g. "latest-1.18".
+	Name string
+
+	// URL is the location of the marker.
+	URL string
+
+	// Version is the content of the marker.
+	Version string
+}
+
+// ListCIVersionMarkers returns the CI version markers of `branch` together
+// with the versions they point to. These are the markers of the ciMarkers
+// table, which means "latest" for master and additionally "latest-1.18" for
+// release branches like release-1.18. The marker names follow
+// GetCIKubeVersion. Markers which are not published are left out.
+func ListCIVersionMarkers(branch string) ([]VersionMarker, error) {
+	if !IsValidReleaseBranch(branch) {
+		return nil, errors.Errorf("%s is not a release branch", branch)
+	}
+
+	markers := []VersionMarker{}
+	for _, name := range ciMarkerNames(branch) {
+		markerURL := strings.TrimSuffix(ciURLBase, "/") + "/" + name + ".txt"
+		version, err := fetchMarker(context.Background(), markerURL, nil)
+		if statusErr, ok := errors.Cause(err).(*statusError); ok && statusErr.code == http.StatusNotFound {
+			logrus.Infof("Marker %s is not published", markerURL)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "retrieving marker %s", name)
+		}
+		markers = append(markers, VersionMarker{Name: name, URL: markerURL, Version: version})
+	}
+	return markers, nil
+}
+
+// MarkersForVersion returns the release version markers a version would be
+// published to. Official releases update the stable markers, while
+// pre-releases update the latest markers.
+// Replaces the marker selection of release::gcs::publish_version
+func MarkersForVersion(version string) ([]string, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing version %s", version)
+	}
+
+	channel := ReleaseTypeStable
+	if len(sem.Pre) > 0 {
+		channel = ReleaseTypeLatest
+	}
+
+	return channelMarkers(channel, sem.Major, sem.Minor), nil
+}
+
+// CIMarkersForVersion returns the CI version markers a build of `version`
+// updates, which are the ciMarkers of its branch. Builds of a release branch
+// update ci/latest.txt and the marker of their minor, for example
+// ci/latest-1.18.txt, while builds of master only update ci/latest.txt.
+func CIMarkersForVersion(version string) ([]string, error) {
+	branch, err := KubecrossBranchForVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	markers := []string{}
+	for _, name := range ciMarkerNames(branch) {
+		markers = append(markers, path.Join(ciMarkerDir, name+".txt"))
+	}
+	return markers, nil
+}
+
+// ciMarkerNames returns the names of the ciMarkers of `branch`.
+func ciMarkerNames(branch string) []string {
+	minor := strings.TrimPrefix(branch, "release-")
+	names := []string{}
+	for _, marker := range ciMarkers {
+		if strings.Contains(marker, ciMarkerMinor) {
+			if git.IsDefaultBranch(branch) {
+				continue
+			}
+			marker = strings.ReplaceAll(marker, ciMarkerMinor, minor)
+		}
+		names = append(names, marker)
+	}
+	return names
+}
+
+// PlanMarkerUpdates returns the marker writes needed to point the provided
+// channel at `version`. Prerelease versions are rejected for the stable
+// channel. The markers of the CI channel are the ones of CIMarkersForVersion.
+func PlanMarkerUpdates(version string, channel ReleaseType) ([]MarkerUpdate, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing version %s", version)
+	}
+
+	switch channel {
+	case ReleaseTypeStable, ReleaseTypeLatest, ReleaseTypeCI:
+	default:
+		return nil, errors.Errorf("unknown release type %q", channel)
+	}
+
+	// Never let a prerelease end up in the stable markers
+	if channel == ReleaseTypeStable && len(sem.Pre) > 0 {
+		return nil, errors.Errorf(
+			"refusing to point %s markers at prerelease version %s", channel, version,
+		)
+	}
+
+	markers := channelMarkers(channel, sem.Major, sem.Minor)
+	if channel == ReleaseTypeCI {
+		if markers, err = CIMarkersForVersion(version); err != nil {
+			return nil, err
+		}
+	}
+
+	updates := []MarkerUpdate{}
+	for _, marker := range markers {
+		updates = append(updates, MarkerUpdate{Marker: marker, Version: version})
+	}
+	return updates, nil
+}
+
+// EnsureMonotonicStable verifies that moving a marker from `current` to
+// `next` does not move it backwards. Setting `force` allows going back to an
+// older version, which is required for rollbacks.
+// Replaces release::gcs::verify_latest_update
+func EnsureMonotonicStable(current, next string, force bool) error {
+	currentSem, err := util.TagStringToSemver(current)
+	if err != nil {
+		return errors.Wrapf(err, "parsing current version %s", current)
+	}
+	nextSem, err := util.TagStringToSemver(next)
+	if err != nil {
+		return errors.Wrapf(err, "parsing next version %s", next)
+	}
+
+	if nextSem.LT(currentSem) {
+		if !force {
+			return errors.Errorf(
+				"version %s is older than the published version %s", next, current,
+			)
+		}
+		logrus.Warnf(
+			"Forcing marker from %s back to older version %s", current, next,
+		)
+	}
+	return nil
+}
+
+// PlanRollback returns the marker writes needed to point `channel` back at the
+// older version `toVersion`. The markers are read from GCS: markers already
+// pointing to `toVersion` are left out, while a rollback would move any other
+// marker backwards, which is only allowed by EnsureMonotonicStable if `force`
+// is set. Markers which do not exist or point to an older version are
+// rejected, since writing them would move the channel forward.
+func PlanRollback(toVersion string, channel ReleaseType, force bool) ([]MarkerUpdate, error) {
+	updates, err := PlanMarkerUpdates(toVersion, channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "planning rollback")
+	}
+	bucket, _, err := publishedLocation(toVersion, channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "planning rollback")
+	}
+
+	rollback := []MarkerUpdate{}
+	for _, update := range updates {
+		content, err := ReadGCSObject(JoinGCSPath(bucket, update.Marker))
+		if errors.Cause(err) == ErrObjectNotFound {
+			return nil, errors.Errorf(
+				"marker %s is not published, rolling it back to %s would move it forward",
+				update.Marker, toVersion,
+			)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading marker %s", update.Marker)
+		}
+
+		current := strings.TrimSpace(string(content))
+		newer, err := IsNewerVersion(toVersion, current)
+		if err != nil {
+			return nil, errors.Wrapf(err, "comparing marker %s", update.Marker)
+		}
+		if newer {
+			return nil, errors.Errorf(
+				"marker %s points to %s, rolling it back to %s would move it forward",
+				update.Marker, current, toVersion,
+			)
+		}
+		if current == toVersion {
+			continue
+		}
+
+		if err := EnsureMonotonicStable(current, toVersion, force); err != nil {
+			return nil, errors.Wrapf(err, "rolling back marker %s", update.Marker)
+		}
+		logrus.Warnf(
+			"Rollback will move marker %s backwards from %s to %s",
+			update.Marker, current, update.Version,
+		)
+		rollback = append(rollback, update)
+	}
+	return rollback, nil
+}
+
+// channelMarkers returns the release marker paths of a channel for the
+// provided major and minor version, e.g. release/stable.txt,
+// release/stable-1.txt and release/stable-1.18.txt.
+func channelMarkers(channel ReleaseType, major, minor uint64) []string {
+	name := string(channel)
+	return []string{
+		path.Join(releaseMarkerDir, name+".txt"),
+		path.Join(releaseMarkerDir, fmt.Sprintf("%s-%d.txt", name, major)),
+		path.Join(releaseMarkerDir, fmt.Sprintf("%s-%d.%d.txt", name, major, minor)),
+	}
+}
+
+// ExpectedMarkerContent returns the exact content of a marker file pointing
+// to `version`, which is the version followed by a single newline.
+func ExpectedMarkerContent(version string) string {
+	return strings.TrimSpace(version) + "\n"
+}
+
+// MarkerEquals returns true if the marker file `content` is byte for byte the
+// content expected for `version`.
+func MarkerEquals(content []byte, version string) bool {
+	return string(content) == ExpectedMarkerContent(version)
+}
+
+// WriteMarker writes the marker of `update` below the local directory
+// `stageDir`, for example into the GCSStagePath before pushing it.
+func WriteMarker(stageDir string, update MarkerUpdate) error {
+	_, err := WriteMarkerWithOptions(stageDir, update, nil)
+	return err
+}
+
+// WriteMarkerWithOptions is WriteMarker, which returns the path of the
+// marker file. The marker is only logged if `opts` enable the dry run mode.
+func WriteMarkerWithOptions(stageDir string, update MarkerUpdate, opts *StageOptions) (string, error) {
+	file := filepath.Join(stageDir, filepath.FromSlash(update.Marker))
+	if opts.dryRun() {
+		opts.logger().Infof(
+			"Dry run: would write marker %s pointing to %s", file, update.Version,
+		)
+		return file, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), os.FileMode(0755)); err != nil {
+		return "", errors.Wrapf(err, "creating directory for marker %s", update.Marker)
+	}
+
+	opts.logger().Infof("Writing marker %s pointing to %s", update.Marker, update.Version)
+	if err := ioutil.WriteFile(
+		file, []byte(ExpectedMarkerContent(update.Version)), os.FileMode(0644),
+	); err != nil {
+		return "", errors.Wrapf(err, "writing marker %s", update.Marker)
+	}
+	return file, nil
+}
@@ -0,0 +1,461 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkersForVersion(t *testing.T) {
+	type want struct {
+		r    []string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Official": {
+			version: "v1.18.3",
+			want: want{
+				r: []string{
+					"release/stable.txt",
+					"release/stable-1.txt",
+					"release/stable-1.18.txt",
+				},
+			},
+		},
+		"PreRelease": {
+			version: "v1.19.0-beta.1",
+			want: want{
+				r: []string{
+					"release/latest.txt",
+					"release/latest-1.txt",
+					"release/latest-1.19.txt",
+				},
+			},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := MarkersForVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestCIMarkersForVersion(t *testing.T) {
+	type want struct {
+		r    []string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"ReleaseBranch": {
+			version: "v1.18.4-rc.0.12+f1a2b3c4d5e6f7",
+			want: want{
+				r: []string{"ci/latest.txt", "ci/latest-1.18.txt"},
+			},
+		},
+		"ReleaseBranchBeta": {
+			version: "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			want: want{
+				r: []string{"ci/latest.txt", "ci/latest-1.19.txt"},
+			},
+		},
+		"Master": {
+			version: "v1.20.0-alpha.0.1+a1b2c3d4e5f6a7",
+			want:    want{r: []string{"ci/latest.txt"}},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := CIMarkersForVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestCIMarkerNames(t *testing.T) {
+	for branch, want := range map[string][]string{
+		"master":       {"latest"},
+		"main":         {"latest"},
+		"release-1.18": {"latest", "latest-1.18"},
+	} {
+		require.Equal(t, want, ciMarkerNames(branch))
+		require.Equal(t, want[len(want)-1], ciMarkerName(branch))
+	}
+}
+
+func TestListCIVersionMarkers(t *testing.T) {
+	markers := map[string]string{
+		"/latest.txt":      "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7",
+		"/latest-1.18.txt": "v1.18.4-rc.0.12+f1a2b3c4d5e6f7\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			marker, ok := markers[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(marker))
+		},
+	))
+	defer server.Close()
+	defer func(base string) { ciURLBase = base }(ciURLBase)
+	ciURLBase = server.URL
+
+	type want struct {
+		r    []VersionMarker
+		rErr bool
+	}
+	cases := map[string]struct {
+		branch string
+		want   want
+	}{
+		"Master": {
+			branch: "master",
+			want: want{r: []VersionMarker{{
+				Name:    "latest",
+				URL:     server.URL + "/latest.txt",
+				Version: "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7",
+			}}},
+		},
+		"Main": {
+			branch: "main",
+			want: want{r: []VersionMarker{{
+				Name:    "latest",
+				URL:     server.URL + "/latest.txt",
+				Version: "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7",
+			}}},
+		},
+		"ReleaseBranch": {
+			branch: "release-1.18",
+			want: want{r: []VersionMarker{
+				{
+					Name:    "latest",
+					URL:     server.URL + "/latest.txt",
+					Version: "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7",
+				},
+				{
+					Name:    "latest-1.18",
+					URL:     server.URL + "/latest-1.18.txt",
+					Version: "v1.18.4-rc.0.12+f1a2b3c4d5e6f7",
+				},
+			}},
+		},
+		"NotPublished": {
+			branch: "release-1.17",
+			want: want{r: []VersionMarker{{
+				Name:    "latest",
+				URL:     server.URL + "/latest.txt",
+				Version: "v1.20.0-alpha.0.12+a1b2c3d4e5f6a7",
+			}}},
+		},
+		"InvalidBranch": {
+			branch: "wrong",
+			want:   want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ListCIVersionMarkers(tc.branch)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestPlanMarkerUpdates(t *testing.T) {
+	type want struct {
+		r    []MarkerUpdate
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		channel ReleaseType
+		want    want
+	}{
+		"Stable": {
+			version: "v1.18.3",
+			channel: ReleaseTypeStable,
+			want: want{
+				r: []MarkerUpdate{
+					{Marker: "release/stable.txt", Version: "v1.18.3"},
+					{Marker: "release/stable-1.txt", Version: "v1.18.3"},
+					{Marker: "release/stable-1.18.txt", Version: "v1.18.3"},
+				},
+			},
+		},
+		"CI": {
+			version: "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			channel: ReleaseTypeCI,
+			want: want{
+				r: []MarkerUpdate{
+					{Marker: "ci/latest.txt", Version: "v1.19.0-beta.1.58+e19c4a2b1ec777"},
+					{Marker: "ci/latest-1.19.txt", Version: "v1.19.0-beta.1.58+e19c4a2b1ec777"},
+				},
+			},
+		},
+		"CIMaster": {
+			version: "v1.20.0-alpha.0.1+a1b2c3d4e5f6a7",
+			channel: ReleaseTypeCI,
+			want: want{
+				r: []MarkerUpdate{
+					{Marker: "ci/latest.txt", Version: "v1.20.0-alpha.0.1+a1b2c3d4e5f6a7"},
+				},
+			},
+		},
+		"Latest": {
+			version: "v1.19.0-rc.1",
+			channel: ReleaseTypeLatest,
+			want: want{
+				r: []MarkerUpdate{
+					{Marker: "release/latest.txt", Version: "v1.19.0-rc.1"},
+					{Marker: "release/latest-1.txt", Version: "v1.19.0-rc.1"},
+					{Marker: "release/latest-1.19.txt", Version: "v1.19.0-rc.1"},
+				},
+			},
+		},
+		"LatestOfficial": {
+			version: "v1.18.3",
+			channel: ReleaseTypeLatest,
+			want: want{
+				r: []MarkerUpdate{
+					{Marker: "release/latest.txt", Version: "v1.18.3"},
+					{Marker: "release/latest-1.txt", Version: "v1.18.3"},
+					{Marker: "release/latest-1.18.txt", Version: "v1.18.3"},
+				},
+			},
+		},
+		"StableRC": {
+			version: "v1.19.0-rc.1",
+			channel: ReleaseTypeStable,
+			want:    want{rErr: true},
+		},
+		"StableBeta": {
+			version: "v1.19.0-beta.2",
+			channel: ReleaseTypeStable,
+			want:    want{rErr: true},
+		},
+		"StableAlpha": {
+			version: "v1.19.0-alpha.3",
+			channel: ReleaseTypeStable,
+			want:    want{rErr: true},
+		},
+		"StableCIBuild": {
+			version: "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			channel: ReleaseTypeStable,
+			want:    want{rErr: true},
+		},
+		"UnknownChannel": {
+			version: "v1.18.3",
+			channel: ReleaseType("wrong"),
+			want:    want{rErr: true},
+		},
+		"InvalidVersion": {
+			version: "wrong",
+			channel: ReleaseTypeLatest,
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := PlanMarkerUpdates(tc.version, tc.channel)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestEnsureMonotonicStable(t *testing.T) {
+	cases := map[string]struct {
+		current string
+		next    string
+		force   bool
+		rErr    bool
+	}{
+		"Newer": {
+			current: "v1.18.2",
+			next:    "v1.18.3",
+		},
+		"Same": {
+			current: "v1.18.3",
+			next:    "v1.18.3",
+		},
+		"Older": {
+			current: "v1.18.3",
+			next:    "v1.18.2",
+			rErr:    true,
+		},
+		"OlderForced": {
+			current: "v1.18.3",
+			next:    "v1.18.2",
+			force:   true,
+		},
+		"Invalid": {
+			current: "v1.18.3",
+			next:    "wrong",
+			rErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := EnsureMonotonicStable(tc.current, tc.next, tc.force)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
+func TestPlanRollback(t *testing.T) {
+	defer useFakeGCS(map[string]string{
+		"kubernetes-release/release/stable.txt":      "v1.18.3\n",
+		"kubernetes-release/release/stable-1.txt":    "v1.18.3\n",
+		"kubernetes-release/release/stable-1.18.txt": "v1.18.3\n",
+		"kubernetes-release/release/latest.txt":      "v1.19.0-rc.1\n",
+		"kubernetes-release/release/latest-1.txt":    "v1.19.0-rc.1\n",
+	})()
+
+	type want struct {
+		r    []MarkerUpdate
+		rErr bool
+	}
+	cases := map[string]struct {
+		toVersion string
+		channel   ReleaseType
+		force     bool
+		want      want
+	}{
+		"Forced": {
+			toVersion: "v1.18.2",
+			channel:   ReleaseTypeStable,
+			force:     true,
+			want: want{r: []MarkerUpdate{
+				{Marker: "release/stable.txt", Version: "v1.18.2"},
+				{Marker: "release/stable-1.txt", Version: "v1.18.2"},
+				{Marker: "release/stable-1.18.txt", Version: "v1.18.2"},
+			}},
+		},
+		"NotForced": {
+			toVersion: "v1.18.2",
+			channel:   ReleaseTypeStable,
+			want:      want{rErr: true},
+		},
+		"UpToDate": {
+			toVersion: "v1.18.3",
+			channel:   ReleaseTypeStable,
+			want:      want{r: []MarkerUpdate{}},
+		},
+		"Forward": {
+			toVersion: "v1.18.4",
+			channel:   ReleaseTypeStable,
+			force:     true,
+			want:      want{rErr: true},
+		},
+		"NotPublished": {
+			toVersion: "v1.19.0-rc.0",
+			channel:   ReleaseTypeLatest,
+			force:     true,
+			want:      want{rErr: true},
+		},
+		"InvalidVersion": {
+			toVersion: "wrong",
+			channel:   ReleaseTypeStable,
+			force:     true,
+			want:      want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := PlanRollback(tc.toVersion, tc.channel, tc.force)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestExpectedMarkerContent(t *testing.T) {
+	require.Equal(t, "v1.18.3\n", ExpectedMarkerContent("v1.18.3"))
+	require.Equal(t, "v1.18.3\n", ExpectedMarkerContent(" v1.18.3\n\n"))
+}
+
+func TestMarkerEquals(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		want    bool
+	}{
+		"Equal":             {content: "v1.18.3\n", want: true},
+		"NoNewline":         {content: "v1.18.3"},
+		"TrailingNewlines":  {content: "v1.18.3\n\n"},
+		"CarriageReturn":    {content: "v1.18.3\r\n"},
+		"DifferentVersion":  {content: "v1.18.2\n"},
+		"LeadingWhitespace": {content: " v1.18.3\n"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, MarkerEquals([]byte(tc.content), "v1.18.3"))
+		})
+	}
+}
+
+func TestWriteMarker(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	update := MarkerUpdate{Marker: "release/stable-1.18.txt", Version: "v1.18.3"}
+	file := filepath.Join(baseTmpDir, "release", "stable-1.18.txt")
+
+	// A dry run only returns the file
+	res, err := WriteMarkerWithOptions(baseTmpDir, update, &StageOptions{DryRun: true})
+	require.Nil(t, err)
+	require.Equal(t, file, res)
+	_, err = os.Stat(filepath.Dir(file))
+	require.True(t, os.IsNotExist(err))
+
+	require.Nil(t, WriteMarker(baseTmpDir, update))
+	content, err := ioutil.ReadFile(file)
+	require.Nil(t, err)
+	require.True(t, MarkerEquals(content, update.Version))
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// FetchOutcomeSuccess is the outcome of a successful fetch.
+	FetchOutcomeSuccess = "success"
+
+	// FetchOutcomeFailure is the outcome of a failed fetch.
+	FetchOutcomeFailure = "failure"
+
+	kubecrossChannelPrefix = "kube-cross/"
+)
+
+// FetchRecorder receives the outcome of every marker fetch done by
+// GetKubeVersion and GetKubecrossVersion, like the FetchMetrics do. The
+// channel is the name of the marker along with its directory like
+// "release/stable-1.18" or "ci/latest", or "kube-cross/<branch>", and the
+// outcome is either FetchOutcomeSuccess or FetchOutcomeFailure.
+type FetchRecorder interface {
+	RecordFetch(channel, outcome string, latency time.Duration)
+}
+
+// fetchRecorder is the FetchRecorder in use, nil disables recording.
+var fetchRecorder = struct {
+	sync.RWMutex
+	recorder FetchRecorder
+}{}
+
+// SetFetchRecorder sets the recorder for the outcome of all subsequent
+// fetches. Passing nil disables recording, which is the default.
+func SetFetchRecorder(recorder FetchRecorder) {
+	fetchRecorder.Lock()
+	defer fetchRecorder.Unlock()
+	fetchRecorder.recorder = recorder
+}
+
+// recordFetch passes the outcome of a fetch started at `start` to the
+// fetchRecorder, if set.
+func recordFetch(channel string, start time.Time, err error) {
+	fetchRecorder.RLock()
+	recorder := fetchRecorder.recorder
+	fetchRecorder.RUnlock()
+	if recorder == nil {
+		return
+	}
+
+	outcome := FetchOutcomeSuccess
+	if err != nil {
+		outcome = FetchOutcomeFailure
+	}
+	recorder.RecordFetch(channel, outcome, time.Since(start))
+}
+
+// FetchMetrics is a FetchRecorder exporting the marker fetches as Prometheus
+// metrics: the kubernetes_release_marker_fetches_total counter and the
+// kubernetes_release_marker_fetch_duration_seconds histogram, both labeled
+// by channel and outcome.
+type FetchMetrics struct {
+	fetches *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+// NewFetchMetrics creates new FetchMetrics, which are neither registered nor
+// recording fetches yet.
+func NewFetchMetrics() *FetchMetrics {
+	labels := []string{"channel", "outcome"}
+	return &FetchMetrics{
+		fetches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kubernetes_release",
+			Subsystem: "marker",
+			Name:      "fetches_total",
+			Help:      "Number of version marker fetches by channel and outcome.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "kubernetes_release",
+			Subsystem: "marker",
+			Name:      "fetch_duration_seconds",
+			Help:      "Latency of version marker fetches by channel and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+	}
+}
+
+// RecordFetch counts the fetch and observes its latency.
+func (m *FetchMetrics) RecordFetch(channel, outcome string, latency time.Duration) {
+	m.fetches.WithLabelValues(channel, outcome).Inc()
+	m.latency.WithLabelValues(channel, outcome).Observe(latency.Seconds())
+}
+
+// RegisterFetchMetrics registers new FetchMetrics with `registerer` and sets
+// them as the FetchRecorder of all subsequent fetches. Nothing gets
+// registered without a registerer, the package never uses the global
+// Prometheus registry on its own.
+func RegisterFetchMetrics(registerer prometheus.Registerer) (*FetchMetrics, error) {
+	if registerer == nil {
+		return nil, errors.New("registering fetch metrics: no registerer provided")
+	}
+
+	metrics := NewFetchMetrics()
+	if err := registerer.Register(metrics.fetches); err != nil {
+		return nil, errors.Wrap(err, "registering fetch counter")
+	}
+	if err := registerer.Register(metrics.latency); err != nil {
+		registerer.Unregister(metrics.fetches)
+		return nil, errors.Wrap(err, "registering fetch latency histogram")
+	}
+
+	SetFetchRecorder(metrics)
+	return metrics, nil
+}
+
+// markerChannel returns the channel name of a marker URL, for example
+// https://dl.k8s.io/release/stable-1.18.txt becomes release/stable-1.18. The
+// directory keeps markers of the same name apart, like ci/latest.txt and
+// release/latest.txt.
+func markerChannel(markerURL string) string {
+	return path.Join(
+		path.Base(path.Dir(markerURL)),
+		strings.TrimSuffix(path.Base(markerURL), ".txt"),
+	)
+}
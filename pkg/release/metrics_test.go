@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFetchRecorder struct {
+	fetches []string
+}
+
+func (f *fakeFetchRecorder) RecordFetch(channel, outcome string, latency time.Duration) {
+	f.fetches = append(f.fetches, channel+":"+outcome)
+}
+
+func TestFetchRecorder(t *testing.T) {
+	server := newMarkerServer("v1.18.3", time.Time{})
+	defer server.Close()
+
+	defer func(retry *RetryOptions) { defaultRetryOptions = retry }(defaultRetryOptions)
+	defaultRetryOptions = &RetryOptions{}
+
+	recorder := &fakeFetchRecorder{}
+	SetFetchRecorder(recorder)
+	defer SetFetchRecorder(nil)
+
+	_, err := GetKubeVersion(server.URL+"/release/stable-1.18.txt", false)
+	require.Nil(t, err)
+	_, err = GetKubeVersion(server.URL+"/release/latest.txt", false)
+	require.Nil(t, err)
+	_, err = GetKubeVersion("http://localhost:0/ci/latest.txt", false)
+	require.NotNil(t, err)
+
+	require.Equal(t, []string{
+		"release/stable-1.18:success",
+		"release/latest:success",
+		"ci/latest:failure",
+	}, recorder.fetches)
+}
+
+func TestFetchRecorderDisabled(t *testing.T) {
+	server := newMarkerServer("v1.18.3", time.Time{})
+	defer server.Close()
+
+	SetFetchRecorder(nil)
+	res, err := GetKubeVersion(server.URL, false)
+	require.Nil(t, err)
+	require.Equal(t, "v1.18.3", res)
+}
+
+func TestRegisterFetchMetrics(t *testing.T) {
+	server := newMarkerServer("v1.18.3", time.Time{})
+	defer server.Close()
+
+	defer func(retry *RetryOptions) { defaultRetryOptions = retry }(defaultRetryOptions)
+	defaultRetryOptions = &RetryOptions{}
+	defer SetFetchRecorder(nil)
+
+	_, err := RegisterFetchMetrics(nil)
+	require.NotNil(t, err)
+
+	registry := prometheus.NewRegistry()
+	metrics, err := RegisterFetchMetrics(registry)
+	require.Nil(t, err)
+
+	_, err = GetKubeVersion(server.URL+"/release/stable-1.18.txt", false)
+	require.Nil(t, err)
+	_, err = GetKubeVersion(server.URL+"/release/stable-1.18.txt", false)
+	require.Nil(t, err)
+	_, err = GetKubeVersion("http://localhost:0/ci/latest.txt", false)
+	require.NotNil(t, err)
+
+	require.Equal(t, float64(2), testutil.ToFloat64(
+		metrics.fetches.WithLabelValues("release/stable-1.18", FetchOutcomeSuccess),
+	))
+	require.Equal(t, float64(1), testutil.ToFloat64(
+		metrics.fetches.WithLabelValues("ci/latest", FetchOutcomeFailure),
+	))
+
+	// The metrics can only be registered once per registry
+	_, err = RegisterFetchMetrics(registry)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,367 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/util"
+)
+
+const (
+	serverBinPath   = "kubernetes/server/bin/"
+	dockerTagSuffix = ".docker_tag"
+)
+
+var (
+	// platformArtifactRE matches the platform specific release tarballs, for
+	// example kubernetes-client-darwin-amd64.tar.gz.
+	platformArtifactRE = regexp.MustCompile(
+		`^kubernetes-(client|server|node)-([a-z0-9]+)-([a-z0-9]+)\.tar\.gz$`,
+	)
+
+	// nonLinuxComponents are the tarball components built for platforms other
+	// than linux.
+	nonLinuxComponents = map[string][]string{
+		"darwin":  {"client"},
+		"windows": {"client", "node"},
+	}
+)
+
+// DetectBuiltPlatforms returns the platforms the release artifacts in the
+// build output directory `workDir` have been built for, sorted by their
+// string representation.
+func DetectBuiltPlatforms(workDir string) ([]Platform, error) {
+	artifacts, err := ListReleaseArtifacts(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[Platform]bool{}
+	for _, artifact := range artifacts {
+		match := platformArtifactRE.FindStringSubmatch(filepath.Base(artifact))
+		if match == nil {
+			continue
+		}
+		found[Platform{OS: match[2], Arch: match[3]}] = true
+	}
+
+	platforms := []Platform{}
+	for platform := range found {
+		platforms = append(platforms, platform)
+	}
+	sortPlatforms(platforms)
+	return platforms, nil
+}
+
+// PlatformDiff compares the platforms the release artifacts in `workDir` have
+// been built for to the `expected` ones. It returns the expected platforms
+// which have not been built and the built platforms which are not expected,
+// both sorted by their string representation. The expected platforms may use
+// the artifact format for their OS, e.g. Platform{OS: "linux-amd64"}.
+func PlatformDiff(workDir string, expected []Platform) (missing, extra []Platform, err error) {
+	built, err := DetectBuiltPlatforms(workDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	remaining := map[Platform]bool{}
+	for _, platform := range built {
+		remaining[platform.normalize()] = true
+	}
+
+	missing = []Platform{}
+	seen := map[Platform]bool{}
+	for _, platform := range expected {
+		platform = platform.normalize()
+		if seen[platform] {
+			continue
+		}
+		seen[platform] = true
+		if !remaining[platform] {
+			missing = append(missing, platform)
+		}
+		delete(remaining, platform)
+	}
+
+	extra = []Platform{}
+	for platform := range remaining {
+		extra = append(extra, platform)
+	}
+	sortPlatforms(missing)
+	sortPlatforms(extra)
+	return missing, extra, nil
+}
+
+// sortPlatforms sorts `platforms` by their string representation.
+func sortPlatforms(platforms []Platform) {
+	sort.Slice(platforms, func(i, j int) bool {
+		return platforms[i].String() < platforms[j].String()
+	})
+}
+
+// ExpectedPlatformArtifacts returns the release tarballs a complete build for
+// the provided platforms produces. Linux builds contain the artifacts of
+// ExpectedArtifacts, while other operating systems only provide client and,
+// for windows, node tarballs.
+func ExpectedPlatformArtifacts(platforms []Platform) []string {
+	arches := []string{}
+	others := []string{}
+	for _, platform := range platforms {
+		if platform.OS == "linux" {
+			arches = append(arches, platform.Arch)
+			continue
+		}
+		for _, component := range nonLinuxComponents[platform.OS] {
+			others = append(others, fmt.Sprintf(
+				"kubernetes-%s-%s-%s.tar.gz", component, platform.OS, platform.Arch,
+			))
+		}
+	}
+	return append(ExpectedArtifacts(arches), others...)
+}
+
+// VerifyUniformVersion checks that kubernetes.tar.gz and the server tarballs
+// of all architectures in the build output directory `workDir` contain the
+// same version.
+func VerifyUniformVersion(workDir string) error {
+	versions, err := builtVersions(workDir)
+	if err != nil {
+		return err
+	}
+	return verifyUniform("version", versions, func(version string) string {
+		return version
+	})
+}
+
+// VerifyUniformCommit checks that all release tarballs in the build output
+// directory `workDir` have been built from the same commit, which is the build
+// metadata of their version, e.g. e19c4a2b1ec777 for
+// v1.19.0-beta.1.58+e19c4a2b1ec777.
+func VerifyUniformCommit(workDir string) error {
+	versions, err := builtVersions(workDir)
+	if err != nil {
+		return err
+	}
+	return verifyUniform("commit", versions, func(version string) string {
+		sem, err := util.TagStringToSemver(version)
+		if err != nil {
+			return ""
+		}
+		return strings.Join(sem.Build, ".")
+	})
+}
+
+// VerifyMultiArchRelease is the gate for multi-arch releases. It checks that
+// the build output directory `workDir` contains every expected platform with
+// all of its artifacts and that all architectures agree on their version and
+// commit. All failures are reported together.
+func VerifyMultiArchRelease(workDir string, expected []Platform) error {
+	failures := []string{}
+
+	missing, extra, err := PlatformDiff(workDir, expected)
+	if err != nil {
+		return err
+	}
+	for _, platform := range missing {
+		failures = append(failures, fmt.Sprintf("platform %s not built", platform))
+	}
+	for _, platform := range extra {
+		logrus.Warnf("Found unexpected platform %s", platform)
+	}
+
+	for _, artifact := range ExpectedPlatformArtifacts(expected) {
+		if !util.Exists(filepath.Join(workDir, ReleaseTarsPath, artifact)) {
+			failures = append(failures, "missing artifact "+artifact)
+		}
+	}
+
+	for _, verify := range []func(string) error{VerifyUniformVersion, VerifyUniformCommit} {
+		if err := verify(workDir); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.Errorf(
+			"multi-arch release verification failed: %s",
+			strings.Join(failures, "; "),
+		)
+	}
+	return nil
+}
+
+// VerifyComponentMinorConsistency checks that all server components, like
+// kube-apiserver or kube-scheduler, of every server tarball in the build output
+// directory `workDir` share the same major and minor version. The versions
+// are taken from the image tags embedded in the tarballs.
+func VerifyComponentMinorConsistency(workDir string) error {
+	artifacts, err := ListReleaseArtifacts(workDir)
+	if err != nil {
+		return err
+	}
+
+	versions := map[string]string{}
+	for _, artifact := range artifacts {
+		match := platformArtifactRE.FindStringSubmatch(filepath.Base(artifact))
+		if match == nil || match[1] != "server" {
+			continue
+		}
+
+		components, err := readServerComponentVersions(
+			filepath.Join(workDir, ReleaseTarsPath, artifact),
+		)
+		if err != nil {
+			return errors.Wrapf(err, "reading component versions of %s", artifact)
+		}
+		for component, version := range components {
+			versions[artifact+": "+component] = version
+		}
+	}
+	if len(versions) == 0 {
+		return errors.Errorf("no server components found in %s", workDir)
+	}
+
+	return verifyUniform("minor", versions, func(version string) string {
+		sem, err := util.TagStringToSemver(version)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%d.%d", sem.Major, sem.Minor)
+	})
+}
+
+// builtVersions returns the versions of kubernetes.tar.gz and all server
+// tarballs in `workDir` indexed by their artifact path.
+func builtVersions(workDir string) (map[string]string, error) {
+	artifacts, err := ListReleaseArtifacts(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, artifact := range artifacts {
+		name := filepath.Base(artifact)
+		file := filepath.Join(workDir, ReleaseTarsPath, artifact)
+
+		switch match := platformArtifactRE.FindStringSubmatch(name); {
+		case name == kubernetesTar:
+			version, err := ReadVersionFromTarball(file)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading version of %s", artifact)
+			}
+			versions[artifact] = version
+
+		case match != nil && match[1] == "server":
+			version, err := readServerTarballVersion(file)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading version of %s", artifact)
+			}
+			versions[artifact] = version
+		}
+	}
+	return versions, nil
+}
+
+// readServerTarballVersion returns the version of a server tarball based on
+// the image tag of its first docker_tag file. Image tags use an '_' instead of
+// the '+' of the version.
+func readServerTarballVersion(tarballPath string) (string, error) {
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	name, content, err := readFileFromTarReader(file, isServerImageTag)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", errors.Errorf("unable to find image tag in %s", tarballPath)
+	}
+	return imageTagToVersion(content), nil
+}
+
+// readServerComponentVersions returns the versions of all components of a
+// server tarball indexed by the component name, based on their docker_tag
+// files.
+func readServerComponentVersions(tarballPath string) (map[string]string, error) {
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	files, err := readFilesFromTarReader(file, isServerImageTag)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for name, content := range files {
+		component := strings.TrimSuffix(path.Base(name), dockerTagSuffix)
+		versions[component] = imageTagToVersion(content)
+	}
+	return versions, nil
+}
+
+// isServerImageTag returns true if `name` is a docker_tag file of a server
+// tarball.
+func isServerImageTag(name string) bool {
+	return strings.HasPrefix(name, serverBinPath) &&
+		strings.HasSuffix(name, dockerTagSuffix)
+}
+
+// imageTagToVersion converts the content of a docker_tag file back to the
+// version it has been created from.
+func imageTagToVersion(content []byte) string {
+	return strings.ReplaceAll(strings.TrimSpace(string(content)), "_", "+")
+}
+
+// verifyUniform checks that `property` of all `versions` is the same and
+// reports the differing artifacts otherwise.
+func verifyUniform(property string, versions map[string]string, get func(string) string) error {
+	byValue := map[string][]string{}
+	for artifact, version := range versions {
+		value := get(version)
+		byValue[value] = append(byValue[value], artifact)
+	}
+	if len(byValue) < 2 {
+		return nil
+	}
+
+	found := []string{}
+	for value, artifacts := range byValue {
+		if value == "" {
+			value = "<none>"
+		}
+		sort.Strings(artifacts)
+		found = append(found, fmt.Sprintf("%s (%s)", value, strings.Join(artifacts, ", ")))
+	}
+	sort.Strings(found)
+	return errors.Errorf("artifacts differ in %s: %s", property, strings.Join(found, ", "))
+}
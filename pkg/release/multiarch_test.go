@@ -0,0 +1,316 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestBuild creates the release tarballs of `version` for the provided
+// platforms below the ReleaseTarsPath of `workDir`. The server tarballs of
+// linux architectures listed in `serverVersions` contain the provided
+// version instead.
+func writeTestBuild(
+	t *testing.T, workDir, version string, platforms []Platform,
+	serverVersions map[string]string,
+) {
+	releaseTars := filepath.Join(workDir, ReleaseTarsPath)
+	require.Nil(t, os.MkdirAll(releaseTars, os.ModePerm))
+
+	for _, artifact := range ExpectedPlatformArtifacts(platforms) {
+		files := map[string]string{"kubernetes/README.md": "test"}
+		if artifact == kubernetesTar {
+			files[dockerVersionPath] = version
+		} else if strings.HasPrefix(artifact, "kubernetes-server-linux-") {
+			arch := strings.TrimSuffix(
+				strings.TrimPrefix(artifact, "kubernetes-server-linux-"), ".tar.gz",
+			)
+			serverVersion := version
+			if v, ok := serverVersions[arch]; ok {
+				serverVersion = v
+			}
+			files[serverBinPath+"kube-apiserver"+dockerTagSuffix] = strings.ReplaceAll(
+				serverVersion, "+", "_",
+			)
+		}
+		writeTestTarball(t, filepath.Join(releaseTars, artifact), files)
+	}
+}
+
+func TestDetectBuiltPlatforms(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	writeTestArtifacts(t, baseTmpDir, map[string]string{
+		"kubernetes.tar.gz":                           "test",
+		"kubernetes-client-darwin-amd64.tar.gz":       "test",
+		"kubernetes-client-linux-arm64.tar.gz":        "test",
+		"kubernetes-server-linux-arm64.tar.gz":        "test",
+		"kubernetes-node-linux-amd64.tar.gz":          "test",
+		"kubernetes-node-linux-amd64.tar.gz.sha256":   "test",
+		"kubernetes-node-windows-amd64.tar.gz":        "test",
+		"kubernetes-client-windows-amd64.tar.gz.asc":  "test",
+		"kubernetes-client-linux-unknown.tar.gz.test": "test",
+	})
+
+	res, err := DetectBuiltPlatforms(baseTmpDir)
+	require.Nil(t, err)
+	require.Equal(t, []Platform{
+		{"darwin", "amd64"},
+		{"linux", "amd64"},
+		{"linux", "arm64"},
+		{"windows", "amd64"},
+	}, res)
+}
+
+func TestPlatformDiff(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	writeTestArtifacts(t, baseTmpDir, map[string]string{
+		"kubernetes.tar.gz":                     "test",
+		"kubernetes-client-darwin-amd64.tar.gz": "test",
+		"kubernetes-server-linux-amd64.tar.gz":  "test",
+		"kubernetes-server-linux-s390x.tar.gz":  "test",
+	})
+
+	type want struct {
+		missing []Platform
+		extra   []Platform
+	}
+	cases := map[string]struct {
+		expected []Platform
+		want     want
+	}{
+		"Complete": {
+			expected: []Platform{{"linux", "amd64"}, {"linux", "s390x"}, {"darwin", "amd64"}},
+			want:     want{missing: []Platform{}, extra: []Platform{}},
+		},
+		"MissingAndExtra": {
+			expected: []Platform{{"linux", "amd64"}, {"linux", "arm64"}, {"windows", "amd64"}},
+			want: want{
+				missing: []Platform{{"linux", "arm64"}, {"windows", "amd64"}},
+				extra:   []Platform{{"darwin", "amd64"}, {"linux", "s390x"}},
+			},
+		},
+		"Normalized": {
+			expected: []Platform{
+				{OS: "linux-amd64"}, {OS: "linux/s390x"}, {"Darwin", "AMD64"}, {"linux", "amd64"},
+			},
+			want: want{missing: []Platform{}, extra: []Platform{}},
+		},
+		"NoneExpected": {
+			want: want{
+				missing: []Platform{},
+				extra:   []Platform{{"darwin", "amd64"}, {"linux", "amd64"}, {"linux", "s390x"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			missing, extra, err := PlatformDiff(baseTmpDir, tc.expected)
+			require.Nil(t, err)
+			require.Equal(t, tc.want.missing, missing)
+			require.Equal(t, tc.want.extra, extra)
+		})
+	}
+
+	_, _, err = PlatformDiff(filepath.Join(baseTmpDir, "notexisting"), nil)
+	require.NotNil(t, err)
+}
+
+func TestExpectedPlatformArtifacts(t *testing.T) {
+	require.Equal(t, []string{
+		"kubernetes.tar.gz",
+		"kubernetes-src.tar.gz",
+		"kubernetes-manifests.tar.gz",
+		"kubernetes-client-linux-amd64.tar.gz",
+		"kubernetes-server-linux-amd64.tar.gz",
+		"kubernetes-node-linux-amd64.tar.gz",
+		"kubernetes-client-darwin-amd64.tar.gz",
+		"kubernetes-client-windows-amd64.tar.gz",
+		"kubernetes-node-windows-amd64.tar.gz",
+	}, ExpectedPlatformArtifacts([]Platform{
+		{"darwin", "amd64"},
+		{"linux", "amd64"},
+		{"windows", "amd64"},
+	}))
+}
+
+func TestVerifyUniformVersionAndCommit(t *testing.T) {
+	const version = "v1.19.0-beta.1.58+e19c4a2b1ec777"
+	platforms := []Platform{{"linux", "amd64"}, {"linux", "arm64"}}
+
+	cases := map[string]struct {
+		serverVersions map[string]string
+		versionErr     bool
+		commitErr      bool
+	}{
+		"Uniform": {},
+		"DifferentVersion": {
+			serverVersions: map[string]string{"arm64": "v1.19.0-beta.1.59+e19c4a2b1ec777"},
+			versionErr:     true,
+		},
+		"DifferentCommit": {
+			serverVersions: map[string]string{"arm64": "v1.19.0-beta.1.59+aaaaaaaaaaaaaa"},
+			versionErr:     true,
+			commitErr:      true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+			writeTestBuild(t, baseTmpDir, version, platforms, tc.serverVersions)
+
+			err = VerifyUniformVersion(baseTmpDir)
+			require.Equal(t, tc.versionErr, err != nil)
+			err = VerifyUniformCommit(baseTmpDir)
+			require.Equal(t, tc.commitErr, err != nil)
+		})
+	}
+}
+
+func TestVerifyMultiArchRelease(t *testing.T) {
+	const version = "v1.18.3"
+	expected := []Platform{
+		{"darwin", "amd64"},
+		{"linux", "amd64"},
+		{"linux", "arm64"},
+		{"windows", "amd64"},
+	}
+
+	cases := map[string]struct {
+		built          []Platform
+		serverVersions map[string]string
+		remove         string
+		rErr           bool
+	}{
+		"Complete": {
+			built: expected,
+		},
+		"AdditionalPlatform": {
+			built: append([]Platform{{"linux", "s390x"}}, expected...),
+		},
+		"MissingPlatform": {
+			built: expected[:3],
+			rErr:  true,
+		},
+		"MissingArtifact": {
+			built:  expected,
+			remove: kubernetesSrcTar,
+			rErr:   true,
+		},
+		"VersionMismatch": {
+			built:          expected,
+			serverVersions: map[string]string{"arm64": "v1.18.2"},
+			rErr:           true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+			writeTestBuild(t, baseTmpDir, version, tc.built, tc.serverVersions)
+			if tc.remove != "" {
+				require.Nil(t, os.Remove(
+					filepath.Join(baseTmpDir, ReleaseTarsPath, tc.remove),
+				))
+			}
+
+			err = VerifyMultiArchRelease(baseTmpDir, expected)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
+func TestVerifyComponentMinorConsistency(t *testing.T) {
+	cases := map[string]struct {
+		servers      map[string]map[string]string
+		rErr         bool
+		wantOutliers []string
+	}{
+		"Consistent": {
+			servers: map[string]map[string]string{
+				"amd64": {
+					"kube-apiserver": "v1.19.0-beta.1.58_e19c4a2b1ec777",
+					"kube-scheduler": "v1.19.0-beta.1.58_e19c4a2b1ec777",
+				},
+				"arm64": {
+					"kube-apiserver": "v1.19.1",
+					"kube-scheduler": "v1.19.1",
+				},
+			},
+		},
+		"LaggingComponent": {
+			servers: map[string]map[string]string{
+				"amd64": {
+					"kube-apiserver":          "v1.19.1",
+					"kube-controller-manager": "v1.19.1",
+					"kube-scheduler":          "v1.18.5",
+				},
+			},
+			rErr:         true,
+			wantOutliers: []string{"1.18 (kubernetes-server-linux-amd64.tar.gz: kube-scheduler)"},
+		},
+		"NoServerTarballs": {
+			rErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+
+			releaseTars := filepath.Join(baseTmpDir, ReleaseTarsPath)
+			require.Nil(t, os.MkdirAll(releaseTars, os.ModePerm))
+			writeTestTarball(t, filepath.Join(releaseTars, kubernetesTar), map[string]string{
+				dockerVersionPath: "v1.19.1",
+			})
+			for arch, components := range tc.servers {
+				files := map[string]string{"kubernetes/README.md": "test"}
+				for component, tag := range components {
+					files[serverBinPath+component+dockerTagSuffix] = tag
+				}
+				writeTestTarball(t, filepath.Join(
+					releaseTars, "kubernetes-server-linux-"+arch+".tar.gz",
+				), files)
+			}
+
+			err = VerifyComponentMinorConsistency(baseTmpDir)
+			require.Equal(t, tc.rErr, err != nil)
+			for _, outlier := range tc.wantOutliers {
+				require.Contains(t, err.Error(), outlier)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+// packageVersionRE matches versions valid for both a Debian upstream version
+// without revision and a RPM Version tag, which both must not contain
+// hyphens.
+var packageVersionRE = regexp.MustCompile(`^[0-9][A-Za-z0-9.+~]*$`)
+
+// DebianPackageVersion converts the Kubernetes `version` into a Debian
+// upstream version. Pre-releases are separated by a tilde to sort before the
+// final release, and build metadata is appended with a dot, which means
+// v1.20.0-beta.1.58+e19c4a2b1ec777 becomes 1.20.0~beta.1.58.e19c4a2b1ec777.
+func DebianPackageVersion(version string) (string, error) {
+	return packageVersion(version, ".")
+}
+
+// RPMPackageVersion converts the Kubernetes `version` into a RPM version.
+// Pre-releases are separated by a tilde to sort before the final release,
+// while build metadata is kept, which means
+// v1.20.0-beta.1.58+e19c4a2b1ec777 becomes 1.20.0~beta.1.58+e19c4a2b1ec777.
+func RPMPackageVersion(version string) (string, error) {
+	return packageVersion(version, "+")
+}
+
+// packageVersion converts `version` into a package version, where the build
+// metadata is separated by `buildSeparator`.
+func packageVersion(version, buildSeparator string) (string, error) {
+	if IsDirtyBuild(version) {
+		return "", errors.Errorf("dirty build %s cannot be packaged", version)
+	}
+	sem, err := util.TagStringToSemver(strings.TrimSpace(version))
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+
+	res := fmt.Sprintf("%d.%d.%d", sem.Major, sem.Minor, sem.Patch)
+	if len(sem.Pre) > 0 {
+		pre := []string{}
+		for _, part := range sem.Pre {
+			pre = append(pre, part.String())
+		}
+		res += "~" + strings.Join(pre, ".")
+	}
+	if len(sem.Build) > 0 {
+		res += buildSeparator + strings.Join(sem.Build, ".")
+	}
+
+	if !packageVersionRE.MatchString(res) {
+		return "", errors.Errorf("version %s cannot be represented as package version", version)
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageVersions(t *testing.T) {
+	type want struct {
+		deb  string
+		rpm  string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Official": {
+			version: "v1.18.3",
+			want:    want{deb: "1.18.3", rpm: "1.18.3"},
+		},
+		"NoPrefix": {
+			version: "1.18.3",
+			want:    want{deb: "1.18.3", rpm: "1.18.3"},
+		},
+		"PreRelease": {
+			version: "v1.20.0-beta.1",
+			want:    want{deb: "1.20.0~beta.1", rpm: "1.20.0~beta.1"},
+		},
+		"CIBuild": {
+			version: "v1.20.0-beta.1.58+e19c4a2b1ec777",
+			want: want{
+				deb: "1.20.0~beta.1.58.e19c4a2b1ec777",
+				rpm: "1.20.0~beta.1.58+e19c4a2b1ec777",
+			},
+		},
+		"CIBuildAlpha": {
+			version: "v1.21.0-alpha.0.1+a1b2c3d4e5f6a7",
+			want: want{
+				deb: "1.21.0~alpha.0.1.a1b2c3d4e5f6a7",
+				rpm: "1.21.0~alpha.0.1+a1b2c3d4e5f6a7",
+			},
+		},
+		"HyphenInPreRelease": {
+			version: "v1.20.0-rc-1",
+			want:    want{rErr: true},
+		},
+		"Dirty": {
+			version: "v1.20.0-beta.1.58+e19c4a2b1ec777-dirty",
+			want:    want{rErr: true},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			deb, err := DebianPackageVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.deb, deb)
+
+			rpm, err := RPMPackageVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.rpm, rpm)
+		})
+	}
+}
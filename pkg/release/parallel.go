@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"runtime"
+	"sync"
+)
+
+var maxParallelism = struct {
+	sync.Mutex
+	n int
+}{n: runtime.GOMAXPROCS(0)}
+
+// SetMaxParallelism limits the number of concurrent operations, like
+// requests or checksum computations, done by a single function of the
+// package. A value less than one resets the limit to the default, which is
+// GOMAXPROCS.
+func SetMaxParallelism(n int) {
+	maxParallelism.Lock()
+	defer maxParallelism.Unlock()
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	maxParallelism.n = n
+}
+
+// MaxParallelism returns the current limit of concurrent operations set by
+// SetMaxParallelism.
+func MaxParallelism() int {
+	maxParallelism.Lock()
+	defer maxParallelism.Unlock()
+	return maxParallelism.n
+}
+
+// runParallel calls `fn` for every index from zero to `count` - 1, running at
+// most MaxParallelism calls at once. It waits for all calls and returns the
+// error of the lowest failed index.
+func runParallel(count int, fn func(i int) error) error {
+	return runParallelLimit(count, MaxParallelism(), fn)
+}
+
+// runParallelLimit is runParallel, which runs at most `n` calls at once.
+func runParallelLimit(count, n int, fn func(i int) error) error {
+	if n < 1 {
+		n = 1
+	}
+	errs := make([]error, count)
+	limit := make(chan struct{}, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMaxParallelism(t *testing.T) {
+	defer SetMaxParallelism(0)
+
+	SetMaxParallelism(3)
+	require.Equal(t, 3, MaxParallelism())
+
+	SetMaxParallelism(0)
+	require.Equal(t, runtime.GOMAXPROCS(0), MaxParallelism())
+}
+
+func TestRunParallel(t *testing.T) {
+	defer SetMaxParallelism(0)
+	SetMaxParallelism(2)
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	called := make([]bool, 10)
+	require.Nil(t, runParallel(len(called), func(i int) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		called[i] = true
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}))
+	require.Equal(t, 2, maxRunning)
+	for _, c := range called {
+		require.True(t, c)
+	}
+
+	// The error of the lowest index is returned
+	err := runParallel(5, func(i int) error {
+		if i >= 2 {
+			return errors.Errorf("failed %d", i)
+		}
+		return nil
+	})
+	require.NotNil(t, err)
+	require.Equal(t, "failed 2", err.Error())
+
+	require.Nil(t, runParallel(0, func(int) error { return errors.New("not called") }))
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/util"
+)
+
+// GetLatestPatchVersion returns the newest patch release of the minor
+// version `minor`, which is of the form "1.20" or "v1.20", for example v1.20.11.
+// The version is retrieved from the stable marker of the minor, like
+// stable-1.20.txt, the same way as ListPatchVersions does.
+func GetLatestPatchVersion(minor string) (string, error) {
+	parts := strings.Split(util.TrimTagPrefix(strings.TrimSpace(minor)), ".")
+	if len(parts) != 2 {
+		return "", errors.Errorf("invalid minor version %q, expected <major>.<minor>", minor)
+	}
+	major, majorErr := strconv.Atoi(parts[0])
+	minorNum, minorErr := strconv.Atoi(parts[1])
+	if majorErr != nil || minorErr != nil || major < 0 || minorNum < 0 {
+		return "", errors.Errorf("invalid minor version %q, expected <major>.<minor>", minor)
+	}
+
+	latest, _, err := latestPatch(major, minorNum)
+	if err != nil {
+		return "", err
+	}
+	return latest, nil
+}
+
+// ListPatchVersions returns all official patch releases of the minor version
+// `major`.`minor` in ascending order. The newest patch release is taken from
+// the stable marker of the minor, for example stable-1.20.txt. Every patch
+// release up to it is only listed if its kubernetes.tar.gz is published, for
+// example at https://dl.k8s.io/release/v1.20.3/kubernetes.tar.gz.
+func ListPatchVersions(major, minor int) ([]string, error) {
+	if major < 0 || minor < 0 {
+		return nil, errors.Errorf("invalid minor version %d.%d", major, minor)
+	}
+
+	_, sem, err := latestPatch(major, minor)
+	if err != nil {
+		return nil, err
+	}
+
+	published := make([]bool, sem.Patch+1)
+	if err := runParallel(len(published), func(patch int) error {
+		version := fmt.Sprintf("v%d.%d.%d", major, minor, patch)
+		tarURL, err := ReleaseDownloadURL(version, kubernetesTar)
+		if err != nil {
+			return err
+		}
+		exists, err := urlExists(tarURL)
+		if err != nil {
+			return errors.Wrapf(err, "checking release %s", version)
+		}
+		if !exists {
+			logrus.Debugf("Skipping unpublished patch release %s", version)
+		}
+		published[patch] = exists
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for patch, exists := range published {
+		if exists {
+			versions = append(versions, fmt.Sprintf("v%d.%d.%d", major, minor, patch))
+		}
+	}
+	return versions, nil
+}
+
+// LastNPatches returns the newest `n` patch releases of the minor version
+// `major`.`minor`, sorted descending. Fewer versions are returned if the
+// minor does not have `n` patch releases yet.
+func LastNPatches(major, minor, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, errors.Errorf("number of patches must be positive, got %d", n)
+	}
+
+	versions, err := ListPatchVersions(major, minor)
+	if err != nil {
+		return nil, err
+	}
+	sorted, err := SortVersions(versions)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := []string{}
+	for i := len(sorted) - 1; i >= 0 && len(latest) < n; i-- {
+		latest = append(latest, sorted[i])
+	}
+	return latest, nil
+}
+
+// latestPatch returns the newest patch release of the minor version
+// `major`.`minor` from its stable marker, unparsed and as SemVer. The error
+// keeps a 404 of the marker as its cause, which means that no release of the
+// minor has been published yet.
+func latestPatch(major, minor int) (string, semver.Version, error) {
+	marker := fmt.Sprintf("%s/stable-%d.%d.txt", downloadURLBase, major, minor)
+	latest, err := fetchMarker(context.Background(), marker, nil)
+	if statusErr, ok := errors.Cause(err).(*statusError); ok && statusErr.code == http.StatusNotFound {
+		return "", semver.Version{}, errors.Wrapf(
+			err, "no releases of %d.%d published yet", major, minor,
+		)
+	}
+	if err != nil {
+		return "", semver.Version{}, errors.Wrapf(
+			err, "retrieving latest patch release of %d.%d", major, minor,
+		)
+	}
+
+	sem, err := util.TagStringToSemver(latest)
+	if err != nil {
+		return "", semver.Version{}, errors.Wrapf(err, "parsing version %s of %s", latest, marker)
+	}
+	if sem.Major != uint64(major) || sem.Minor != uint64(minor) {
+		return "", semver.Version{}, errors.Errorf(
+			"marker %s points to version %s of another minor", marker, latest,
+		)
+	}
+	return latest, sem, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastNPatches(t *testing.T) {
+	defer newStableMarkersServer(map[string]string{
+		"stable-1.20.txt": "v1.20.6",
+		"stable-1.21.txt": "v1.21.1",
+		"stable-1.22.txt": "v1.21.4",
+	})()
+
+	type want struct {
+		r    []string
+		rErr bool
+	}
+	cases := map[string]struct {
+		major, minor, n int
+		want            want
+	}{
+		"LastFive": {
+			major: 1, minor: 20, n: 5,
+			want: want{r: []string{
+				"v1.20.6", "v1.20.5", "v1.20.4", "v1.20.3", "v1.20.2",
+			}},
+		},
+		"FewerAvailable": {
+			major: 1, minor: 21, n: 5,
+			want: want{r: []string{"v1.21.1", "v1.21.0"}},
+		},
+		"Latest": {
+			major: 1, minor: 20, n: 1,
+			want: want{r: []string{"v1.20.6"}},
+		},
+		"NoMarker": {
+			major: 1, minor: 10, n: 5,
+			want: want{rErr: true},
+		},
+		"MarkerOfOtherMinor": {
+			major: 1, minor: 22, n: 5,
+			want: want{rErr: true},
+		},
+		"InvalidN": {
+			major: 1, minor: 20, n: 0,
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := LastNPatches(tc.major, tc.minor, tc.n)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestGetLatestPatchVersion(t *testing.T) {
+	defer newStableMarkersServer(map[string]string{
+		"stable-1.20.txt": "v1.20.11\n",
+		"stable-1.22.txt": "v1.21.4",
+	})()
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		minor string
+		want  want
+	}{
+		"Minor":              {minor: "1.20", want: want{r: "v1.20.11"}},
+		"WithPrefix":         {minor: "v1.20", want: want{r: "v1.20.11"}},
+		"NotReleased":        {minor: "1.23", want: want{rErr: true}},
+		"MarkerOfOtherMinor": {minor: "1.22", want: want{rErr: true}},
+		"PatchVersion":       {minor: "1.20.1", want: want{rErr: true}},
+		"Invalid":            {minor: "one.twenty", want: want{rErr: true}},
+		"Empty":              {minor: "", want: want{rErr: true}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := GetLatestPatchVersion(tc.minor)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestListPatchVersions(t *testing.T) {
+	defer newStableMarkersServer(map[string]string{
+		"stable-1.20.txt": "v1.20.4",
+	}, "v1.20.2")()
+
+	res, err := ListPatchVersions(1, 20)
+	require.Nil(t, err)
+	require.Equal(t, []string{"v1.20.0", "v1.20.1", "v1.20.3", "v1.20.4"}, res)
+
+	res, err = LastNPatches(1, 20, 3)
+	require.Nil(t, err)
+	require.Equal(t, []string{"v1.20.4", "v1.20.3", "v1.20.1"}, res)
+}
+
+func TestListPatchVersionsNotPublished(t *testing.T) {
+	defer newStableMarkersServer(map[string]string{
+		"stable-1.20.txt": "v1.20.6",
+	})()
+
+	res, err := ListPatchVersions(1, 23)
+	require.NotNil(t, err)
+	require.Nil(t, res)
+	require.Contains(t, err.Error(), "no releases of 1.23 published yet")
+
+	// The 404 stays available to callers skipping unreleased minors
+	statusErr, ok := errors.Cause(err).(*statusError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusNotFound, statusErr.code)
+}
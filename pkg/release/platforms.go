@@ -0,0 +1,137 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+// Platform is an operating system and architecture combination Kubernetes
+// binaries are built for.
+type Platform struct {
+	OS   string
+	Arch string
+}
+
+// String returns the platform in the format <os>/<arch>, e.g. linux/amd64.
+func (p Platform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// ParsePlatform parses a platform in the format <os>/<arch>, like
+// linux/amd64, or in the artifact format <os>-<arch>, like linux-amd64.
+func ParsePlatform(platform string) (Platform, error) {
+	parts := strings.FieldsFunc(
+		strings.ToLower(strings.TrimSpace(platform)),
+		func(r rune) bool { return r == '/' || r == '-' },
+	)
+	if len(parts) != 2 {
+		return Platform{}, errors.Errorf("invalid platform %q", platform)
+	}
+	return Platform{OS: parts[0], Arch: parts[1]}, nil
+}
+
+// normalize returns the platform with lower case fields. A platform string
+// in the OS field, for example Platform{OS: "linux-amd64"}, is split up.
+func (p Platform) normalize() Platform {
+	if p.Arch == "" {
+		if parsed, err := ParsePlatform(p.OS); err == nil {
+			return parsed
+		}
+	}
+	return Platform{
+		OS:   strings.ToLower(strings.TrimSpace(p.OS)),
+		Arch: strings.ToLower(strings.TrimSpace(p.Arch)),
+	}
+}
+
+var linuxPlatforms = []Platform{
+	{"linux", "amd64"},
+	{"linux", "386"},
+	{"linux", "arm"},
+	{"linux", "arm64"},
+	{"linux", "ppc64le"},
+	{"linux", "s390x"},
+}
+
+// platformMatrix contains the supported platforms per release line, where
+// every entry applies from its minor version on until the next entry. The
+// entries have to be sorted by version. If the matrix changes, add a new entry
+// with the complete list of platforms for the new minor.
+var platformMatrix = []struct {
+	since     semver.Version
+	platforms []Platform
+}{
+	{
+		since: semver.Version{Major: 1, Minor: 14},
+		platforms: append([]Platform{
+			{"darwin", "amd64"},
+			{"darwin", "386"},
+			{"windows", "amd64"},
+			{"windows", "386"},
+		}, linuxPlatforms...),
+	},
+	{
+		// darwin/386 got dropped together with the support in go1.15
+		since: semver.Version{Major: 1, Minor: 19},
+		platforms: append([]Platform{
+			{"darwin", "amd64"},
+			{"windows", "amd64"},
+			{"windows", "386"},
+		}, linuxPlatforms...),
+	},
+	{
+		since: semver.Version{Major: 1, Minor: 21},
+		platforms: append([]Platform{
+			{"darwin", "amd64"},
+			{"darwin", "arm64"},
+			{"windows", "amd64"},
+			{"windows", "386"},
+		}, linuxPlatforms...),
+	},
+}
+
+// SupportedPlatforms returns the platforms binaries are expected to be built
+// for in the release line of the provided version.
+func SupportedPlatforms(version string) ([]Platform, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing version %s", version)
+	}
+	minor := semver.Version{Major: sem.Major, Minor: sem.Minor}
+
+	var platforms []Platform
+	for _, entry := range platformMatrix {
+		if minor.LT(entry.since) {
+			break
+		}
+		platforms = entry.platforms
+	}
+
+	if platforms == nil {
+		return nil, errors.Errorf("no platform information available for version %s", version)
+	}
+
+	res := make([]Platform, len(platforms))
+	copy(res, platforms)
+	return res, nil
+}
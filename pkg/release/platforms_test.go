@@ -0,0 +1,113 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlatformMatrixSorted(t *testing.T) {
+	for i := 1; i < len(platformMatrix); i++ {
+		require.True(t, platformMatrix[i-1].since.LT(platformMatrix[i].since))
+	}
+}
+
+func TestSupportedPlatforms(t *testing.T) {
+	type want struct {
+		contains    []Platform
+		notContains []Platform
+		rErr        bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"1.18": {
+			version: "v1.18.3",
+			want: want{
+				contains:    []Platform{{"linux", "amd64"}, {"darwin", "386"}},
+				notContains: []Platform{{"darwin", "arm64"}},
+			},
+		},
+		"1.19": {
+			version: "v1.19.0-rc.1",
+			want: want{
+				contains:    []Platform{{"linux", "s390x"}, {"windows", "amd64"}},
+				notContains: []Platform{{"darwin", "386"}, {"darwin", "arm64"}},
+			},
+		},
+		"1.21 CI build": {
+			version: "v1.21.0-alpha.0.58+e19c4a2b1ec777",
+			want: want{
+				contains: []Platform{{"darwin", "arm64"}},
+			},
+		},
+		"Too old": {
+			version: "v1.13.0",
+			want:    want{rErr: true},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := SupportedPlatforms(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			for _, p := range tc.want.contains {
+				require.Contains(t, res, p)
+			}
+			for _, p := range tc.want.notContains {
+				require.NotContains(t, res, p)
+			}
+		})
+	}
+}
+
+func TestPlatformString(t *testing.T) {
+	require.Equal(t, "linux/amd64", Platform{"linux", "amd64"}.String())
+}
+
+func TestParsePlatform(t *testing.T) {
+	type want struct {
+		r    Platform
+		rErr bool
+	}
+	cases := map[string]struct {
+		platform string
+		want     want
+	}{
+		"Slash":     {platform: "linux/amd64", want: want{r: Platform{"linux", "amd64"}}},
+		"Dash":      {platform: "linux-arm64", want: want{r: Platform{"linux", "arm64"}}},
+		"UpperCase": {platform: " Darwin/AMD64 ", want: want{r: Platform{"darwin", "amd64"}}},
+		"OnlyOS":    {platform: "linux", want: want{rErr: true}},
+		"TooMany":   {platform: "linux/amd64/v2", want: want{rErr: true}},
+		"Empty":     {want: want{rErr: true}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParsePlatform(tc.platform)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
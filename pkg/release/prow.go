@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ProwBucket is the bucket Prow uploads the job artifacts to.
+	ProwBucket = "kubernetes-jenkins"
+
+	prowLogsDir      = "logs"
+	prowFinishedJSON = "finished.json"
+)
+
+// prowFinished is the part of a Prow job's finished.json which records the
+// tested version.
+type prowFinished struct {
+	JobVersion string `json:"job-version"`
+	Version    string `json:"version"`
+	Metadata   struct {
+		JobVersion string `json:"job-version"`
+	} `json:"metadata"`
+}
+
+// ProwJobPath returns the GCS location of the artifacts of a Prow job run,
+// for example gs://kubernetes-jenkins/logs/ci-kubernetes-build/1234.
+func ProwJobPath(jobName, buildID string) (string, error) {
+	for _, value := range []string{jobName, buildID} {
+		if value == "" || value == "." || value == ".." || strings.Contains(value, "/") {
+			return "", errors.Errorf("invalid job %q or build ID %q", jobName, buildID)
+		}
+	}
+	return JoinGCSPath(ProwBucket, prowLogsDir, jobName, buildID), nil
+}
+
+// GetVersionFromProwJob returns the Kubernetes version the build `buildID` of
+// the Prow job `jobName` produced, as recorded in its finished.json, which is
+// read using the GCS client.
+func GetVersionFromProwJob(jobName, buildID string) (string, error) {
+	return GetVersionFromProwJobWithContext(context.Background(), jobName, buildID)
+}
+
+// GetVersionFromProwJobWithContext is GetVersionFromProwJob, where reading
+// the finished.json gets aborted if `ctx` is cancelled.
+func GetVersionFromProwJobWithContext(ctx context.Context, jobName, buildID string) (string, error) {
+	jobPath, err := ProwJobPath(jobName, buildID)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("Retrieving version of build %s of job %s", buildID, jobName)
+	content, err := readGCSObject(ctx, JoinGCSPath(jobPath, prowFinishedJSON))
+	if errors.Cause(err) == ErrObjectNotFound {
+		return "", errors.Errorf(
+			"build %s of job %s not found or not finished (no %s in %s)",
+			buildID, jobName, prowFinishedJSON, jobPath,
+		)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s of job %s", prowFinishedJSON, jobName)
+	}
+
+	finished := &prowFinished{}
+	if err := json.Unmarshal(content, finished); err != nil {
+		return "", errors.Wrapf(err, "parsing %s of job %s", prowFinishedJSON, jobName)
+	}
+
+	version := finished.Metadata.JobVersion
+	for _, v := range []string{finished.JobVersion, finished.Version} {
+		if version == "" {
+			version = v
+		}
+	}
+	if version == "" {
+		return "", errors.Errorf(
+			"build %s of job %s did not record a version", buildID, jobName,
+		)
+	}
+
+	valid, err := IsValidReleaseBuild(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "validating version %s", version)
+	}
+	if !valid {
+		return "", errors.Errorf(
+			"build %s of job %s recorded an invalid version: %s", buildID, jobName, version,
+		)
+	}
+	return version, nil
+}
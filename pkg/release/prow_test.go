@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProwJobPath(t *testing.T) {
+	res, err := ProwJobPath("ci-kubernetes-build", "1234")
+	require.Nil(t, err)
+	require.Equal(t, "gs://kubernetes-jenkins/logs/ci-kubernetes-build/1234", res)
+
+	for _, args := range [][]string{
+		{"", "1234"},
+		{"ci-kubernetes-build", ""},
+		{"ci-kubernetes-build", "../1234"},
+		{"..", "1234"},
+	} {
+		_, err := ProwJobPath(args[0], args[1])
+		require.NotNil(t, err)
+	}
+}
+
+func TestGetVersionFromProwJob(t *testing.T) {
+	objects := fakeGCSObjects{
+		"kubernetes-jenkins/logs/forbidden/1/finished.json": {
+			err: errors.New("googleapi: Error 403: Access denied, forbidden"),
+		},
+	}
+	for name, content := range map[string]string{
+		"kubernetes-jenkins/logs/metadata/1/finished.json": `{
+			"passed": true,
+			"metadata": {"job-version": "v1.19.0-beta.1.58+e19c4a2b1ec777"}
+		}`,
+		"kubernetes-jenkins/logs/legacy/1/finished.json": `{
+			"passed": true,
+			"job-version": "v1.18.3"
+		}`,
+		"kubernetes-jenkins/logs/unversioned/1/finished.json": `{"passed": true}`,
+		"kubernetes-jenkins/logs/invalid/1/finished.json":     `{"version": "wrong"}`,
+		"kubernetes-jenkins/logs/malformed/1/finished.json":   `{`,
+	} {
+		objects[name] = &fakeGCSObject{content: content}
+	}
+	defer useGCSObjects(objects)()
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		job  string
+		want want
+	}{
+		"Metadata": {
+			job:  "metadata",
+			want: want{r: "v1.19.0-beta.1.58+e19c4a2b1ec777"},
+		},
+		"Legacy": {
+			job:  "legacy",
+			want: want{r: "v1.18.3"},
+		},
+		"Unversioned": {
+			job:  "unversioned",
+			want: want{rErr: true},
+		},
+		"InvalidVersion": {
+			job:  "invalid",
+			want: want{rErr: true},
+		},
+		"Malformed": {
+			job:  "malformed",
+			want: want{rErr: true},
+		},
+		"NotFound": {
+			job:  "notexisting",
+			want: want{rErr: true},
+		},
+		"Forbidden": {
+			job:  "forbidden",
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := GetVersionFromProwJob(tc.job, "1")
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+
+	// Only missing objects are reported as missing builds
+	_, err := GetVersionFromProwJob("notexisting", "1")
+	require.Contains(t, err.Error(), "not found or not finished")
+	_, err = GetVersionFromProwJob("forbidden", "1")
+	require.Contains(t, err.Error(), "403")
+	require.NotContains(t, err.Error(), "not found or not finished")
+}
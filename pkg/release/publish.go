@@ -0,0 +1,214 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// markerContentType is the expected content type of version markers.
+	markerContentType = "text/plain"
+
+	// markerMaxCacheAge is the maximum number of seconds version markers may
+	// be cached, since they have to reflect a new release immediately.
+	markerMaxCacheAge = 60
+)
+
+// artifactContentTypes are the content types accepted for release tarballs.
+var artifactContentTypes = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-tar",
+	"application/octet-stream",
+}
+
+// IsAlreadyPublished returns true if the artifacts of `version` already exist
+// in GCS and all markers of `channel` already point to it, which means that
+// publishing it again would be a no-op. An error is returned if the markers
+// point to the version while its artifacts are missing.
+func IsAlreadyPublished(version string, channel ReleaseType) (bool, error) {
+	updates, err := PlanMarkerUpdates(version, channel)
+	if err != nil {
+		return false, err
+	}
+
+	bucket, versionPath, err := publishedLocation(version, channel)
+	if err != nil {
+		return false, err
+	}
+
+	artifactsExist, err := GCSObjectExists(JoinGCSPath(versionPath, kubernetesTar))
+	if err != nil {
+		return false, errors.Wrapf(err, "checking artifacts of %s", version)
+	}
+
+	markersPublished := true
+	for _, update := range updates {
+		content, err := ReadGCSObject(JoinGCSPath(bucket, update.Marker))
+		if errors.Cause(err) == ErrObjectNotFound {
+			markersPublished = false
+			continue
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "reading marker %s", update.Marker)
+		}
+		if !MarkerEquals(content, version) {
+			markersPublished = false
+		}
+	}
+
+	if markersPublished && !artifactsExist {
+		return false, errors.Errorf(
+			"markers of %s point to %s, but its artifacts are missing in %s",
+			channel, version, versionPath,
+		)
+	}
+
+	logrus.Infof(
+		"Version %s artifacts published: %t, %s markers published: %t",
+		version, artifactsExist, channel, markersPublished,
+	)
+	return artifactsExist && markersPublished, nil
+}
+
+// VerifyObjectMetadata checks that the published artifacts of `version` and
+// the markers of `channel` are served with the expected metadata. Markers have
+// to be plain text which is not cached for longer than a minute, while
+// tarballs have to use an archive content type and may be cached. The returned
+// error lists all objects violating the policy.
+func VerifyObjectMetadata(version string, channel ReleaseType) error {
+	updates, err := PlanMarkerUpdates(version, channel)
+	if err != nil {
+		return err
+	}
+	bucket, versionPath, err := publishedLocation(version, channel)
+	if err != nil {
+		return err
+	}
+
+	mismatches := []string{}
+	check := func(gcsPath string, verify func(*ObjectMetadata) []string) error {
+		metadata, err := ReadGCSObjectMetadata(gcsPath)
+		if errors.Cause(err) == ErrObjectNotFound {
+			mismatches = append(mismatches, gcsPath+" does not exist")
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "reading metadata of %s", gcsPath)
+		}
+		for _, problem := range verify(metadata) {
+			mismatches = append(mismatches, gcsPath+" "+problem)
+		}
+		return nil
+	}
+
+	for _, artifact := range ExpectedArtifacts(nil) {
+		if err := check(JoinGCSPath(versionPath, artifact), verifyArtifactMetadata); err != nil {
+			return err
+		}
+	}
+	for _, update := range updates {
+		if err := check(JoinGCSPath(bucket, update.Marker), verifyMarkerMetadata); err != nil {
+			return err
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return errors.Errorf(
+			"object metadata verification failed: %s", strings.Join(mismatches, "; "),
+		)
+	}
+	return nil
+}
+
+// publishedLocation returns the bucket containing the markers of `channel`
+// and the gs:// path the artifacts of `version` are published to.
+func publishedLocation(version string, channel ReleaseType) (bucket, versionPath string, err error) {
+	if channel == ReleaseTypeCI {
+		versionPath = CIObjectPath(version)
+		if versionPath == "" {
+			return "", "", errors.Errorf("invalid CI version %s", version)
+		}
+		return GetReleaseBucket(CIBucketSuffix), versionPath, nil
+	}
+	bucket = GetReleaseBucket("")
+	return bucket, JoinGCSPath(bucket, releaseMarkerDir, version), nil
+}
+
+// verifyMarkerMetadata returns the policy violations of the metadata of a
+// version marker.
+func verifyMarkerMetadata(metadata *ObjectMetadata) []string {
+	problems := []string{}
+	if mediaType(metadata.ContentType) != markerContentType {
+		problems = append(problems, fmt.Sprintf(
+			"has content type %q, expected %q", metadata.ContentType, markerContentType,
+		))
+	}
+	if age, ok := maxCacheAge(metadata.CacheControl); !ok || age > markerMaxCacheAge {
+		problems = append(problems, fmt.Sprintf(
+			"has cache control %q, expected a max-age of at most %ds",
+			metadata.CacheControl, markerMaxCacheAge,
+		))
+	}
+	return problems
+}
+
+// verifyArtifactMetadata returns the policy violations of the metadata of a
+// release tarball.
+func verifyArtifactMetadata(metadata *ObjectMetadata) []string {
+	contentType := mediaType(metadata.ContentType)
+	for _, allowed := range artifactContentTypes {
+		if contentType == allowed {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf(
+		"has content type %q, expected one of %s",
+		metadata.ContentType, strings.Join(artifactContentTypes, ", "),
+	)}
+}
+
+// mediaType returns the content type without parameters like the charset.
+func mediaType(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+// maxCacheAge returns the number of seconds the Cache-Control header value
+// `cacheControl` allows caching. The result is false if it does not limit
+// caching at all.
+func maxCacheAge(cacheControl string) (int, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache", directive == "no-store":
+			return 0, true
+		case strings.HasPrefix(directive, "max-age="):
+			age, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil {
+				return 0, false
+			}
+			return age, true
+		}
+	}
+	return 0, false
+}
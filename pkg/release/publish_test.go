@@ -0,0 +1,201 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAlreadyPublished(t *testing.T) {
+	const ciVersion = "v1.19.0-beta.1.58+e19c4a2b1ec777"
+	cleanup := useFakeGCS(map[string]string{
+		"kubernetes-release/release/v1.18.3/kubernetes.tar.gz": "test",
+		"kubernetes-release/release/stable.txt":                "v1.18.3\n",
+		"kubernetes-release/release/stable-1.txt":              "v1.18.3\n",
+		"kubernetes-release/release/stable-1.18.txt":           "v1.18.3\n",
+
+		"kubernetes-release/release/v1.18.2/kubernetes.tar.gz": "test",
+
+		"kubernetes-release/release/latest.txt":      "v1.19.0-rc.1\n",
+		"kubernetes-release/release/latest-1.txt":    "v1.19.0-rc.1\n",
+		"kubernetes-release/release/latest-1.19.txt": "v1.19.0-rc.1\n",
+
+		"kubernetes-release-dev/ci/" + ciVersion + "/kubernetes.tar.gz": "test",
+		"kubernetes-release-dev/ci/latest.txt":                          ciVersion + "\n",
+		"kubernetes-release-dev/ci/latest-1.txt":                        ciVersion + "\n",
+		"kubernetes-release-dev/ci/latest-1.19.txt":                     ciVersion + "\n",
+	})
+	defer cleanup()
+
+	type want struct {
+		r    bool
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		channel ReleaseType
+		want    want
+	}{
+		"Published": {
+			version: "v1.18.3",
+			channel: ReleaseTypeStable,
+			want:    want{r: true},
+		},
+		"MarkersNotUpdated": {
+			version: "v1.18.2",
+			channel: ReleaseTypeStable,
+		},
+		"NotPublished": {
+			version: "v1.18.4",
+			channel: ReleaseTypeStable,
+		},
+		"MarkersWithoutArtifacts": {
+			version: "v1.19.0-rc.1",
+			channel: ReleaseTypeLatest,
+			want:    want{rErr: true},
+		},
+		"CIPublished": {
+			version: ciVersion,
+			channel: ReleaseTypeCI,
+			want:    want{r: true},
+		},
+		"InvalidChannel": {
+			version: "v1.18.3",
+			channel: ReleaseType("wrong"),
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := IsAlreadyPublished(tc.version, tc.channel)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+// useGCSMetadata replaces the GCS client with one serving the provided
+// objects, keyed by "bucket/object", with their metadata until the returned
+// function is called.
+func useGCSMetadata(objects map[string]ObjectMetadata) func() {
+	fake := fakeGCSObjects{}
+	for name, metadata := range objects {
+		fake[name] = &fakeGCSObject{attrs: storage.ObjectAttrs{
+			ContentType:  metadata.ContentType,
+			CacheControl: metadata.CacheControl,
+		}}
+	}
+	return useGCSObjects(fake)
+}
+
+func TestVerifyObjectMetadata(t *testing.T) {
+	marker := ObjectMetadata{
+		ContentType:  "text/plain; charset=utf-8",
+		CacheControl: "private, max-age=0, no-transform",
+	}
+	tarball := ObjectMetadata{
+		ContentType:  "application/x-tar",
+		CacheControl: "public, max-age=3600",
+	}
+	objects := map[string]ObjectMetadata{
+		"kubernetes-release/release/stable-1.txt":    marker,
+		"kubernetes-release/release/stable-1.18.txt": marker,
+	}
+	for _, version := range []string{"v1.18.3", "v1.18.2"} {
+		for _, artifact := range ExpectedArtifacts(nil) {
+			objects["kubernetes-release/release/"+version+"/"+artifact] = tarball
+		}
+	}
+
+	cases := map[string]struct {
+		version    string
+		stable     ObjectMetadata
+		mismatches []string
+	}{
+		"Valid": {
+			version: "v1.18.3",
+			stable:  marker,
+		},
+		"CachedMarker": {
+			version: "v1.18.3",
+			stable: ObjectMetadata{
+				ContentType:  "text/plain",
+				CacheControl: "public, max-age=3600",
+			},
+			mismatches: []string{"release/stable.txt has cache control"},
+		},
+		"NoCacheControl": {
+			version:    "v1.18.3",
+			stable:     ObjectMetadata{ContentType: "text/plain"},
+			mismatches: []string{"release/stable.txt has cache control"},
+		},
+		"WrongContentType": {
+			version: "v1.18.3",
+			stable: ObjectMetadata{
+				ContentType:  "application/octet-stream",
+				CacheControl: "no-cache",
+			},
+			mismatches: []string{"release/stable.txt has content type"},
+		},
+		"MissingArtifacts": {
+			version: "v1.18.4",
+			stable:  marker,
+			mismatches: []string{
+				"release/v1.18.4/kubernetes.tar.gz does not exist",
+				"release/v1.18.4/kubernetes-src.tar.gz does not exist",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			objects["kubernetes-release/release/stable.txt"] = tc.stable
+			defer useGCSMetadata(objects)()
+
+			err := VerifyObjectMetadata(tc.version, ReleaseTypeStable)
+			require.Equal(t, len(tc.mismatches) > 0, err != nil)
+			for _, mismatch := range tc.mismatches {
+				require.Contains(t, err.Error(), mismatch)
+			}
+		})
+	}
+}
+
+func TestVerifyObjectMetadataWrongArtifactType(t *testing.T) {
+	objects := map[string]ObjectMetadata{}
+	for _, artifact := range ExpectedArtifacts(nil) {
+		objects["kubernetes-release/release/v1.18.3/"+artifact] = ObjectMetadata{
+			ContentType: "text/html",
+		}
+	}
+	for _, marker := range []string{"stable.txt", "stable-1.txt", "stable-1.18.txt"} {
+		objects["kubernetes-release/release/"+marker] = ObjectMetadata{
+			ContentType: "text/plain", CacheControl: "no-store",
+		}
+	}
+	defer useGCSMetadata(objects)()
+
+	err := VerifyObjectMetadata("v1.18.3", ReleaseTypeStable)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), `kubernetes.tar.gz has content type "text/html"`)
+
+	require.NotNil(t, VerifyObjectMetadata("v1.18.3", ReleaseType("wrong")))
+}
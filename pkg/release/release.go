@@ -17,14 +17,25 @@ limitations under the License.
 package release
 
 import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/pkg/errors"
@@ -36,11 +47,10 @@ import (
 
 const (
 	// gcbmgr/anago defaults
-	DefaultToolRepo   = "release"
-	DefaultToolBranch = "master"
-	DefaultProject    = "kubernetes-release-test"
-	DefaultDiskSize   = "300"
-	BucketPrefix      = "kubernetes-release-"
+	DefaultToolRepo = "release"
+	DefaultProject  = "kubernetes-release-test"
+	DefaultDiskSize = "300"
+	BucketPrefix    = "kubernetes-release-"
 
 	versionReleaseRE  = `v(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[a-zA-Z0-9]+)*\.*(0|[1-9][0-9]*)?`
 	versionBuildRE    = `([0-9]{1,})\+([0-9a-f]{5,40})`
@@ -49,6 +59,7 @@ const (
 	bazelBuildPath    = "bazel-bin/build/release-tars"
 	bazelVersionPath  = "bazel-genfiles/version"
 	dockerVersionPath = "kubernetes/version"
+	dockerVersionFile = "_output/release-stage/full/kubernetes/version"
 	kubernetesTar     = "kubernetes.tar.gz"
 
 	// GCSStagePath is the directory where release artifacts are staged before
@@ -77,6 +88,24 @@ const (
 
 var (
 	DefaultToolOrg = git.DefaultGithubOrg
+
+	// DefaultToolBranch is the branch of the tool repository used if
+	// 'TOOL_BRANCH' is not set. Forks based on main can set it to git.Main.
+	DefaultToolBranch = git.Master
+
+	// gzipMagic are the leading bytes of gzip compressed data.
+	gzipMagic = []byte{0x1f, 0x8b}
+
+	// ErrInvalidVersion is returned, wrapped together with the source and the
+	// raw content, if a version marker does not contain a valid semver
+	// version. Callers can detect it with errors.Is or errors.Cause.
+	ErrInvalidVersion = errors.New("invalid version")
+
+	// KubeVersionOverrideEnv is the name of an environment variable which, if
+	// set, short-circuits GetKubeVersion and all version getters built on top
+	// of it. The override has to be a valid release build. It is disabled by
+	// default and can be enabled by setting it to e.g. "K8S_VERSION".
+	KubeVersionOverrideEnv = ""
 )
 
 // GetDefaultKubernetesRepoURL returns the default HTTPS repo URL for Release Engineering tools.
@@ -101,6 +130,40 @@ func GetToolRepoURL(org, repo string, useSSH bool) (string, error) {
 	return git.GetRepoURL(org, repo, useSSH)
 }
 
+// GetToolRepoURLAuto returns the repo URL for Release Engineering tools like
+// GetToolRepoURL, but selects SSH if credentials for it are available, which
+// is the case if an SSH agent is running ('SSH_AUTH_SOCK' is set) or a key
+// exists in ~/.ssh/id_*. HTTPS is used otherwise.
+func GetToolRepoURLAuto(org, repo string) (string, error) {
+	useSSH := sshCredentialsAvailable()
+	logrus.Debugf("SSH credentials available: %v", useSSH)
+	return GetToolRepoURL(org, repo, useSSH)
+}
+
+// sshCredentialsAvailable returns true if an SSH agent is running or the
+// user has a private key in the default location.
+func sshCredentialsAvailable() bool {
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		return true
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	keys, err := filepath.Glob(filepath.Join(home, ".ssh", "id_*"))
+	if err != nil {
+		return false
+	}
+	for _, key := range keys {
+		// Public keys alone are not sufficient
+		if !strings.HasSuffix(key, ".pub") {
+			return true
+		}
+	}
+	return false
+}
+
 // GetToolOrg checks if the 'TOOL_ORG' environment variable is set.
 // If 'TOOL_ORG' is non-empty, it returns the value. Otherwise, it returns DefaultToolOrg.
 func GetToolOrg() string {
@@ -134,11 +197,134 @@ func GetToolBranch() string {
 	return toolBranch
 }
 
+// GetReleaseBucket returns the name of the release bucket with the provided
+// suffix, which is <prefix><suffix>. The prefix is taken from the
+// 'RELEASE_BUCKET_PREFIX' environment variable if it is non-empty and is
+// BucketPrefix otherwise. Standard suffixes are "dev" for CI builds, "gcb"
+// for mock releases and the user name for developer pushes. An empty suffix
+// returns the bucket of official releases, which is the prefix without its
+// trailing dash, for example kubernetes-release.
+func GetReleaseBucket(suffix string) string {
+	prefix := os.Getenv("RELEASE_BUCKET_PREFIX")
+	if prefix == "" {
+		prefix = BucketPrefix
+	}
+
+	if suffix == "" {
+		return strings.TrimSuffix(prefix, "-")
+	}
+	return prefix + suffix
+}
+
+// ValidateToolBranch checks that the tool branch returned by GetToolBranch
+// matches `releaseBranch`, the branch the release gets built from. The default
+// branches master and main are considered equal. A mismatch results in an
+// error if `strict` is set and in a warning otherwise.
+func ValidateToolBranch(releaseBranch string, strict bool) error {
+	toolBranch := GetToolBranch()
+	if toolBranch == releaseBranch ||
+		(git.IsDefaultBranch(toolBranch) && git.IsDefaultBranch(releaseBranch)) {
+		return nil
+	}
+
+	msg := fmt.Sprintf(
+		"tool branch %s does not match release branch %s", toolBranch, releaseBranch,
+	)
+	if strict {
+		return errors.New(msg)
+	}
+	logrus.Warn(msg)
+	return nil
+}
+
 // BuiltWithBazel determines whether the most recent Kubernetes release was built with Bazel.
 func BuiltWithBazel(workDir string) (bool, error) {
-	bazelBuild := filepath.Join(workDir, bazelBuildPath, kubernetesTar)
-	dockerBuild := filepath.Join(workDir, dockerBuildPath, kubernetesTar)
-	return util.MoreRecent(bazelBuild, dockerBuild)
+	buildType, err := detectExistingBuildType(workDir)
+	if err != nil {
+		return false, err
+	}
+	return buildType == BuildTypeBazel, nil
+}
+
+// BuildType is the type of a Kubernetes build, which depends on the tool it
+// was done with.
+type BuildType string
+
+const (
+	// BuildTypeBazel is used for builds below bazel-bin.
+	BuildTypeBazel BuildType = "bazel"
+
+	// BuildTypeDocker is used for Dockerized builds below _output.
+	BuildTypeDocker BuildType = "docker"
+
+	// BuildTypeUnknown is used if no build exists.
+	BuildTypeUnknown BuildType = "unknown"
+)
+
+// DetectBuildType returns the type of the most recent build in `workDir`,
+// which is the one with the newer kubernetes.tar.gz. If only one of both
+// tarballs exists, its build type is returned. BuildTypeUnknown is returned if
+// neither a Bazel nor a Dockerized build exists.
+func DetectBuildType(workDir string) (BuildType, error) {
+	bazelBuild, err := statBuildTarball(filepath.Join(workDir, bazelBuildPath, kubernetesTar))
+	if err != nil {
+		return BuildTypeUnknown, errors.Wrapf(err, "detecting build in %s", workDir)
+	}
+	dockerBuild, err := statBuildTarball(filepath.Join(workDir, dockerBuildPath, kubernetesTar))
+	if err != nil {
+		return BuildTypeUnknown, errors.Wrapf(err, "detecting build in %s", workDir)
+	}
+
+	switch {
+	case bazelBuild == nil && dockerBuild == nil:
+		return BuildTypeUnknown, nil
+	case dockerBuild == nil:
+		return BuildTypeBazel, nil
+	case bazelBuild == nil:
+		return BuildTypeDocker, nil
+	case bazelBuild.ModTime().Unix() >= dockerBuild.ModTime().Unix():
+		return BuildTypeBazel, nil
+	}
+	return BuildTypeDocker, nil
+}
+
+// statBuildTarball returns the file info of the build tarball `path`, which is
+// nil if the tarball does not exist.
+func statBuildTarball(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return info, err
+}
+
+// SameBuildTool returns true if the builds in `dirA` and `dirB` were done
+// with the same tool, together with the detected build types. Outputs of
+// different tools may legitimately differ and are not comparable for
+// reproducibility.
+func SameBuildTool(dirA, dirB string) (same bool, typeA, typeB BuildType, err error) {
+	typeA, err = detectExistingBuildType(dirA)
+	if err != nil {
+		return false, "", "", err
+	}
+	typeB, err = detectExistingBuildType(dirB)
+	if err != nil {
+		return false, "", "", err
+	}
+	return typeA == typeB, typeA, typeB, nil
+}
+
+// detectExistingBuildType calls DetectBuildType and returns an error if no
+// build exists in `workDir`.
+func detectExistingBuildType(workDir string) (BuildType, error) {
+	buildType, err := DetectBuildType(workDir)
+	if err != nil {
+		return "", err
+	}
+	if buildType == BuildTypeUnknown {
+		return "", errors.Errorf("no Kubernetes build found in %s", workDir)
+	}
+	return buildType, nil
 }
 
 // ReadBazelVersion reads the version from a Bazel build.
@@ -147,15 +333,324 @@ func ReadBazelVersion(workDir string) (string, error) {
 	return string(version), err
 }
 
+// ReadBazelizedVersion reads the version from a Bazel build like
+// ReadBazelVersion. If the genfiles version file does not exist, the version
+// embedded in the kubernetes.tar.gz of the Bazel build is used, which mirrors
+// ReadDockerizedVersion.
+func ReadBazelizedVersion(workDir string) (string, error) {
+	versionFile := filepath.Join(workDir, bazelVersionPath)
+	if util.Exists(versionFile) {
+		version, err := ReadBazelVersion(workDir)
+		if err != nil {
+			return "", errors.Wrapf(err, "reading %s", versionFile)
+		}
+		return strings.TrimSpace(version), nil
+	}
+
+	tarball := filepath.Join(workDir, bazelBuildPath, kubernetesTar)
+	logrus.Infof("No %s found, reading version from %s", versionFile, tarball)
+	return ReadVersionFromTarball(tarball)
+}
+
+// ReadVersion reads the version of the most recent build in `workDir`, using
+// ReadBazelizedVersion or ReadDockerizedVersion depending on its
+// DetectBuildType.
+func ReadVersion(workDir string) (string, error) {
+	buildType, err := detectExistingBuildType(workDir)
+	if err != nil {
+		return "", err
+	}
+	logrus.Infof("Using %s build version", buildType)
+	if buildType == BuildTypeBazel {
+		return ReadBazelizedVersion(workDir)
+	}
+	return ReadDockerizedVersion(workDir)
+}
+
+// BuildInfo describes the most recent Kubernetes build of a directory.
+type BuildInfo struct {
+	// Type is the tool the build has been done with.
+	Type BuildType
+
+	// Version is the version of the build, like v1.18.3-beta.0.12+f1a2b3c4d5e6f7.
+	Version string
+
+	// TarballPath is the location of the kubernetes.tar.gz of the build.
+	TarballPath string
+
+	// Dirty is true if the build was done from a modified tree.
+	Dirty bool
+
+	// Valid is true if the version is a valid release build according to
+	// IsValidReleaseBuild.
+	Valid bool
+}
+
+// InspectBuild returns the BuildInfo of the most recent build in `workDir`,
+// which combines DetectBuildType, ReadVersion, IsDirtyBuild and
+// IsValidReleaseBuild. An error is returned if `workDir` does not contain any
+// build.
+func InspectBuild(workDir string) (*BuildInfo, error) {
+	buildType, err := detectExistingBuildType(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &BuildInfo{Type: buildType}
+	if buildType == BuildTypeBazel {
+		info.TarballPath = filepath.Join(workDir, bazelBuildPath, kubernetesTar)
+		info.Version, err = ReadBazelizedVersion(workDir)
+	} else {
+		info.TarballPath = filepath.Join(workDir, dockerBuildPath, kubernetesTar)
+		info.Version, err = ReadDockerizedVersion(workDir)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading version of %s build in %s", buildType, workDir)
+	}
+
+	info.Dirty = IsDirtyBuild(info.Version)
+	info.Valid, err = IsValidReleaseBuild(info.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "validating version %s", info.Version)
+	}
+	return info, nil
+}
+
+// ReadVersionOptions are the options for the WithOptions variants of the
+// version reading functions.
+type ReadVersionOptions struct {
+	// SHA256 is the expected checksum of the tarball, which gets verified
+	// using VerifyTarballChecksum before extracting the version if set.
+	SHA256 string
+}
+
+// ReadDockerizedVersionWithOptions reads the version from a Dockerized
+// Kubernetes build like ReadDockerizedVersion, verifying the kubernetes.tar.gz
+// according to `opts` first.
+func ReadDockerizedVersionWithOptions(workDir string, opts *ReadVersionOptions) (string, error) {
+	tarball := filepath.Join(workDir, dockerBuildPath, kubernetesTar)
+	if err := opts.verify(tarball); err != nil {
+		return "", err
+	}
+	return ReadDockerizedVersion(workDir)
+}
+
+// verify checks the tarball at `tarballPath` according to the options.
+func (o *ReadVersionOptions) verify(tarballPath string) error {
+	if o == nil || o.SHA256 == "" {
+		return nil
+	}
+	return VerifyTarballChecksum(tarballPath, o.SHA256)
+}
+
 // ReadDockerizedVersion reads the version from a Dockerized Kubernetes build.
 func ReadDockerizedVersion(workDir string) (string, error) {
+	r, err := ReadFileFromReleaseTarball(workDir, dockerVersionPath)
+	if err != nil {
+		return "", err
+	}
+	version, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s", dockerVersionPath)
+	}
+	return strings.TrimSpace(string(version)), nil
+}
+
+// ReadFileFromReleaseTarball returns the content of the file at `innerPath`,
+// for example "kubernetes/LICENSES", inside the release tarball of the
+// Dockerized build in `workDir`.
+func ReadFileFromReleaseTarball(workDir, innerPath string) (io.Reader, error) {
 	dockerTarball := filepath.Join(workDir, dockerBuildPath, kubernetesTar)
-	reader, err := util.ReadFileFromGzippedTar(dockerTarball, dockerVersionPath)
+	file, err := os.Open(dockerTarball)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	innerPath = path.Clean(innerPath)
+	name, content, err := readFileFromTarReader(file, func(name string) bool {
+		return path.Clean(name) == innerPath
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", dockerTarball)
+	}
+	if name == "" {
+		return nil, errors.Errorf("unable to find %s in %s", innerPath, dockerTarball)
+	}
+	return bytes.NewReader(content), nil
+}
+
+// ReadVersionFromTarball reads the version embedded in a release tarball.
+func ReadVersionFromTarball(tarballPath string) (string, error) {
+	return ReadVersionFromTarballWithOptions(tarballPath, nil)
+}
+
+// ReadVersionFromTarballWithOptions reads the version embedded in a release
+// tarball like ReadVersionFromTarball, verifying the tarball according to
+// `opts` first.
+func ReadVersionFromTarballWithOptions(tarballPath string, opts *ReadVersionOptions) (string, error) {
+	if err := opts.verify(tarballPath); err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return ReadVersionFromTarReader(file)
+}
+
+// ReadVersionFromTarReader reads the version embedded in a release tarball
+// stream, for example directly from a download. The stream may be either
+// gzip compressed or a plain tar archive.
+func ReadVersionFromTarReader(r io.Reader) (string, error) {
+	name, content, err := readFileFromTarReader(r, func(name string) bool {
+		return name == dockerVersionPath
+	})
 	if err != nil {
 		return "", err
 	}
-	file, err := ioutil.ReadAll(reader)
-	return strings.TrimSpace(string(file)), err
+	if name == "" {
+		return "", errors.Errorf("unable to find %s in tarball", dockerVersionPath)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// readFileFromTarReader returns the name and content of the first file in the
+// optionally gzipped tarball stream `r` for which `match` returns true. The
+// returned name is empty if no file matched.
+func readFileFromTarReader(r io.Reader, match func(name string) bool) (string, []byte, error) {
+	var name string
+	var content []byte
+	err := walkTarReader(r, func(h *tar.Header, tr io.Reader) (bool, error) {
+		if !match(h.Name) {
+			return false, nil
+		}
+		c, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return true, errors.Wrapf(err, "reading %s", h.Name)
+		}
+		name, content = h.Name, c
+		return true, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return name, content, nil
+}
+
+// readFilesFromTarReader returns the content of all files in the optionally
+// gzipped tarball stream `r` for which `match` returns true, indexed by their
+// name.
+func readFilesFromTarReader(r io.Reader, match func(name string) bool) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := walkTarReader(r, func(h *tar.Header, tr io.Reader) (bool, error) {
+		if !match(h.Name) {
+			return false, nil
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return true, errors.Wrapf(err, "reading %s", h.Name)
+		}
+		files[h.Name] = content
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// walkTarReader calls `visit` for every entry of the optionally gzipped
+// tarball stream `r` until it returns true or an error.
+func walkTarReader(r io.Reader, visit func(h *tar.Header, tr io.Reader) (bool, error)) error {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return errors.Wrap(err, "reading tarball header")
+	}
+
+	var archive io.Reader = buffered
+	if bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return errors.Wrap(err, "creating gzip reader")
+		}
+		defer gz.Close()
+		archive = gz
+	}
+
+	tr := tar.NewReader(archive)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading tarball")
+		}
+
+		stop, err := visit(h, tr)
+		if err != nil || stop {
+			return err
+		}
+	}
+}
+
+// VerifyTarballVersion checks that the version embedded in the tarball at
+// `tarballPath` matches `expectedVersion`. A leading 'v' is ignored on both
+// sides.
+func VerifyTarballVersion(tarballPath, expectedVersion string) error {
+	version, err := ReadVersionFromTarball(tarballPath)
+	if err != nil {
+		return errors.Wrapf(err, "reading version from %s", tarballPath)
+	}
+
+	if util.TrimTagPrefix(version) != util.TrimTagPrefix(expectedVersion) {
+		return errors.Errorf(
+			"version mismatch for %s: expected %s, but tarball contains %s",
+			tarballPath, expectedVersion, version,
+		)
+	}
+	return nil
+}
+
+// VerifyVersionFileMatchesBuild checks that the version file of the most
+// recent Bazel or Dockerized build in `workDir` matches the version embedded
+// in its kubernetes.tar.gz, which catches stale version files of previous
+// builds.
+func VerifyVersionFileMatchesBuild(workDir string) error {
+	isBazel, err := BuiltWithBazel(workDir)
+	if err != nil {
+		return errors.Wrap(err, "detecting build type")
+	}
+
+	versionFile := filepath.Join(workDir, dockerVersionFile)
+	tarball := filepath.Join(workDir, dockerBuildPath, kubernetesTar)
+	if isBazel {
+		versionFile = filepath.Join(workDir, bazelVersionPath)
+		tarball = filepath.Join(workDir, bazelBuildPath, kubernetesTar)
+	}
+
+	content, err := ioutil.ReadFile(versionFile)
+	if err != nil {
+		return errors.Wrapf(err, "reading version file %s", versionFile)
+	}
+	fileVersion := strings.TrimSpace(string(content))
+
+	tarballVersion, err := ReadVersionFromTarball(tarball)
+	if err != nil {
+		return errors.Wrapf(err, "reading version from %s", tarball)
+	}
+
+	if fileVersion != tarballVersion {
+		return errors.Errorf(
+			"version file %s contains %s, but %s contains %s",
+			versionFile, fileVersion, tarball, tarballVersion,
+		)
+	}
+	return nil
 }
 
 // IsValidReleaseBuild checks if build version is valid for release.
@@ -163,97 +658,859 @@ func IsValidReleaseBuild(build string) (bool, error) {
 	return regexp.MatchString("("+versionReleaseRE+`(\.`+versionBuildRE+")?"+versionDirtyRE+"?)", build)
 }
 
-// IsDirtyBuild checks if build version is dirty.
+// ReleaseVersion are the components of a release build version, for example
+// v1.19.0-beta.1.58+e19c4a2b1ec777-dirty.
+type ReleaseVersion struct {
+	Major uint64
+	Minor uint64
+	Patch uint64
+
+	// PreRelease is the pre-release label including its number, for example
+	// "beta.1". It is empty for official releases.
+	PreRelease string
+
+	// CommitsSinceTag is the number of commits since the tagged version,
+	// which is zero for tagged versions.
+	CommitsSinceTag uint64
+
+	// CommitSHA is the abbreviated git SHA of the build, if any.
+	CommitSHA string
+
+	// Dirty is true if the build was done from a modified tree.
+	Dirty bool
+}
+
+var (
+	// releaseVersionRE matches complete release build versions without their
+	// dirty suffix.
+	releaseVersionRE = regexp.MustCompile(
+		"^" + versionReleaseRE + `(\.` + versionBuildRE + ")?$",
+	)
+
+	// releaseDirtyRE matches the dirty suffix of a release build version.
+	releaseDirtyRE = regexp.MustCompile(versionDirtyRE + "$")
+
+	// releaseBuildRE matches the commit part of a CI build version.
+	releaseBuildRE = regexp.MustCompile(versionBuildRE)
+
+	// releaseBranchRE matches release branches like release-1.18.
+	releaseBranchRE = regexp.MustCompile(`^release-(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+)
+
+// IsValidReleaseBranch returns true if `branch` is a branch CI builds get
+// published for, which is either master, main or a release branch like
+// release-1.18.
+func IsValidReleaseBranch(branch string) bool {
+	return git.IsDefaultBranch(branch) || releaseBranchRE.MatchString(branch)
+}
+
+// ParseReleaseVersion returns the components of the release build version
+// `build`. Contrary to IsValidReleaseBuild, the whole string has to be a
+// release build version.
+func ParseReleaseVersion(build string) (*ReleaseVersion, error) {
+	// Strip the suffix first, the pre-release label would match it otherwise
+	core := releaseDirtyRE.ReplaceAllString(build, "")
+	dirty := core != build
+
+	match := releaseVersionRE.FindStringSubmatch(core)
+	if match == nil {
+		return nil, errors.Errorf("%q is not a valid release build version", build)
+	}
+
+	res := &ReleaseVersion{
+		PreRelease: strings.TrimPrefix(match[4], "-"),
+		CommitSHA:  match[8],
+		Dirty:      dirty,
+	}
+	if match[5] != "" {
+		if res.PreRelease == "" {
+			return nil, errors.Errorf(
+				"%q has a pre-release number without label", build,
+			)
+		}
+		res.PreRelease += "." + match[5]
+	}
+
+	for target, value := range map[*uint64]string{
+		&res.Major: match[1], &res.Minor: match[2], &res.Patch: match[3],
+		&res.CommitsSinceTag: match[7],
+	} {
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %q of %s", value, build)
+		}
+		*target = n
+	}
+	return res, nil
+}
+
+// IsDirtyBuild checks if build version is dirty, which means that it ends
+// with the -dirty suffix.
 func IsDirtyBuild(build string) bool {
-	return strings.Contains(build, "dirty")
+	dirty, _ := DirtyBuildReason(build)
+	return dirty
+}
+
+// DirtyBuildReason checks if build version is dirty like IsDirtyBuild and
+// additionally returns the suffix marking it as dirty, for example "-dirty"
+// for v1.18.3-dirty. The suffix is empty for clean builds.
+func DirtyBuildReason(build string) (dirty bool, suffix string) {
+	suffix = releaseDirtyRE.FindString(build)
+	return suffix != "", suffix
+}
+
+// IsOfficialRelease returns true if `version` is a final release like
+// v1.20.0, which has neither a pre-release segment like v1.20.0-rc.1 nor the
+// commit of a CI build or a dirty suffix.
+func IsOfficialRelease(version string) (bool, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing version %s", version)
+	}
+	if IsDirtyBuild(version) || releaseBuildRE.MatchString(version) {
+		return false, nil
+	}
+	return len(sem.Pre) == 0 && len(sem.Build) == 0, nil
 }
 
 // TODO: Consider collapsing some of these functions.
 //       Keeping them as-is for now as kubepkg is dependent on them.
 func GetStableReleaseKubeVersion(useSemver bool) (string, error) {
-	logrus.Info("Retrieving Kubernetes release version...")
-	return GetKubeVersion("https://dl.k8s.io/release/stable.txt", useSemver)
+	return GetStableReleaseKubeVersionWithContext(context.Background(), useSemver)
+}
+
+// GetStableReleaseKubeVersionWithContext is GetStableReleaseKubeVersion, where
+// the request gets aborted if `ctx` is cancelled.
+func GetStableReleaseKubeVersionWithContext(ctx context.Context, useSemver bool) (string, error) {
+	return getStableReleaseKubeVersion(ctx, useSemver, nil)
+}
+
+// GetStableReleaseKubeVersionWithOptions is
+// GetStableReleaseKubeVersionWithContext, where `opts` customize how the
+// marker is fetched and logged.
+func GetStableReleaseKubeVersionWithOptions(ctx context.Context, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	return getStableReleaseKubeVersion(ctx, useSemver, opts)
+}
+
+func getStableReleaseKubeVersion(ctx context.Context, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	opts.logger().Info("Retrieving Kubernetes release version...")
+	return getKubeVersionFromMirrors(ctx, "release/stable.txt", DefaultMirrors, useSemver, opts)
 }
 
 func GetStablePrereleaseKubeVersion(useSemver bool) (string, error) {
-	logrus.Info("Retrieving Kubernetes testing version...")
-	return GetKubeVersion("https://dl.k8s.io/release/latest.txt", useSemver)
+	return GetStablePrereleaseKubeVersionWithContext(context.Background(), useSemver)
+}
+
+// GetStablePrereleaseKubeVersionWithContext is GetStablePrereleaseKubeVersion,
+// where the request gets aborted if `ctx` is cancelled.
+func GetStablePrereleaseKubeVersionWithContext(ctx context.Context, useSemver bool) (string, error) {
+	return getStablePrereleaseKubeVersion(ctx, useSemver, nil)
+}
+
+// GetStablePrereleaseKubeVersionWithOptions is
+// GetStablePrereleaseKubeVersionWithContext, where `opts` customize how the
+// marker is fetched and logged.
+func GetStablePrereleaseKubeVersionWithOptions(ctx context.Context, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	return getStablePrereleaseKubeVersion(ctx, useSemver, opts)
+}
+
+func getStablePrereleaseKubeVersion(ctx context.Context, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	opts.logger().Info("Retrieving Kubernetes testing version...")
+	return getKubeVersionFromMirrors(ctx, "release/latest.txt", DefaultMirrors, useSemver, opts)
+}
+
+// ResolvedVersion is a Kubernetes version together with the URL of the
+// version marker it has been fetched from. That is the experimental or origin
+// variant of the marker if it has been used instead. MarkerURL is empty if no
+// fetch happened, because the version has been overridden by the
+// KubeVersionOverrideEnv or read from the MarkerDir of the options.
+type ResolvedVersion struct {
+	Version   string
+	MarkerURL string
 }
 
 func GetLatestCIKubeVersion(useSemver bool) (string, error) {
-	logrus.Info("Retrieving Kubernetes latest build version...")
-	return GetKubeVersion("https://dl.k8s.io/ci/latest.txt", useSemver)
+	return GetLatestCIKubeVersionWithContext(context.Background(), useSemver)
+}
+
+// GetLatestCIKubeVersionWithContext is GetLatestCIKubeVersion, where the
+// request gets aborted if `ctx` is cancelled.
+func GetLatestCIKubeVersionWithContext(ctx context.Context, useSemver bool) (string, error) {
+	resolved, err := getLatestCIKubeVersion(ctx, useSemver, nil)
+	if err != nil {
+		return "", err
+	}
+	return resolved.Version, nil
+}
+
+// GetLatestCIKubeVersionWithOptions is GetLatestCIKubeVersionWithContext,
+// where `opts` customize how the marker is fetched and logged.
+func GetLatestCIKubeVersionWithOptions(ctx context.Context, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	resolved, err := getLatestCIKubeVersion(ctx, useSemver, opts)
+	if err != nil {
+		return "", err
+	}
+	return resolved.Version, nil
+}
+
+// GetLatestCIKubeVersionResolved is GetLatestCIKubeVersion, which
+// additionally returns the URL of the marker the version has been retrieved
+// from.
+func GetLatestCIKubeVersionResolved(useSemver bool) (*ResolvedVersion, error) {
+	return getLatestCIKubeVersion(context.Background(), useSemver, nil)
+}
+
+func getLatestCIKubeVersion(ctx context.Context, useSemver bool, opts *KubeVersionOptions) (*ResolvedVersion, error) {
+	opts.logger().Info("Retrieving Kubernetes latest build version...")
+	return getCIKubeVersionForMarker(ctx, ciMarkerName(git.Master)+".txt", useSemver, opts)
 }
 
 func GetCIKubeVersion(branch string, useSemver bool) (string, error) {
-	logrus.Infof("Retrieving Kubernetes build version on the '%s' branch...", branch)
+	return GetCIKubeVersionWithContext(context.Background(), branch, useSemver)
+}
+
+// GetCIKubeVersionWithContext is GetCIKubeVersion, where the request gets
+// aborted if `ctx` is cancelled.
+func GetCIKubeVersionWithContext(ctx context.Context, branch string, useSemver bool) (string, error) {
+	resolved, err := getCIKubeVersion(ctx, branch, useSemver, nil)
+	if err != nil {
+		return "", err
+	}
+	return resolved.Version, nil
+}
+
+// GetCIKubeVersionWithOptions is GetCIKubeVersionWithContext, where `opts`
+// customize how the marker is fetched and logged.
+func GetCIKubeVersionWithOptions(ctx context.Context, branch string, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	resolved, err := getCIKubeVersion(ctx, branch, useSemver, opts)
+	if err != nil {
+		return "", err
+	}
+	return resolved.Version, nil
+}
+
+// GetCIKubeVersionResolved is GetCIKubeVersion, which additionally returns
+// the URL of the marker the version has been retrieved from.
+func GetCIKubeVersionResolved(branch string, useSemver bool) (*ResolvedVersion, error) {
+	return getCIKubeVersion(context.Background(), branch, useSemver, nil)
+}
+
+func getCIKubeVersion(ctx context.Context, branch string, useSemver bool, opts *KubeVersionOptions) (*ResolvedVersion, error) {
+	if !IsValidReleaseBranch(branch) {
+		return nil, errors.Errorf(
+			"invalid branch %q, expected %s, %s or release-X.Y",
+			branch, git.Master, git.Main,
+		)
+	}
+
+	opts.logger().Infof("Retrieving Kubernetes build version on the '%s' branch...", branch)
 	// TODO: We may need to check if the branch exists first to handle the branch cut scenario
-	versionMarker := "latest"
-	if branch != "master" {
-		version := strings.TrimPrefix(branch, "release-")
+	return getCIKubeVersionForMarker(ctx, ciMarkerName(branch)+".txt", useSemver, opts)
+}
+
+// BranchErrors are the errors of the failed lookups of
+// GetCIKubeVersionsForBranches per branch. errors.Is matches the errors of
+// all branches, for example to check for ErrInvalidVersion.
+type BranchErrors map[string]error
+
+func (e BranchErrors) Error() string {
+	branches := []string{}
+	for branch := range e {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+
+	problems := []string{}
+	for _, branch := range branches {
+		problems = append(problems, fmt.Sprintf("%s: %v", branch, e[branch]))
+	}
+	return fmt.Sprintf(
+		"retrieving CI versions failed for %d branch(es): %s",
+		len(problems), strings.Join(problems, "; "),
+	)
+}
+
+// Is returns true if the error of any branch matches `target`.
+func (e BranchErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCIKubeVersionsForBranches retrieves the CI build versions of `branches`
+// like GetCIKubeVersion, where some branches are looked up concurrently. A
+// failed lookup does not abort the others: the versions of all successful
+// lookups are returned together with BranchErrors holding the error of every
+// failed branch.
+func GetCIKubeVersionsForBranches(branches []string, useSemver bool) (map[string]string, error) {
+	return GetCIKubeVersionsForBranchesWithContext(context.Background(), branches, useSemver)
+}
+
+// GetCIKubeVersionsForBranchesWithContext is GetCIKubeVersionsForBranches,
+// where the requests get aborted if `ctx` is cancelled.
+func GetCIKubeVersionsForBranchesWithContext(ctx context.Context, branches []string, useSemver bool) (map[string]string, error) {
+	return GetCIKubeVersionsForBranchesWithOptions(ctx, branches, useSemver, nil)
+}
 
-		versionMarker = fmt.Sprintf("%s-%s", versionMarker, version)
+// GetCIKubeVersionsForBranchesWithOptions is
+// GetCIKubeVersionsForBranchesWithContext, where `opts` customize how the
+// markers are fetched and logged and how many branches are looked up at once.
+func GetCIKubeVersionsForBranchesWithOptions(
+	ctx context.Context, branches []string, useSemver bool, opts *KubeVersionOptions,
+) (map[string]string, error) {
+	versions := make([]string, len(branches))
+	errs := make([]error, len(branches))
+	// Errors are collected per branch to not abort the other lookups
+	_ = runParallelLimit(len(branches), opts.concurrency(), func(i int) error {
+		versions[i], errs[i] = GetCIKubeVersionWithOptions(ctx, branches[i], useSemver, opts)
+		return nil
+	})
+
+	res := map[string]string{}
+	failed := BranchErrors{}
+	for i, branch := range branches {
+		if errs[i] != nil {
+			failed[branch] = errs[i]
+			continue
+		}
+		res[branch] = versions[i]
 	}
+	if len(failed) > 0 {
+		return res, failed
+	}
+	return res, nil
+}
 
-	versionMarkerFile := fmt.Sprintf("%s.txt", versionMarker)
-	logrus.Infof("Version marker file: %s", versionMarkerFile)
+// GetCIKubeVersionForMarker retrieves the Kubernetes version from the CI
+// version marker `marker`, which is the bare file name of the marker like
+// "latest-fast.txt" or "latest-1.18.txt".
+func GetCIKubeVersionForMarker(marker string, useSemver bool) (string, error) {
+	return GetCIKubeVersionForMarkerWithContext(context.Background(), marker, useSemver)
+}
 
-	u, parseErr := url.Parse("https://dl.k8s.io/ci")
+// GetCIKubeVersionForMarkerWithContext is GetCIKubeVersionForMarker, where
+// the request gets aborted if `ctx` is cancelled.
+func GetCIKubeVersionForMarkerWithContext(ctx context.Context, marker string, useSemver bool) (string, error) {
+	return GetCIKubeVersionForMarkerWithOptions(ctx, marker, useSemver, nil)
+}
+
+// GetCIKubeVersionForMarkerWithOptions is
+// GetCIKubeVersionForMarkerWithContext, where `opts` customize how the marker
+// is fetched and logged.
+func GetCIKubeVersionForMarkerWithOptions(ctx context.Context, marker string, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	resolved, err := getCIKubeVersionForMarker(ctx, marker, useSemver, opts)
+	if err != nil {
+		return "", err
+	}
+	return resolved.Version, nil
+}
+
+func getCIKubeVersionForMarker(ctx context.Context, marker string, useSemver bool, opts *KubeVersionOptions) (*ResolvedVersion, error) {
+	if !strings.HasSuffix(marker, ".txt") || marker == ".txt" {
+		return nil, errors.Errorf("invalid version marker %q, expected a .txt file", marker)
+	}
+	if strings.Contains(marker, "/") {
+		return nil, errors.Errorf("invalid version marker %q, expected a file name", marker)
+	}
+	opts.logger().Infof("Version marker file: %s", marker)
+
+	u, parseErr := url.Parse(ciURLBase)
 	if parseErr != nil {
-		return "", errors.Wrap(parseErr, "failed to parse URL base")
+		return nil, errors.Wrap(parseErr, "failed to parse URL base")
 	}
 
-	u.Path = path.Join(u.Path, versionMarkerFile)
+	u.Path = path.Join(u.Path, marker)
 	markerURL := u.String()
 
-	return GetKubeVersion(markerURL, useSemver)
+	return resolveKubeVersion(ctx, markerURL, useSemver, opts)
+}
+
+// ciMarkerName returns the name of the CI version marker of `branch`, which
+// is the most specific one of ciMarkerNames, for example "latest" for master
+// and main and "latest-1.18" for release-1.18.
+func ciMarkerName(branch string) string {
+	names := ciMarkerNames(branch)
+	return names[len(names)-1]
 }
 
 func GetKubeVersion(markerURL string, useSemver bool) (string, error) {
-	logrus.Infof("Retrieving Kubernetes build version from %s...", markerURL)
-	version, httpErr := util.GetURLResponse(markerURL, true)
-	if httpErr != nil {
-		return "", httpErr
+	return getKubeVersion(context.Background(), markerURL, useSemver, nil)
+}
+
+// GetKubeVersionWithContext is GetKubeVersion, where the request gets aborted
+// if `ctx` is cancelled.
+func GetKubeVersionWithContext(ctx context.Context, markerURL string, useSemver bool) (string, error) {
+	return getKubeVersion(ctx, markerURL, useSemver, nil)
+}
+
+// GetKubeVersionWithOptions retrieves the Kubernetes version from the marker
+// at `markerURL` like GetKubeVersionWithContext, where `opts` can be used to
+// customize how the marker is fetched. Passing nil options equals calling
+// GetKubeVersionWithContext.
+func GetKubeVersionWithOptions(ctx context.Context, markerURL string, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	return getKubeVersion(ctx, markerURL, useSemver, opts)
+}
+
+func getKubeVersion(ctx context.Context, markerURL string, useSemver bool, opts *KubeVersionOptions) (string, error) {
+	resolved, err := resolveKubeVersion(ctx, markerURL, useSemver, opts)
+	if err != nil {
+		return "", err
 	}
+	return resolved.Version, nil
+}
 
-	if useSemver {
-		// Remove the 'v' prefix from the string to make the version SemVer compliant
-		version = strings.TrimPrefix(version, "v")
+// resolveKubeVersion is getKubeVersion, which additionally returns the URL
+// the marker has been fetched from.
+func resolveKubeVersion(ctx context.Context, markerURL string, useSemver bool, opts *KubeVersionOptions) (*ResolvedVersion, error) {
+	log := opts.logger()
+	version, overridden, overrideErr := kubeVersionOverride(log)
+	if overrideErr != nil {
+		return nil, overrideErr
+	}
 
-		sem, semverErr := semver.Parse(version)
-		if semverErr != nil {
-			return "", semverErr
+	source := ""
+	if !overridden {
+		log.Infof("Retrieving Kubernetes build version from %s...", markerURL)
+		start := time.Now()
+		marker, httpErr := resolveMarker(ctx, markerURL, opts)
+		recordFetch(markerChannel(markerURL), start, httpErr)
+		if httpErr != nil {
+			return nil, httpErr
 		}
+		version, source = marker.content, marker.source
+	}
 
-		version = sem.String()
+	version, err := normalizeMarker(version, useSemver, opts.trim())
+	if err != nil {
+		return nil, errors.Wrapf(err, "version marker %s", markerURL)
 	}
 
-	logrus.Infof("Retrieved Kubernetes version: %s", version)
-	return version, nil
+	log.Infof("Retrieved Kubernetes version: %s", version)
+	return &ResolvedVersion{Version: version, MarkerURL: source}, nil
+}
+
+// GetKubeVersionFromMirrors retrieves the Kubernetes version from the marker
+// at `markerPath`, for example "release/stable.txt", like GetKubeVersion. The
+// `mirrors` are base URLs mirroring the layout of dl.k8s.io, which are tried
+// in order until one of them succeeds. DefaultMirrors are used if no mirrors
+// are provided.
+func GetKubeVersionFromMirrors(markerPath string, mirrors []string, useSemver bool) (string, error) {
+	return getKubeVersionFromMirrors(context.Background(), markerPath, mirrors, useSemver, nil)
+}
+
+func getKubeVersionFromMirrors(
+	ctx context.Context, markerPath string, mirrors []string, useSemver bool, opts *KubeVersionOptions,
+) (string, error) {
+	resolved, err := resolveKubeVersionFromMirrors(ctx, markerPath, mirrors, useSemver, opts)
+	if err != nil {
+		return "", err
+	}
+	return resolved.Version, nil
+}
+
+// resolveKubeVersionFromMirrors is getKubeVersionFromMirrors, which
+// additionally returns the marker URL of the mirror that succeeded.
+func resolveKubeVersionFromMirrors(
+	ctx context.Context, markerPath string, mirrors []string, useSemver bool, opts *KubeVersionOptions,
+) (*ResolvedVersion, error) {
+	if len(mirrors) == 0 {
+		mirrors = DefaultMirrors
+	}
+
+	log := opts.logger()
+	errs := []string{}
+	for _, mirror := range mirrors {
+		markerURL := strings.TrimSuffix(mirror, "/") + "/" + strings.TrimPrefix(markerPath, "/")
+		resolved, err := resolveKubeVersion(ctx, markerURL, useSemver, opts)
+		if err == nil {
+			log.Infof("Using version marker from mirror %s", mirror)
+			return resolved, nil
+		}
+		if len(mirrors) == 1 {
+			return nil, err
+		}
+		log.Warnf("Unable to retrieve %s from mirror %s: %v", markerPath, mirror, err)
+		errs = append(errs, err.Error())
+	}
+	return nil, errors.Errorf(
+		"retrieving %s from all mirrors failed: %s", markerPath, strings.Join(errs, "; "),
+	)
+}
+
+// GetKubeVersionWithClient retrieves the Kubernetes version from the marker
+// at `markerURL` like GetKubeVersion, but uses `client` for fetching it.
+func GetKubeVersionWithClient(markerURL string, useSemver bool, client *http.Client) (string, error) {
+	return getKubeVersion(context.Background(), markerURL, useSemver, &KubeVersionOptions{
+		Client: client,
+	})
+}
+
+// GetKubeVersionBoth retrieves the Kubernetes version from the marker at
+// `markerURL` like GetKubeVersion and returns it unmodified together with
+// its parsed semver. For CI builds like v1.19.0-beta.1.58+e19c4a2b1ec777 the
+// commit is part of the build metadata of the semver, which is ignored when
+// comparing versions.
+func GetKubeVersionBoth(markerURL string) (original string, sem semver.Version, err error) {
+	original, err = getKubeVersion(context.Background(), markerURL, false, nil)
+	if err != nil {
+		return "", semver.Version{}, err
+	}
+	sem, err = util.TagStringToSemver(original)
+	if err != nil {
+		return "", semver.Version{}, errors.Wrapf(
+			ErrInvalidVersion, "version marker %s: parsing %q: %v", markerURL, original, err,
+		)
+	}
+	return original, sem, nil
+}
+
+// NormalizeKubeVersion converts the raw content of a version marker into the
+// version returned by GetKubeVersion and friends. Surrounding whitespace gets
+// removed. Unlike NormalizeVersion, the version is otherwise kept as it is
+// unless `useSemver` is set, in which case it gets validated like
+// NormalizeVersion does and is returned without the 'v' prefix to be
+// SemVer compliant, for example v1.18.3 becomes 1.18.3. The returned error
+// wraps ErrInvalidVersion if `version` cannot be parsed.
+func NormalizeKubeVersion(version string, useSemver bool) (string, error) {
+	version = strings.TrimSpace(version)
+	if !useSemver {
+		return version, nil
+	}
+
+	normalized, err := NormalizeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return util.TrimTagPrefix(normalized), nil
+}
+
+// normalizeMarker is NormalizeKubeVersion if `trim` is set. Otherwise the
+// content of the marker is kept as it is, where only the version on the first
+// line gets converted if `useSemver` is set.
+func normalizeMarker(content string, useSemver, trim bool) (string, error) {
+	if trim {
+		return NormalizeKubeVersion(content, useSemver)
+	}
+	if !useSemver {
+		return content, nil
+	}
+
+	version, rest := content, ""
+	if i := strings.Index(content, "\n"); i >= 0 {
+		version, rest = content[:i], content[i:]
+	}
+	version, err := NormalizeKubeVersion(version, useSemver)
+	if err != nil {
+		return "", err
+	}
+	return version + rest, nil
+}
+
+// ReadKubeVersion reads the content of a version marker from `r`, for example
+// os.Stdin, and normalizes it like NormalizeKubeVersion.
+func ReadKubeVersion(r io.Reader, useSemver bool) (string, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", errors.Wrap(err, "reading version marker")
+	}
+	return NormalizeKubeVersion(string(content), useSemver)
+}
+
+// kubeVersionOverride returns the version set via the KubeVersionOverrideEnv
+// environment variable and whether an override is active, which gets logged
+// to `log`.
+func kubeVersionOverride(log logrus.FieldLogger) (version string, overridden bool, err error) {
+	if KubeVersionOverrideEnv == "" {
+		return "", false, nil
+	}
+
+	version = strings.TrimSpace(os.Getenv(KubeVersionOverrideEnv))
+	if version == "" {
+		return "", false, nil
+	}
+
+	valid, err := IsValidReleaseBuild(version)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "validating version override %s", version)
+	}
+	if !valid {
+		return "", false, errors.Errorf(
+			"version override %s=%s is not a valid release build",
+			KubeVersionOverrideEnv, version,
+		)
+	}
+
+	log.Warnf(
+		"Version override is active, using %s from %s",
+		version, KubeVersionOverrideEnv,
+	)
+	return version, true, nil
 }
 
 // GetKubecrossVersion returns the current kube-cross container version.
 // Replaces release::kubecross_version
 func GetKubecrossVersion(branches ...string) (string, error) {
-	for i, branch := range branches {
-		logrus.Infof("Trying to get the kube-cross version for %s...", branch)
+	return GetKubecrossVersionWithContext(context.Background(), branches...)
+}
 
-		versionURL := fmt.Sprintf("https://raw.githubusercontent.com/kubernetes/kubernetes/%s/build/build-image/cross/VERSION", branch)
+// GetKubecrossVersionWithContext is GetKubecrossVersion, where the requests
+// get aborted if `ctx` is cancelled.
+func GetKubecrossVersionWithContext(ctx context.Context, branches ...string) (string, error) {
+	return getKubecrossVersionForBranches(ctx, nil, branches)
+}
 
-		version, httpErr := util.GetURLResponse(versionURL, true)
+// GetKubecrossVersionWithOptions is GetKubecrossVersionWithContext, where
+// `opts` customize how the versions are fetched and logged.
+func GetKubecrossVersionWithOptions(ctx context.Context, opts *KubeVersionOptions, branches ...string) (string, error) {
+	return getKubecrossVersionForBranches(ctx, opts, branches)
+}
+
+func getKubecrossVersionForBranches(ctx context.Context, opts *KubeVersionOptions, branches []string) (string, error) {
+	log := opts.logger()
+	if opts != nil && opts.KubecrossRepoPath != "" {
+		version, err := kubecrossVersionFromRepo(opts.KubecrossRepoPath, log)
+		if err == nil {
+			log.Infof(
+				"Using the kube-cross version of %s for all branches (%s)",
+				opts.KubecrossRepoPath, strings.Join(branches, ", "),
+			)
+			return version, nil
+		}
+		log.Warnf("Unable to use local kube-cross version, falling back to GitHub: %v", err)
+	}
+
+	for i, branch := range branches {
+		version, httpErr := getKubecrossVersion(ctx, branch, opts)
 		if httpErr != nil {
 			if i < len(branches)-1 {
-				logrus.Infof("Error retrieving the kube-cross version for the '%s': %v", branch, httpErr)
+				log.Infof("Error retrieving the kube-cross version for the '%s': %v", branch, httpErr)
 			} else {
 				return "", httpErr
 			}
 		}
 
 		if version != "" {
-			logrus.Infof("Found the following kube-cross version: %s", version)
+			log.Infof("Found the following kube-cross version: %s", version)
 			return version, nil
 		}
 	}
 
 	return "", errors.New("kube-cross version should not be empty; cannot continue")
 }
+
+// KubecrossBranchForVersion returns the branch whose kube-cross version has
+// to be used to build `version`. Alpha versions are built before the branch
+// cut and therefore use master, while all others use their release branch.
+func KubecrossBranchForVersion(version string) (string, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+	if len(sem.Pre) > 0 && sem.Pre[0].VersionStr == "alpha" {
+		return git.Master, nil
+	}
+	return BranchForVersion(version)
+}
+
+// GetKubecrossVersions returns the kube-cross container version for each of
+// the provided branches, which are looked up in parallel.
+func GetKubecrossVersions(branches ...string) (map[string]string, error) {
+	return GetKubecrossVersionsWithOptions(context.Background(), nil, branches...)
+}
+
+// GetKubecrossVersionsWithOptions is GetKubecrossVersions, where the requests
+// get aborted if `ctx` is cancelled and `opts` customize how the versions are
+// fetched and logged.
+func GetKubecrossVersionsWithOptions(ctx context.Context, opts *KubeVersionOptions, branches ...string) (map[string]string, error) {
+	resolved := make([]string, len(branches))
+	if err := runParallel(len(branches), func(i int) error {
+		version, err := getKubecrossVersion(ctx, branches[i], opts)
+		if err != nil {
+			return errors.Wrapf(
+				err, "retrieving the kube-cross version for %s", branches[i],
+			)
+		}
+		if version == "" {
+			return errors.Errorf("kube-cross version for %s is empty", branches[i])
+		}
+		resolved[i] = version
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for i, branch := range branches {
+		versions[branch] = resolved[i]
+	}
+	return versions, nil
+}
+
+// VerifyKubecrossConsistency checks that all provided branches use the same
+// kube-cross version, which is required to produce consistent builds for
+// multiple releases at once.
+func VerifyKubecrossConsistency(branches []string) error {
+	return VerifyKubecrossConsistencyWithOptions(context.Background(), branches, nil)
+}
+
+// VerifyKubecrossConsistencyWithOptions is VerifyKubecrossConsistency, where
+// the requests get aborted if `ctx` is cancelled and `opts` customize how the
+// versions are fetched and logged.
+func VerifyKubecrossConsistencyWithOptions(ctx context.Context, branches []string, opts *KubeVersionOptions) error {
+	versions, err := GetKubecrossVersionsWithOptions(ctx, opts, branches...)
+	if err != nil {
+		return err
+	}
+	return checkKubecrossConsistency(branches, versions, opts.logger())
+}
+
+func checkKubecrossConsistency(branches []string, versions map[string]string, log logrus.FieldLogger) error {
+	distinct := map[string]bool{}
+	perBranch := []string{}
+	for _, branch := range branches {
+		distinct[versions[branch]] = true
+		perBranch = append(perBranch, fmt.Sprintf("%s: %s", branch, versions[branch]))
+	}
+
+	if len(distinct) > 1 {
+		return errors.Errorf(
+			"kube-cross versions diverge across branches (%s)",
+			strings.Join(perBranch, ", "),
+		)
+	}
+	log.Infof("Found consistent kube-cross versions (%s)", strings.Join(perBranch, ", "))
+	return nil
+}
+
+// GetKubecrossVersionFromRepo returns the kube-cross container version of
+// the local kubernetes/kubernetes checkout at `repoPath`, which is the trimmed
+// content of its KubecrossVersionPath file.
+func GetKubecrossVersionFromRepo(repoPath string) (string, error) {
+	return kubecrossVersionFromRepo(repoPath, logrus.StandardLogger())
+}
+
+func kubecrossVersionFromRepo(repoPath string, log logrus.FieldLogger) (string, error) {
+	versionFile := filepath.Join(repoPath, filepath.FromSlash(KubecrossVersionPath))
+	content, err := ioutil.ReadFile(versionFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading kube-cross version of %s", repoPath)
+	}
+
+	version := strings.TrimSpace(string(content))
+	if version == "" {
+		return "", errors.Errorf("kube-cross version file %s is empty", versionFile)
+	}
+	log.Infof("Found the following kube-cross version in %s: %s", repoPath, version)
+	return version, nil
+}
+
+const (
+	// defaultKubecrossCacheTTL is the default duration a resolved kube-cross
+	// version is reused.
+	defaultKubecrossCacheTTL = 10 * time.Minute
+
+	// DefaultKubecrossVersionPath is the default location of the kube-cross
+	// version file in the kubernetes/kubernetes repository.
+	DefaultKubecrossVersionPath = "build/build-image/cross/VERSION"
+)
+
+var (
+	// KubecrossVersionPath is the location of the kube-cross version file
+	// relative to the root of the kubernetes/kubernetes repository, which is
+	// used for every branch looked up by GetKubecrossVersion and its variants
+	// as well as by GetKubecrossVersionFromRepo. It defaults to
+	// DefaultKubecrossVersionPath and can be changed if the file moves.
+	KubecrossVersionPath = DefaultKubecrossVersionPath
+
+	// kubecrossRepoURL is the location of the raw content of the
+	// kubernetes/kubernetes repository on a branch.
+	kubecrossRepoURL = "https://raw.githubusercontent.com/kubernetes/kubernetes/%s"
+
+	kubecrossCache = struct {
+		sync.Mutex
+		ttl      time.Duration
+		versions map[string]kubecrossCacheEntry
+	}{ttl: defaultKubecrossCacheTTL, versions: map[string]kubecrossCacheEntry{}}
+)
+
+type kubecrossCacheEntry struct {
+	version string
+	fetched time.Time
+}
+
+// ClearKubecrossCache drops all kube-cross versions resolved so far, which
+// means the next lookup of every branch fetches the version again.
+func ClearKubecrossCache() {
+	kubecrossCache.Lock()
+	defer kubecrossCache.Unlock()
+	kubecrossCache.versions = map[string]kubecrossCacheEntry{}
+}
+
+// SetKubecrossCacheTTL sets the duration a resolved kube-cross version of a
+// branch is reused by the GetKubecrossVersion functions, which is ten
+// minutes by default. A zero `ttl` disables the cache.
+func SetKubecrossCacheTTL(ttl time.Duration) {
+	kubecrossCache.Lock()
+	defer kubecrossCache.Unlock()
+	kubecrossCache.ttl = ttl
+}
+
+// cachedKubecrossVersion returns the cached kube-cross version fetched from
+// `versionURL` if it is not older than the cache TTL. The versions are cached
+// per CacheKey of the URL, so changing the KubecrossVersionPath or the
+// repository does not return versions of the former location.
+func cachedKubecrossVersion(versionURL string) (string, bool) {
+	kubecrossCache.Lock()
+	defer kubecrossCache.Unlock()
+
+	entry, ok := kubecrossCache.versions[kubecrossCacheKey(versionURL)]
+	if !ok || time.Since(entry.fetched) >= kubecrossCache.ttl {
+		return "", false
+	}
+	return entry.version, true
+}
+
+func cacheKubecrossVersion(versionURL, version string) {
+	kubecrossCache.Lock()
+	defer kubecrossCache.Unlock()
+	kubecrossCache.versions[kubecrossCacheKey(versionURL)] = kubecrossCacheEntry{version, time.Now()}
+}
+
+func kubecrossCacheKey(versionURL string) string {
+	host := ""
+	if u, err := url.Parse(versionURL); err == nil {
+		host = u.Host
+	}
+	return CacheKey(versionURL, false, host)
+}
+
+func getKubecrossVersion(ctx context.Context, branch string, opts *KubeVersionOptions) (string, error) {
+	log := opts.logger()
+	versionURL := fmt.Sprintf(kubecrossRepoURL, branch) + "/" + strings.TrimPrefix(KubecrossVersionPath, "/")
+	if version, ok := cachedKubecrossVersion(versionURL); ok {
+		log.Infof("Using cached kube-cross version for %s", branch)
+		return version, nil
+	}
+
+	log.Infof("Trying to get the kube-cross version for %s...", branch)
+
+	start := time.Now()
+	version, err := getMarkerWithRetry(
+		ctx, opts.httpClient(), versionURL, opts.retryOptions(), log,
+	)
+	recordFetch(kubecrossChannelPrefix+branch, start, err)
+	if err != nil {
+		return "", err
+	}
+	content := strings.TrimSpace(version.content)
+	if content != "" {
+		cacheKubecrossVersion(versionURL, content)
+	}
+	return content, nil
+}
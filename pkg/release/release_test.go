@@ -20,15 +20,26 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"k8s.io/release/pkg/util"
 )
 
 func TestGetDefaultToolRepoURLSuccess(t *testing.T) {
@@ -81,6 +92,62 @@ func TestGetToolRepoURLSuccess(t *testing.T) {
 	}
 }
 
+func TestGetToolRepoURLAuto(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	defer func(home, sock string) {
+		os.Setenv("HOME", home)
+		os.Setenv("SSH_AUTH_SOCK", sock)
+	}(os.Getenv("HOME"), os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("HOME", baseTmpDir)
+
+	testcases := []struct {
+		name     string
+		authSock string
+		keys     []string
+		expected string
+	}{
+		{
+			name:     "no credentials",
+			expected: "https://github.com/kubernetes/release",
+		},
+		{
+			name:     "only public key",
+			keys:     []string{"id_rsa.pub"},
+			expected: "https://github.com/kubernetes/release",
+		},
+		{
+			name:     "ssh agent",
+			authSock: "/tmp/ssh-agent.sock",
+			expected: "git@github.com:kubernetes/release",
+		},
+		{
+			name:     "private key",
+			keys:     []string{"id_ed25519", "id_ed25519.pub"},
+			expected: "git@github.com:kubernetes/release",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+		os.Setenv("SSH_AUTH_SOCK", tc.authSock)
+		sshDir := filepath.Join(baseTmpDir, ".ssh")
+		require.Nil(t, os.RemoveAll(sshDir))
+		require.Nil(t, os.MkdirAll(sshDir, os.ModePerm))
+		for _, key := range tc.keys {
+			require.Nil(t, ioutil.WriteFile(
+				filepath.Join(sshDir, key), []byte{}, os.FileMode(0600),
+			))
+		}
+
+		actual, err := GetToolRepoURLAuto("", "")
+		assert.Equal(t, tc.expected, actual)
+		assert.Nil(t, err)
+	}
+}
+
 func TestGetToolBranchSuccess(t *testing.T) {
 	testcases := []struct {
 		name     string
@@ -107,6 +174,205 @@ func TestGetToolBranchSuccess(t *testing.T) {
 	}
 }
 
+func TestGetReleaseBucket(t *testing.T) {
+	defer os.Unsetenv("RELEASE_BUCKET_PREFIX")
+
+	testcases := []struct {
+		name     string
+		prefix   string
+		suffix   string
+		expected string
+	}{
+		{
+			name:     "default prefix",
+			suffix:   "dev",
+			expected: "kubernetes-release-dev",
+		},
+		{
+			name:     "custom prefix",
+			prefix:   "my-mirror-",
+			suffix:   "gcb",
+			expected: "my-mirror-gcb",
+		},
+		{
+			name:     "official releases",
+			expected: "kubernetes-release",
+		},
+		{
+			name:     "official releases with custom prefix",
+			prefix:   "my-mirror-",
+			expected: "my-mirror",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+		os.Setenv("RELEASE_BUCKET_PREFIX", tc.prefix)
+
+		actual := GetReleaseBucket(tc.suffix)
+		assert.Equal(t, tc.expected, actual)
+	}
+}
+
+func TestSameBuildTool(t *testing.T) {
+	writeBuild := func(tarsPath string) string {
+		dir, err := ioutil.TempDir("", "")
+		require.Nil(t, err)
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, tarsPath), os.ModePerm))
+		require.Nil(t, ioutil.WriteFile(
+			filepath.Join(dir, tarsPath, kubernetesTar), []byte("test"), os.FileMode(0644),
+		))
+		return dir
+	}
+	bazelA := writeBuild(bazelBuildPath)
+	bazelB := writeBuild(bazelBuildPath)
+	docker := writeBuild(dockerBuildPath)
+	empty, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, bazelA, bazelB, docker, empty)
+
+	testcases := []struct {
+		name      string
+		dirA      string
+		dirB      string
+		same      bool
+		toolA     BuildType
+		toolB     BuildType
+		shouldErr bool
+	}{
+		{
+			name:  "both bazel",
+			dirA:  bazelA,
+			dirB:  bazelB,
+			same:  true,
+			toolA: BuildTypeBazel,
+			toolB: BuildTypeBazel,
+		},
+		{
+			name:  "bazel and docker",
+			dirA:  bazelA,
+			dirB:  docker,
+			toolA: BuildTypeBazel,
+			toolB: BuildTypeDocker,
+		},
+		{
+			name:      "no build",
+			dirA:      docker,
+			dirB:      empty,
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+
+		same, toolA, toolB, err := SameBuildTool(tc.dirA, tc.dirB)
+		require.Equal(t, tc.shouldErr, err != nil)
+		require.Equal(t, tc.same, same)
+		require.Equal(t, tc.toolA, toolA)
+		require.Equal(t, tc.toolB, toolB)
+	}
+}
+
+func TestDetectBuildType(t *testing.T) {
+	writeBuild := func(dir, tarsPath string, modTime time.Time) {
+		tarball := filepath.Join(dir, tarsPath, kubernetesTar)
+		require.Nil(t, os.MkdirAll(filepath.Dir(tarball), os.ModePerm))
+		require.Nil(t, ioutil.WriteFile(tarball, []byte("test"), os.FileMode(0644)))
+		require.Nil(t, os.Chtimes(tarball, modTime, modTime))
+	}
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+
+	testcases := []struct {
+		name     string
+		bazel    *time.Time
+		docker   *time.Time
+		expected BuildType
+	}{
+		{name: "bazel only", bazel: &newer, expected: BuildTypeBazel},
+		{name: "docker only", docker: &newer, expected: BuildTypeDocker},
+		{name: "bazel newer", bazel: &newer, docker: &older, expected: BuildTypeBazel},
+		{name: "docker newer", bazel: &older, docker: &newer, expected: BuildTypeDocker},
+		{name: "no build", expected: BuildTypeUnknown},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+
+		dir, err := ioutil.TempDir("", "")
+		require.Nil(t, err)
+		defer cleanupTmps(t, dir)
+		if tc.bazel != nil {
+			writeBuild(dir, bazelBuildPath, *tc.bazel)
+		}
+		if tc.docker != nil {
+			writeBuild(dir, dockerBuildPath, *tc.docker)
+		}
+
+		actual, err := DetectBuildType(dir)
+		require.Nil(t, err)
+		require.Equal(t, tc.expected, actual)
+
+		bazel, err := BuiltWithBazel(dir)
+		require.Equal(t, tc.expected == BuildTypeUnknown, err != nil)
+		require.Equal(t, tc.expected == BuildTypeBazel, bazel)
+	}
+}
+
+func TestValidateToolBranch(t *testing.T) {
+	defer os.Unsetenv("TOOL_BRANCH")
+
+	testcases := []struct {
+		name          string
+		toolBranch    string
+		releaseBranch string
+		strict        bool
+		shouldErr     bool
+	}{
+		{
+			name:          "matching branches",
+			toolBranch:    "release-1.21",
+			releaseBranch: "release-1.21",
+			strict:        true,
+		},
+		{
+			name:          "default branch on master",
+			releaseBranch: "master",
+			strict:        true,
+		},
+		{
+			name:          "main tool branch on master",
+			toolBranch:    "main",
+			releaseBranch: "master",
+			strict:        true,
+		},
+		{
+			name:          "mismatch strict",
+			releaseBranch: "release-1.21",
+			strict:        true,
+			shouldErr:     true,
+		},
+		{
+			name:          "mismatch warning",
+			releaseBranch: "release-1.21",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+		os.Setenv("TOOL_BRANCH", tc.toolBranch)
+
+		err := ValidateToolBranch(tc.releaseBranch, tc.strict)
+		if tc.shouldErr {
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tc.releaseBranch)
+		} else {
+			require.Nil(t, err)
+		}
+	}
+}
+
 func TestBuiltWithBazel(t *testing.T) {
 	baseTmpDir, err := ioutil.TempDir("", "")
 	require.Nil(t, err)
@@ -115,6 +381,8 @@ func TestBuiltWithBazel(t *testing.T) {
 	require.Nil(t, err)
 	dockerTmpDir, err := ioutil.TempDir("", "docker")
 	require.Nil(t, err)
+	emptyTmpDir, err := ioutil.TempDir("", "empty")
+	require.Nil(t, err)
 
 	// Build directories.
 	require.Nil(t, os.MkdirAll(filepath.Join(baseTmpDir, bazelBuildPath), os.ModePerm))
@@ -151,14 +419,14 @@ func TestBuiltWithBazel(t *testing.T) {
 		os.FileMode(0644),
 	))
 
-	defer cleanupTmps(t, baseTmpDir, bazelTmpDir, dockerTmpDir)
+	defer cleanupTmps(t, baseTmpDir, bazelTmpDir, dockerTmpDir, emptyTmpDir)
 
 	type args struct {
 		path string
 	}
 	type want struct {
-		r   bool
-		err error
+		r    bool
+		rErr bool
 	}
 	cases := map[string]struct {
 		args args
@@ -169,8 +437,7 @@ func TestBuiltWithBazel(t *testing.T) {
 				path: baseTmpDir,
 			},
 			want: want{
-				r:   false,
-				err: nil,
+				r: false,
 			},
 		},
 		"DockerOnly": {
@@ -178,8 +445,7 @@ func TestBuiltWithBazel(t *testing.T) {
 				path: dockerTmpDir,
 			},
 			want: want{
-				r:   false,
-				err: nil,
+				r: false,
 			},
 		},
 		"BazelOnly": {
@@ -187,8 +453,15 @@ func TestBuiltWithBazel(t *testing.T) {
 				path: bazelTmpDir,
 			},
 			want: want{
-				r:   true,
-				err: nil,
+				r: true,
+			},
+		},
+		"NoBuild": {
+			args: args{
+				path: emptyTmpDir,
+			},
+			want: want{
+				rErr: true,
 			},
 		},
 	}
@@ -196,7 +469,7 @@ func TestBuiltWithBazel(t *testing.T) {
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			res, err := BuiltWithBazel(tc.args.path)
-			require.Equal(t, tc.want.err, err)
+			require.Equal(t, tc.want.rErr, err != nil)
 			require.Equal(t, tc.want.r, res)
 		})
 	}
@@ -329,6 +602,274 @@ func TestReadDockerVersion(t *testing.T) {
 	}
 }
 
+func TestReadBazelizedVersion(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	withVersionFile := filepath.Join(baseTmpDir, "genfiles")
+	require.Nil(t, os.MkdirAll(filepath.Join(withVersionFile, "bazel-genfiles"), os.ModePerm))
+	require.Nil(t, ioutil.WriteFile(
+		filepath.Join(withVersionFile, bazelVersionPath), []byte("v1.18.3\n"), os.FileMode(0644),
+	))
+
+	withTarball := filepath.Join(baseTmpDir, "tarball")
+	require.Nil(t, os.MkdirAll(filepath.Join(withTarball, bazelBuildPath), os.ModePerm))
+	writeTestTarball(t, filepath.Join(withTarball, bazelBuildPath, kubernetesTar), map[string]string{
+		dockerVersionPath: "v1.18.2\n",
+	})
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		path string
+		want want
+	}{
+		"VersionFile": {
+			path: withVersionFile,
+			want: want{r: "v1.18.3"},
+		},
+		"Tarball": {
+			path: withTarball,
+			want: want{r: "v1.18.2"},
+		},
+		"NoBuild": {
+			path: filepath.Join(baseTmpDir, "notexisting"),
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ReadBazelizedVersion(tc.path)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	bazel := filepath.Join(baseTmpDir, "bazel")
+	require.Nil(t, os.MkdirAll(filepath.Join(bazel, bazelBuildPath), os.ModePerm))
+	writeTestTarball(t, filepath.Join(bazel, bazelBuildPath, kubernetesTar), map[string]string{
+		dockerVersionPath: "v1.18.3\n",
+	})
+
+	docker := filepath.Join(baseTmpDir, "docker")
+	require.Nil(t, os.MkdirAll(filepath.Join(docker, dockerBuildPath), os.ModePerm))
+	writeTestTarball(t, filepath.Join(docker, dockerBuildPath, kubernetesTar), map[string]string{
+		dockerVersionPath: "v1.18.2\n",
+	})
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		path string
+		want want
+	}{
+		"Bazel": {
+			path: bazel,
+			want: want{r: "v1.18.3"},
+		},
+		"Docker": {
+			path: docker,
+			want: want{r: "v1.18.2"},
+		},
+		"NoBuild": {
+			path: baseTmpDir,
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ReadVersion(tc.path)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestInspectBuild(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	bazel := filepath.Join(baseTmpDir, "bazel")
+	require.Nil(t, os.MkdirAll(filepath.Join(bazel, bazelBuildPath), os.ModePerm))
+	writeTestTarball(t, filepath.Join(bazel, bazelBuildPath, kubernetesTar), map[string]string{
+		dockerVersionPath: "v1.18.3\n",
+	})
+
+	docker := filepath.Join(baseTmpDir, "docker")
+	require.Nil(t, os.MkdirAll(filepath.Join(docker, dockerBuildPath), os.ModePerm))
+	writeTestTarball(t, filepath.Join(docker, dockerBuildPath, kubernetesTar), map[string]string{
+		dockerVersionPath: "v1.18.3-beta.0.12+f1a2b3c4d5e6f7-dirty\n",
+	})
+
+	invalid := filepath.Join(baseTmpDir, "invalid")
+	require.Nil(t, os.MkdirAll(filepath.Join(invalid, dockerBuildPath), os.ModePerm))
+	writeTestTarball(t, filepath.Join(invalid, dockerBuildPath, kubernetesTar), map[string]string{
+		dockerVersionPath: "wrong\n",
+	})
+
+	type want struct {
+		r    *BuildInfo
+		rErr bool
+	}
+	cases := map[string]struct {
+		path string
+		want want
+	}{
+		"Bazel": {
+			path: bazel,
+			want: want{r: &BuildInfo{
+				Type:        BuildTypeBazel,
+				Version:     "v1.18.3",
+				TarballPath: filepath.Join(bazel, bazelBuildPath, kubernetesTar),
+				Valid:       true,
+			}},
+		},
+		"DockerDirty": {
+			path: docker,
+			want: want{r: &BuildInfo{
+				Type:        BuildTypeDocker,
+				Version:     "v1.18.3-beta.0.12+f1a2b3c4d5e6f7-dirty",
+				TarballPath: filepath.Join(docker, dockerBuildPath, kubernetesTar),
+				Dirty:       true,
+				Valid:       true,
+			}},
+		},
+		"InvalidVersion": {
+			path: invalid,
+			want: want{r: &BuildInfo{
+				Type:        BuildTypeDocker,
+				Version:     "wrong",
+				TarballPath: filepath.Join(invalid, dockerBuildPath, kubernetesTar),
+			}},
+		},
+		"NoBuild": {
+			path: baseTmpDir,
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := InspectBuild(tc.path)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestReadFileFromReleaseTarball(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	require.Nil(t, os.MkdirAll(filepath.Join(baseTmpDir, dockerBuildPath), os.ModePerm))
+	writeTestTarball(t, filepath.Join(baseTmpDir, dockerBuildPath, kubernetesTar), map[string]string{
+		"./kubernetes/LICENSES": "licenses",
+		dockerVersionPath:       "v1.18.3\n",
+	})
+
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		workDir   string
+		innerPath string
+		want      want
+	}{
+		"Version": {
+			workDir:   baseTmpDir,
+			innerPath: dockerVersionPath,
+			want:      want{r: "v1.18.3\n"},
+		},
+		"DotPrefixed": {
+			workDir:   baseTmpDir,
+			innerPath: "kubernetes/LICENSES",
+			want:      want{r: "licenses"},
+		},
+		"MissingInnerPath": {
+			workDir:   baseTmpDir,
+			innerPath: "kubernetes/notexisting",
+			want:      want{rErr: true},
+		},
+		"MissingTarball": {
+			workDir:   "notadir",
+			innerPath: dockerVersionPath,
+			want:      want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, err := ReadFileFromReleaseTarball(tc.workDir, tc.innerPath)
+			require.Equal(t, tc.want.rErr, err != nil)
+			if err != nil {
+				return
+			}
+			content, err := ioutil.ReadAll(r)
+			require.Nil(t, err)
+			require.Equal(t, tc.want.r, string(content))
+		})
+	}
+}
+
+func TestVerifyTarballVersion(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	tarball := filepath.Join(baseTmpDir, kubernetesTar)
+	writeTestTarball(t, tarball, map[string]string{
+		dockerVersionPath: "v1.18.3\n",
+	})
+
+	cases := map[string]struct {
+		path     string
+		expected string
+		rErr     bool
+	}{
+		"Match": {
+			path:     tarball,
+			expected: "v1.18.3",
+		},
+		"MatchWithoutPrefix": {
+			path:     tarball,
+			expected: "1.18.3",
+		},
+		"Mismatch": {
+			path:     tarball,
+			expected: "v1.18.4",
+			rErr:     true,
+		},
+		"MissingTarball": {
+			path:     filepath.Join(baseTmpDir, "notexisting.tar.gz"),
+			expected: "v1.18.3",
+			rErr:     true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := VerifyTarballVersion(tc.path, tc.expected)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
 func TestIsValidReleaseBuild(t *testing.T) {
 	type want struct {
 		r    bool
@@ -377,6 +918,75 @@ func TestIsValidReleaseBuild(t *testing.T) {
 	}
 }
 
+func TestParseReleaseVersion(t *testing.T) {
+	type want struct {
+		r    *ReleaseVersion
+		rErr bool
+	}
+	cases := map[string]struct {
+		build string
+		want  want
+	}{
+		"Release": {
+			build: "v1.17.6",
+			want:  want{r: &ReleaseVersion{Major: 1, Minor: 17, Patch: 6}},
+		},
+		"PreRelease": {
+			build: "v1.19.0-rc.1",
+			want: want{r: &ReleaseVersion{
+				Major: 1, Minor: 19, PreRelease: "rc.1",
+			}},
+		},
+		"Build": {
+			build: "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			want: want{r: &ReleaseVersion{
+				Major: 1, Minor: 19, PreRelease: "beta.1",
+				CommitsSinceTag: 58, CommitSHA: "e19c4a2b1ec777",
+			}},
+		},
+		"ReleaseBuild": {
+			build: "v1.18.3.12+abcdef0",
+			want: want{r: &ReleaseVersion{
+				Major: 1, Minor: 18, Patch: 3,
+				CommitsSinceTag: 12, CommitSHA: "abcdef0",
+			}},
+		},
+		"DirtyBuild": {
+			build: "v1.20.0-alpha.0.1+a1b2c3d4e5-dirty",
+			want: want{r: &ReleaseVersion{
+				Major: 1, Minor: 20, PreRelease: "alpha.0",
+				CommitsSinceTag: 1, CommitSHA: "a1b2c3d4e5", Dirty: true,
+			}},
+		},
+		"DirtyRelease": {
+			build: "v1.17.6-dirty",
+			want: want{r: &ReleaseVersion{
+				Major: 1, Minor: 17, Patch: 6, Dirty: true,
+			}},
+		},
+		"MissingPrefix": {
+			build: "1.17.6",
+			want:  want{rErr: true},
+		},
+		"TrailingGarbage": {
+			build: "v1.17.6 wrong",
+			want:  want{rErr: true},
+		},
+		"NumberWithoutLabel": {
+			build: "v1.17.6.1",
+			want:  want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParseReleaseVersion(tc.build)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
 func TestIsDirtyBuild(t *testing.T) {
 	cases := map[string]struct {
 		build string
@@ -390,6 +1000,18 @@ func TestIsDirtyBuild(t *testing.T) {
 			build: "v1.17.6.abcde",
 			want:  false,
 		},
+		"DirtyBuildWithCommit": {
+			build: "v1.19.0-beta.1.58+e19c4a2b1ec777-dirty",
+			want:  true,
+		},
+		"DirtyPreReleaseLabel": {
+			build: "v1.19.0-dirtyfix.1",
+			want:  false,
+		},
+		"DirtyNotSuffix": {
+			build: "v1.19.0-dirty.1",
+			want:  false,
+		},
 	}
 
 	for name, tc := range cases {
@@ -400,6 +1022,138 @@ func TestIsDirtyBuild(t *testing.T) {
 	}
 }
 
+func TestDirtyBuildReason(t *testing.T) {
+	dirty, suffix := DirtyBuildReason("v1.17.6-dirty")
+	require.True(t, dirty)
+	require.Equal(t, "-dirty", suffix)
+
+	dirty, suffix = DirtyBuildReason("v1.17.6-dirtyfix.1")
+	require.False(t, dirty)
+	require.Empty(t, suffix)
+}
+
+func TestIsOfficialRelease(t *testing.T) {
+	type want struct {
+		r    bool
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Official": {
+			version: "v1.20.0",
+			want:    want{r: true},
+		},
+		"OfficialWithoutPrefix": {
+			version: "1.20.0",
+			want:    want{r: true},
+		},
+		"RC": {
+			version: "v1.20.0-rc.1",
+		},
+		"Alpha": {
+			version: "v1.20.0-alpha.0",
+		},
+		"CIBuild": {
+			version: "v1.20.0-rc.1.12+f1a2b3c4d5e6f7",
+		},
+		"OfficialCIBuild": {
+			version: "v1.20.0+f1a2b3c4d5e6f7",
+		},
+		"Dirty": {
+			version: "v1.20.0-dirty",
+		},
+		"DirtyCIBuild": {
+			version: "v1.20.1-rc.0.3+f1a2b3c4d5e6f7-dirty",
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := IsOfficialRelease(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestNormalizeKubeVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		raw       string
+		useSemver bool
+		want      want
+	}{
+		"Release": {
+			raw:  "v1.18.3\n",
+			want: want{r: "v1.18.3"},
+		},
+		"ReleaseSemver": {
+			raw:       " v1.18.3\n",
+			useSemver: true,
+			want:      want{r: "1.18.3"},
+		},
+		"CIBuildSemver": {
+			raw:       "v1.19.0-beta.1.58+e19c4a2b1ec777\n",
+			useSemver: true,
+			want:      want{r: "1.19.0-beta.1.58+e19c4a2b1ec777"},
+		},
+		"InvalidSemver": {
+			raw:       "<html></html>",
+			useSemver: true,
+			want:      want{rErr: true},
+		},
+		"InvalidWithoutSemver": {
+			raw:  "<html></html>",
+			want: want{r: "<html></html>"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := NormalizeKubeVersion(tc.raw, tc.useSemver)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.rErr, errors.Is(err, ErrInvalidVersion))
+			require.Equal(t, tc.want.r, res)
+
+			res, err = ReadKubeVersion(strings.NewReader(tc.raw), tc.useSemver)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestIsValidReleaseBranch(t *testing.T) {
+	cases := map[string]struct {
+		branch string
+		want   bool
+	}{
+		"Master":        {branch: "master", want: true},
+		"Main":          {branch: "main", want: true},
+		"ReleaseBranch": {branch: "release-1.20", want: true},
+		"Typo":          {branch: "realese-1.20"},
+		"PatchVersion":  {branch: "release-1.20.1"},
+		"LeadingZero":   {branch: "release-1.02"},
+		"NoVersion":     {branch: "release-"},
+		"Prefixed":      {branch: "my-release-1.20"},
+		"Empty":         {branch: ""},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, IsValidReleaseBranch(tc.branch))
+		})
+	}
+}
+
 func TestGetKubeVersionSuccess(t *testing.T) {
 	testcases := []struct {
 		name      string
@@ -461,6 +1215,428 @@ func TestGetKubeVersionFailure(t *testing.T) {
 	}
 }
 
+// writeTestTarball creates a gzipped tarball at `path` containing the provided
+// file names and contents.
+func writeTestTarball(t *testing.T, path string, files map[string]string) {
+	b := testTarball(t, files, true)
+	require.Nil(t, ioutil.WriteFile(path, b.Bytes(), os.FileMode(0644)))
+}
+
+// testTarball returns a tar archive containing the provided file names and
+// contents, gzipped if `compress` is set.
+func testTarball(t *testing.T, files map[string]string, compress bool) *bytes.Buffer {
+	var b bytes.Buffer
+	var w io.WriteCloser = nopWriteCloser{&b}
+	if compress {
+		w = gzip.NewWriter(&b)
+	}
+	tw := tar.NewWriter(w)
+	for name, content := range files {
+		require.Nil(t, tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0644,
+			Size: int64(len(content)),
+		}))
+		_, err := tw.Write([]byte(content))
+		require.Nil(t, err)
+	}
+	require.Nil(t, tw.Close())
+	require.Nil(t, w.Close())
+	return &b
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestReadVersionFromTarReader(t *testing.T) {
+	versionFile := map[string]string{
+		"kubernetes/README.md": "test",
+		dockerVersionPath:      "v1.18.3\n",
+	}
+
+	cases := map[string]struct {
+		r    io.Reader
+		want string
+		rErr bool
+	}{
+		"Gzipped": {
+			r:    testTarball(t, versionFile, true),
+			want: "v1.18.3",
+		},
+		"Uncompressed": {
+			r:    testTarball(t, versionFile, false),
+			want: "v1.18.3",
+		},
+		"MissingVersion": {
+			r:    testTarball(t, map[string]string{"kubernetes/README.md": "test"}, true),
+			rErr: true,
+		},
+		"Empty": {
+			r:    &bytes.Buffer{},
+			rErr: true,
+		},
+		"NoTarball": {
+			r:    bytes.NewBufferString("this is not a tarball"),
+			rErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ReadVersionFromTarReader(tc.r)
+			require.Equal(t, tc.rErr, err != nil)
+			require.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestReadVersionWithChecksum(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	tarball := filepath.Join(baseTmpDir, dockerBuildPath, kubernetesTar)
+	require.Nil(t, os.MkdirAll(filepath.Dir(tarball), os.ModePerm))
+	writeTestTarball(t, tarball, map[string]string{dockerVersionPath: "v1.18.3\n"})
+	sha, err := util.SHA256ForFile(tarball)
+	require.Nil(t, err)
+
+	cases := map[string]struct {
+		opts *ReadVersionOptions
+		want string
+		rErr bool
+	}{
+		"NoOptions":       {want: "v1.18.3"},
+		"NoChecksum":      {opts: &ReadVersionOptions{}, want: "v1.18.3"},
+		"ChecksumMatch":   {opts: &ReadVersionOptions{SHA256: sha}, want: "v1.18.3"},
+		"ChecksumInvalid": {opts: &ReadVersionOptions{SHA256: "wrong"}, rErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ReadVersionFromTarballWithOptions(tarball, tc.opts)
+			require.Equal(t, tc.rErr, err != nil)
+			require.Equal(t, tc.want, res)
+
+			res, err = ReadDockerizedVersionWithOptions(baseTmpDir, tc.opts)
+			require.Equal(t, tc.rErr, err != nil)
+			require.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestVerifyVersionFileMatchesBuild(t *testing.T) {
+	cases := map[string]struct {
+		bazel          bool
+		fileVersion    string
+		tarballVersion string
+		rErr           bool
+	}{
+		"DockerMatch": {
+			fileVersion:    "v1.18.3\n",
+			tarballVersion: "v1.18.3",
+		},
+		"DockerMismatch": {
+			fileVersion:    "v1.18.2",
+			tarballVersion: "v1.18.3",
+			rErr:           true,
+		},
+		"BazelMatch": {
+			bazel:          true,
+			fileVersion:    "v1.18.3",
+			tarballVersion: "v1.18.3",
+		},
+		"BazelMismatch": {
+			bazel:          true,
+			fileVersion:    "v1.18.3",
+			tarballVersion: "v1.18.3.1+e19c4a2b1ec777",
+			rErr:           true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+
+			versionFile, buildPath := dockerVersionFile, dockerBuildPath
+			if tc.bazel {
+				versionFile, buildPath = bazelVersionPath, bazelBuildPath
+			}
+			for _, dir := range []string{filepath.Dir(versionFile), buildPath} {
+				require.Nil(t, os.MkdirAll(filepath.Join(baseTmpDir, dir), os.ModePerm))
+			}
+			require.Nil(t, ioutil.WriteFile(
+				filepath.Join(baseTmpDir, versionFile),
+				[]byte(tc.fileVersion), os.FileMode(0644),
+			))
+			writeTestTarball(t,
+				filepath.Join(baseTmpDir, buildPath, kubernetesTar),
+				map[string]string{dockerVersionPath: tc.tarballVersion},
+			)
+
+			err = VerifyVersionFileMatchesBuild(baseTmpDir)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
+func TestGetKubeVersionOverride(t *testing.T) {
+	const overrideEnv = "TEST_K8S_VERSION"
+	KubeVersionOverrideEnv = overrideEnv
+	defer func() {
+		KubeVersionOverrideEnv = ""
+		os.Unsetenv(overrideEnv)
+	}()
+
+	testcases := []struct {
+		name      string
+		override  string
+		useSemver bool
+		expected  string
+		shouldErr bool
+	}{
+		{
+			name:     "Override (non-semver)",
+			override: "v1.18.3",
+			expected: "v1.18.3",
+		},
+		{
+			name:      "Override (semver)",
+			override:  "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			useSemver: true,
+			expected:  "1.19.0-beta.1.58+e19c4a2b1ec777",
+		},
+		{
+			name:      "Invalid override",
+			override:  "1.18.3",
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.name)
+		require.Nil(t, os.Setenv(overrideEnv, tc.override))
+
+		// The marker URL is never reached if an override is active
+		actual, err := GetKubeVersion("https://fake.url", tc.useSemver)
+		require.Equal(t, tc.shouldErr, err != nil)
+		require.Equal(t, tc.expected, actual)
+	}
+}
+
+func TestKubecrossBranchForVersion(t *testing.T) {
+	testcases := []struct {
+		version   string
+		expected  string
+		shouldErr bool
+	}{
+		{version: "v1.18.3", expected: "release-1.18"},
+		{version: "v1.19.0-beta.1.58+e19c4a2b1ec777", expected: "release-1.19"},
+		{version: "v1.19.0-rc.0", expected: "release-1.19"},
+		{version: "v1.20.0-alpha.0.1+e19c4a2b1ec777", expected: "master"},
+		{version: "wrong", shouldErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Logf("Test case: %s", tc.version)
+		branch, err := KubecrossBranchForVersion(tc.version)
+		require.Equal(t, tc.shouldErr, err != nil)
+		require.Equal(t, tc.expected, branch)
+	}
+}
+
+func TestGetKubecrossVersionCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			branch := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/"+KubecrossVersionPath)
+			fmt.Fprintf(w, "v1.15.2-%s\n", branch)
+		},
+	))
+	defer server.Close()
+	defer func(u string) { kubecrossRepoURL = u }(kubecrossRepoURL)
+	kubecrossRepoURL = server.URL + "/%s"
+	defer SetKubecrossCacheTTL(defaultKubecrossCacheTTL)
+	defer ClearKubecrossCache()
+	ClearKubecrossCache()
+
+	// Concurrent lookups of the same branches
+	results := make([]map[string]string, 10)
+	errs := make([]error, len(results))
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = GetKubecrossVersions("release-1.18", "release-1.19")
+		}(i)
+	}
+	wg.Wait()
+	for i := range results {
+		require.Nil(t, errs[i])
+		require.Equal(t, map[string]string{
+			"release-1.18": "v1.15.2-release-1.18",
+			"release-1.19": "v1.15.2-release-1.19",
+		}, results[i])
+	}
+
+	// Cached lookups do not hit the server
+	fetched := atomic.LoadInt32(&requests)
+	version, err := GetKubecrossVersion("release-1.18")
+	require.Nil(t, err)
+	require.Equal(t, "v1.15.2-release-1.18", version)
+	require.Equal(t, fetched, atomic.LoadInt32(&requests))
+
+	ClearKubecrossCache()
+	_, err = GetKubecrossVersion("release-1.18")
+	require.Nil(t, err)
+	require.Equal(t, fetched+1, atomic.LoadInt32(&requests))
+
+	SetKubecrossCacheTTL(0)
+	_, err = GetKubecrossVersion("release-1.18")
+	require.Nil(t, err)
+	require.Equal(t, fetched+2, atomic.LoadInt32(&requests))
+}
+
+func TestGetKubecrossVersionFromRepo(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	_, err = GetKubecrossVersionFromRepo(baseTmpDir)
+	require.NotNil(t, err)
+
+	versionFile := filepath.Join(baseTmpDir, "build", "build-image", "cross", "VERSION")
+	require.Nil(t, os.MkdirAll(filepath.Dir(versionFile), os.ModePerm))
+	require.Nil(t, ioutil.WriteFile(versionFile, []byte("\n"), os.FileMode(0644)))
+	_, err = GetKubecrossVersionFromRepo(baseTmpDir)
+	require.NotNil(t, err)
+
+	require.Nil(t, ioutil.WriteFile(versionFile, []byte("v1.15.2-1\n"), os.FileMode(0644)))
+	version, err := GetKubecrossVersionFromRepo(baseTmpDir)
+	require.Nil(t, err)
+	require.Equal(t, "v1.15.2-1", version)
+
+	// The local checkout is preferred over GitHub if configured
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "v1.15.2-remote")
+		},
+	))
+	defer server.Close()
+	defer func(u string) { kubecrossRepoURL = u }(kubecrossRepoURL)
+	kubecrossRepoURL = server.URL + "/%s"
+	defer ClearKubecrossCache()
+	ClearKubecrossCache()
+
+	opts := &KubeVersionOptions{KubecrossRepoPath: baseTmpDir}
+	version, err = GetKubecrossVersionWithOptions(context.Background(), opts, "release-1.18")
+	require.Nil(t, err)
+	require.Equal(t, "v1.15.2-1", version)
+
+	// The checkout is not used without options
+	version, err = GetKubecrossVersion("release-1.18")
+	require.Nil(t, err)
+	require.Equal(t, "v1.15.2-remote", version)
+
+	ClearKubecrossCache()
+	opts.KubecrossRepoPath = filepath.Join(baseTmpDir, "notexisting")
+	version, err = GetKubecrossVersionWithOptions(context.Background(), opts, "release-1.18")
+	require.Nil(t, err)
+	require.Equal(t, "v1.15.2-remote", version)
+}
+
+func TestKubecrossVersionPath(t *testing.T) {
+	defer func(p string) { KubecrossVersionPath = p }(KubecrossVersionPath)
+	KubecrossVersionPath = "build/cross/VERSION"
+
+	requested := []string{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requested = append(requested, r.URL.Path)
+			if r.URL.Path != "/release-1.19/build/cross/VERSION" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintln(w, "v1.15.2-1")
+		},
+	))
+	defer server.Close()
+	defer func(u string) { kubecrossRepoURL = u }(kubecrossRepoURL)
+	kubecrossRepoURL = server.URL + "/%s"
+	defer func(retry *RetryOptions) { defaultRetryOptions = retry }(defaultRetryOptions)
+	defaultRetryOptions = &RetryOptions{}
+	defer ClearKubecrossCache()
+	ClearKubecrossCache()
+
+	// The branches are still tried in order
+	version, err := GetKubecrossVersion("release-1.20", "release-1.19")
+	require.Nil(t, err)
+	require.Equal(t, "v1.15.2-1", version)
+	require.Equal(t, []string{
+		"/release-1.20/build/cross/VERSION",
+		"/release-1.19/build/cross/VERSION",
+	}, requested)
+
+	// Versions of the former path are not taken from the cache
+	requested = []string{}
+	KubecrossVersionPath = DefaultKubecrossVersionPath
+	_, err = GetKubecrossVersion("release-1.19")
+	require.NotNil(t, err)
+	require.Equal(t, []string{"/release-1.19/build/build-image/cross/VERSION"}, requested)
+	KubecrossVersionPath = "build/cross/VERSION"
+
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+	versionFile := filepath.Join(baseTmpDir, "build", "cross", "VERSION")
+	require.Nil(t, os.MkdirAll(filepath.Dir(versionFile), os.ModePerm))
+	require.Nil(t, ioutil.WriteFile(versionFile, []byte("v1.15.2-2\n"), os.FileMode(0644)))
+	version, err = GetKubecrossVersionFromRepo(baseTmpDir)
+	require.Nil(t, err)
+	require.Equal(t, "v1.15.2-2", version)
+}
+
+func TestCheckKubecrossConsistency(t *testing.T) {
+	cases := map[string]struct {
+		branches []string
+		versions map[string]string
+		rErr     bool
+	}{
+		"Consistent": {
+			branches: []string{"release-1.18", "release-1.17"},
+			versions: map[string]string{
+				"release-1.18": "v1.13.9-5",
+				"release-1.17": "v1.13.9-5",
+			},
+		},
+		"Diverging": {
+			branches: []string{"release-1.18", "release-1.17"},
+			versions: map[string]string{
+				"release-1.18": "v1.13.9-5",
+				"release-1.17": "v1.12.17-1",
+			},
+			rErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			logger := logrus.New()
+			logger.SetOutput(output)
+
+			err := checkKubecrossConsistency(tc.branches, tc.versions, logger)
+			require.Equal(t, tc.rErr, err != nil)
+			require.Equal(t, !tc.rErr, strings.Contains(output.String(), "Found consistent"))
+		})
+	}
+}
+
 func cleanupTmps(t *testing.T, dir ...string) {
 	for _, each := range dir {
 		require.Nil(t, os.RemoveAll(each))
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+// IsSecurityRelease returns true if `version` is one of the provided
+// `securityVersions`. Versions are compared semantically, which means that a
+// leading 'v' does not matter. Unknown or unparsable versions are no security
+// releases.
+func IsSecurityRelease(version string, securityVersions []string) bool {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return false
+	}
+
+	for _, securityVersion := range securityVersions {
+		securitySem, err := util.TagStringToSemver(securityVersion)
+		if err != nil {
+			continue
+		}
+		if sem.Equals(securitySem) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSecurityReleases loads the list of security releases from `location`,
+// which can be either an http(s) URL or a local file. The list contains one
+// version per line, where empty lines and lines starting with '#' are
+// ignored.
+func LoadSecurityReleases(location string) ([]string, error) {
+	var content string
+	if strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") {
+		res, err := util.GetURLResponse(location, false)
+		if err != nil {
+			return nil, err
+		}
+		content = res
+	} else {
+		res, err := ioutil.ReadFile(location)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading security releases from %s", location)
+		}
+		content = string(res)
+	}
+
+	versions := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if _, err := util.TagStringToSemver(line); err != nil {
+			return nil, errors.Wrapf(err, "invalid security release %q in %s", line, location)
+		}
+		versions = append(versions, line)
+	}
+	return versions, errors.Wrapf(scanner.Err(), "parsing security releases from %s", location)
+}
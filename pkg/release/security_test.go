@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSecurityRelease(t *testing.T) {
+	securityVersions := []string{"v1.18.3", "1.17.6", "wrong"}
+
+	cases := map[string]struct {
+		version string
+		want    bool
+	}{
+		"Security":         {version: "v1.18.3", want: true},
+		"SecurityNoPrefix": {version: "1.18.3", want: true},
+		"SecurityPrefixed": {version: "v1.17.6", want: true},
+		"Regular":          {version: "v1.18.2"},
+		"Invalid":          {version: "wrong"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, IsSecurityRelease(tc.version, securityVersions))
+		})
+	}
+}
+
+func TestLoadSecurityReleases(t *testing.T) {
+	const list = "# Security releases\nv1.18.3\n\n  v1.17.6  \n"
+	want := []string{"v1.18.3", "v1.17.6"}
+
+	// File
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	file := filepath.Join(baseTmpDir, "security-releases.txt")
+	require.Nil(t, ioutil.WriteFile(file, []byte(list), os.FileMode(0644)))
+	res, err := LoadSecurityReleases(file)
+	require.Nil(t, err)
+	require.Equal(t, want, res)
+
+	// URL
+	server := newMarkerServer(list, time.Time{})
+	defer server.Close()
+	res, err = LoadSecurityReleases(server.URL)
+	require.Nil(t, err)
+	require.Equal(t, want, res)
+
+	// Invalid content
+	require.Nil(t, ioutil.WriteFile(file, []byte("wrong\n"), os.FileMode(0644)))
+	_, err = LoadSecurityReleases(file)
+	require.NotNil(t, err)
+
+	// Not existing
+	_, err = LoadSecurityReleases(filepath.Join(baseTmpDir, "notexisting"))
+	require.NotNil(t, err)
+}
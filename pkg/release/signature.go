@@ -0,0 +1,104 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/command"
+	"k8s.io/release/pkg/util"
+)
+
+const gpgExecutable = "gpg"
+
+// VerifySignatures checks that every artifact and checksum file below `dir`
+// has a detached signature (<file>.asc) which verifies against the public
+// keys in `keyring`. The returned error lists all unsigned files and files
+// with invalid signatures.
+func VerifySignatures(dir, keyring string) error {
+	files := []string{}
+	if err := filepath.Walk(dir, func(
+		file string, info os.FileInfo, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && !strings.HasSuffix(file, signatureExtension) {
+			files = append(files, file)
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "listing files in %s", dir)
+	}
+	sort.Strings(files)
+
+	unsigned := []string{}
+	signed := []string{}
+	for _, file := range files {
+		if util.Exists(file + signatureExtension) {
+			signed = append(signed, file)
+		} else {
+			unsigned = append(unsigned, file)
+		}
+	}
+
+	invalid := []string{}
+	if len(signed) > 0 {
+		if !command.Available(gpgExecutable) {
+			return errors.Errorf("%s is required to verify signatures", gpgExecutable)
+		}
+
+		keyringPath, err := filepath.Abs(keyring)
+		if err != nil {
+			return errors.Wrapf(err, "resolving keyring path %s", keyring)
+		}
+
+		for _, file := range signed {
+			logrus.Infof("Verifying signature of %s", file)
+			status, err := command.New(gpgExecutable,
+				"--batch",
+				"--no-default-keyring",
+				"--keyring", keyringPath,
+				"--verify", file+signatureExtension, file,
+			).RunSilent()
+			if err != nil {
+				return errors.Wrapf(err, "running %s for %s", gpgExecutable, file)
+			}
+			if !status.Success() {
+				invalid = append(invalid, file)
+			}
+		}
+	}
+
+	problems := []string{}
+	if len(unsigned) > 0 {
+		problems = append(problems, "unsigned: "+strings.Join(unsigned, ", "))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, "invalid signature: "+strings.Join(invalid, ", "))
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("signature verification failed (%s)", strings.Join(problems, "; "))
+	}
+	return nil
+}
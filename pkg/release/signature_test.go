@@ -0,0 +1,55 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignaturesEmptyDir(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	require.Nil(t, VerifySignatures(baseTmpDir, "keyring.gpg"))
+}
+
+func TestVerifySignaturesUnsigned(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	for _, file := range []string{"kubernetes.tar.gz", "kubernetes.tar.gz.sha256"} {
+		require.Nil(t, ioutil.WriteFile(
+			filepath.Join(baseTmpDir, file), []byte("test"), os.FileMode(0644),
+		))
+	}
+
+	err = VerifySignatures(baseTmpDir, "keyring.gpg")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "unsigned")
+	require.Contains(t, err.Error(), "kubernetes.tar.gz.sha256")
+}
+
+func TestVerifySignaturesNotExistingDir(t *testing.T) {
+	require.NotNil(t, VerifySignatures("/not/existing", "keyring.gpg"))
+}
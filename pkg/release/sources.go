@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/git"
+	"k8s.io/release/pkg/util"
+)
+
+// VersionSource is a location a Kubernetes version can be resolved from.
+type VersionSource interface {
+	// Name describes the source for logging and error messages.
+	Name() string
+
+	// Resolve retrieves the version from the source.
+	Resolve() (string, error)
+}
+
+// MarkerSource resolves the version from a version marker, for example
+// https://dl.k8s.io/release/stable.txt.
+type MarkerSource struct {
+	URL     string
+	Options *KubeVersionOptions
+}
+
+// Name returns the URL of the marker.
+func (s *MarkerSource) Name() string {
+	return s.URL
+}
+
+// Resolve retrieves the version from the marker.
+func (s *MarkerSource) Resolve() (string, error) {
+	return GetKubeVersionWithOptions(context.Background(), s.URL, false, s.Options)
+}
+
+// GitTagSource resolves the version from the latest tag of a branch.
+type GitTagSource struct {
+	Repo   *git.Repo
+	Branch string
+}
+
+// Name returns a description of the branch.
+func (s *GitTagSource) Name() string {
+	return fmt.Sprintf("git tags of %s", s.Branch)
+}
+
+// Resolve retrieves the latest tag of the branch.
+func (s *GitTagSource) Resolve() (string, error) {
+	tag, err := s.Repo.LatestTagForBranch(s.Branch)
+	if err != nil {
+		return "", err
+	}
+	return util.SemverToTagString(tag), nil
+}
+
+// MultiSource is a VersionSource that resolves the version from the first of
+// its sources that succeeds, for example the CDN marker, then the GCS origin
+// and finally the git tags.
+type MultiSource struct {
+	sources []VersionSource
+}
+
+// NewMultiSource creates a new MultiSource trying the provided sources in
+// order.
+func NewMultiSource(sources ...VersionSource) *MultiSource {
+	return &MultiSource{sources: sources}
+}
+
+// Name returns the names of all sources.
+func (m *MultiSource) Name() string {
+	names := []string{}
+	for _, source := range m.sources {
+		names = append(names, source.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Resolve retrieves the version from the first source which succeeds like
+// ResolveWithSource.
+func (m *MultiSource) Resolve() (string, error) {
+	version, _, err := m.ResolveWithSource()
+	return version, err
+}
+
+// ResolveWithSource retrieves the version from the first source which
+// succeeds together with the name of that source. The returned error
+// contains the failures of all sources if none succeeded.
+func (m *MultiSource) ResolveWithSource() (version, source string, err error) {
+	failures := []string{}
+	for _, s := range m.sources {
+		version, err = s.Resolve()
+		if err != nil {
+			logrus.Warnf("Unable to resolve version from %s: %v", s.Name(), err)
+			failures = append(failures, fmt.Sprintf("%s: %v", s.Name(), err))
+			continue
+		}
+
+		logrus.Infof("Resolved version %s from %s", version, s.Name())
+		return version, s.Name(), nil
+	}
+
+	if len(failures) == 0 {
+		return "", "", errors.New("no version sources configured")
+	}
+	return "", "", errors.Errorf(
+		"unable to resolve version from any source: %s",
+		strings.Join(failures, "; "),
+	)
+}
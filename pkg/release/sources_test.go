@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVersionSource struct {
+	name    string
+	version string
+	err     error
+}
+
+func (f *fakeVersionSource) Name() string { return f.name }
+
+func (f *fakeVersionSource) Resolve() (string, error) { return f.version, f.err }
+
+func TestMarkerSource(t *testing.T) {
+	server := newMarkerServer("v1.18.3", time.Time{})
+	defer server.Close()
+
+	source := &MarkerSource{URL: server.URL}
+	require.Equal(t, server.URL, source.Name())
+
+	res, err := source.Resolve()
+	require.Nil(t, err)
+	require.Equal(t, "v1.18.3", res)
+}
+
+func TestMultiSource(t *testing.T) {
+	failing := &fakeVersionSource{name: "cdn", err: errors.New("unavailable")}
+	origin := &fakeVersionSource{name: "origin", version: "v1.18.3"}
+	tags := &fakeVersionSource{name: "tags", version: "v1.18.2"}
+
+	type want struct {
+		r      string
+		source string
+		rErr   bool
+	}
+	cases := map[string]struct {
+		sources []VersionSource
+		want    want
+	}{
+		"First": {
+			sources: []VersionSource{origin, tags},
+			want:    want{r: "v1.18.3", source: "origin"},
+		},
+		"Fallback": {
+			sources: []VersionSource{failing, tags},
+			want:    want{r: "v1.18.2", source: "tags"},
+		},
+		"AllFailing": {
+			sources: []VersionSource{failing, failing},
+			want:    want{rErr: true},
+		},
+		"NoSources": {
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			multi := NewMultiSource(tc.sources...)
+			res, resSource, err := multi.ResolveWithSource()
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+			require.Equal(t, tc.want.source, resSource)
+
+			res, err = multi.Resolve()
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
@@ -0,0 +1,449 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/util"
+)
+
+// StageOptions are the options of the functions writing local files or
+// downloading artifacts, like StageFilesWithOptions, WriteMarkerWithOptions,
+// LoadMarkerBundleWithOptions and DownloadReleaseTarball. A nil StageOptions
+// uses the defaults.
+type StageOptions struct {
+	// DryRun logs the files which would be written or removed and the URLs
+	// which would be downloaded, without downloading anything or changing
+	// anything on disk. The functions still validate their inputs, which
+	// lets a dry run fail like the real one, and return the paths they would
+	// write.
+	DryRun bool
+
+	// Logger receives the log messages, the global logrus logger is used if
+	// not set.
+	Logger logrus.FieldLogger
+
+	// Progress is called while downloading with the number of bytes
+	// available locally and the total size, which is -1 if unknown.
+	Progress util.ProgressFunc
+
+	// Client is the HTTP client used for downloading. The
+	// http.DefaultClient is used if not set, because large artifacts would
+	// exceed the timeout used for fetching version markers.
+	Client *http.Client
+}
+
+// dryRun returns true if the options enable the dry run mode.
+func (o *StageOptions) dryRun() bool {
+	return o != nil && o.DryRun
+}
+
+// logger returns the configured logger of the options or the global logrus
+// logger.
+func (o *StageOptions) logger() logrus.FieldLogger {
+	if o == nil || o.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return o.Logger
+}
+
+// progress returns the configured ProgressFunc of the options, which is nil
+// if not set.
+func (o *StageOptions) progress() util.ProgressFunc {
+	if o == nil {
+		return nil
+	}
+	return o.Progress
+}
+
+// httpClient returns the configured download client of the options or the
+// http.DefaultClient.
+func (o *StageOptions) httpClient() *http.Client {
+	if o == nil || o.Client == nil {
+		return http.DefaultClient
+	}
+	return o.Client
+}
+
+// stagePaths are the directories below the build output directory which are
+// populated while staging a release.
+var stagePaths = []string{GCSStagePath, ReleaseStagePath, ReleaseTarsPath}
+
+// EnsureCleanStage checks that the stage directories of the build output
+// directory `workDir` are empty or do not exist, which ensures that no stale
+// artifacts of a previous run get mixed into a fresh build. Use CleanStage to
+// remove them.
+func EnsureCleanStage(workDir string) error {
+	dirty := []string{}
+	for _, stagePath := range stagePaths {
+		dir := filepath.Join(workDir, stagePath)
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "checking stage directory %s", dir)
+		}
+		if len(entries) > 0 {
+			dirty = append(dirty, dir)
+		}
+	}
+
+	if len(dirty) > 0 {
+		return errors.Errorf(
+			"stage directories are not empty: %s", strings.Join(dirty, ", "),
+		)
+	}
+	return nil
+}
+
+// CleanStage removes the stage directories of the build output directory
+// `workDir` together with their content.
+func CleanStage(workDir string) error {
+	_, err := CleanStageWithOptions(workDir, nil)
+	return err
+}
+
+// CleanStageWithOptions is CleanStage, which returns the removed stage
+// directories. They are only logged if `opts` enable the dry run mode.
+func CleanStageWithOptions(workDir string, opts *StageOptions) ([]string, error) {
+	removed := []string{}
+	for _, stagePath := range stagePaths {
+		dir := filepath.Join(workDir, stagePath)
+		removed = append(removed, dir)
+		if opts.dryRun() {
+			opts.logger().Infof("Dry run: would remove stage directory %s", dir)
+			continue
+		}
+		opts.logger().Infof("Removing stage directory %s", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, errors.Wrapf(err, "removing stage directory %s", dir)
+		}
+	}
+	return removed, nil
+}
+
+// ArtifactType is the kind of a staged artifact.
+type ArtifactType string
+
+const (
+	// ArtifactTypeTarball is a compressed tarball like kubernetes.tar.gz.
+	ArtifactTypeTarball ArtifactType = "tar"
+
+	// ArtifactTypeImage is an uncompressed container image tarball like
+	// kube-apiserver.tar.
+	ArtifactTypeImage ArtifactType = "image"
+
+	// ArtifactTypeScript is a shell or PowerShell script like configure.sh.
+	ArtifactTypeScript ArtifactType = "script"
+
+	// ArtifactTypeOther is any other file, like a manifest.
+	ArtifactTypeOther ArtifactType = "other"
+)
+
+// stagedArtifactPaths are the directories below the build output directory
+// containing the artifacts of a staged release. The GCEPath includes the
+// GCIPath and the WindowsLocalPath.
+var stagedArtifactPaths = []string{ReleaseTarsPath, GCEPath, WindowsGCSPath}
+
+// StagedArtifact is a file of a staged release.
+type StagedArtifact struct {
+	// Path is the path of the artifact relative to the build output
+	// directory.
+	Path string
+
+	// Size is the size of the artifact in bytes.
+	Size int64
+
+	// Type is the kind of the artifact detected from its file name.
+	Type ArtifactType
+}
+
+// ListStagedArtifacts returns the artifacts below the ReleaseTarsPath, the
+// GCEPath and the WindowsGCSPath of the build output directory `workDir`,
+// sorted by their path. Checksums and signatures are left out. Stage
+// directories which do not exist yet are skipped.
+func ListStagedArtifacts(workDir string) ([]StagedArtifact, error) {
+	artifacts := []StagedArtifact{}
+	for _, stagePath := range stagedArtifactPaths {
+		dir := filepath.Join(workDir, stagePath)
+		if err := filepath.Walk(dir, func(
+			file string, info os.FileInfo, err error,
+		) error {
+			if os.IsNotExist(err) && file == dir {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() || isArtifactMetadata(file) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(workDir, file)
+			if err != nil {
+				return err
+			}
+			artifacts = append(artifacts, StagedArtifact{
+				Path: rel,
+				Size: info.Size(),
+				Type: detectArtifactType(file),
+			})
+			return nil
+		}); err != nil {
+			return nil, errors.Wrapf(err, "listing staged artifacts in %s", dir)
+		}
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Path < artifacts[j].Path
+	})
+	return artifacts, nil
+}
+
+// detectArtifactType returns the ArtifactType of `file` based on its
+// extension.
+func detectArtifactType(file string) ArtifactType {
+	switch {
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+		return ArtifactTypeTarball
+	case strings.HasSuffix(file, ".tar"):
+		return ArtifactTypeImage
+	case strings.HasSuffix(file, ".sh"), strings.HasSuffix(file, ".ps1"),
+		strings.HasSuffix(file, ".psm1"):
+		return ArtifactTypeScript
+	default:
+		return ArtifactTypeOther
+	}
+}
+
+// StageFile is a file of the build output directory which gets staged for
+// pushing a build.
+type StageFile struct {
+	// SrcPath is the path of the file relative to the build output directory.
+	SrcPath string
+
+	// DstPath is the directory the file gets staged to, relative to the build
+	// output directory. The file keeps its name.
+	DstPath string
+
+	// Required files have to exist, while missing optional files are skipped.
+	Required bool
+}
+
+// WindowsStageFiles are the scripts below the WindowsLocalPath which are
+// staged into the WindowsGCSPath for Windows nodes on GCE.
+var WindowsStageFiles = []StageFile{
+	{
+		SrcPath:  filepath.Join(WindowsLocalPath, "configure.ps1"),
+		DstPath:  WindowsGCSPath,
+		Required: true,
+	},
+	{
+		SrcPath:  filepath.Join(WindowsLocalPath, "common.psm1"),
+		DstPath:  WindowsGCSPath,
+		Required: true,
+	},
+	{
+		SrcPath:  filepath.Join(WindowsLocalPath, "k8s-node-setup.psm1"),
+		DstPath:  WindowsGCSPath,
+		Required: true,
+	},
+	{
+		SrcPath:  filepath.Join(WindowsLocalPath, "testonly/install-ssh.psm1"),
+		DstPath:  WindowsGCSPath,
+		Required: true,
+	},
+	{
+		SrcPath:  filepath.Join(WindowsLocalPath, "testonly/user-profile.psm1"),
+		DstPath:  WindowsGCSPath,
+		Required: true,
+	},
+}
+
+// StageFiles copies `files` of the build output directory `workDir` into
+// their DstPath. Missing optional files are skipped, while a missing required
+// file aborts staging.
+func StageFiles(workDir string, files []StageFile) error {
+	_, err := StageFilesWithOptions(workDir, files, nil)
+	return err
+}
+
+// StageFilesWithOptions is StageFiles, which returns the paths of the staged
+// files. The files are only logged if `opts` enable the dry run mode.
+func StageFilesWithOptions(workDir string, files []StageFile, opts *StageOptions) ([]string, error) {
+	log := opts.logger()
+	staged := []string{}
+	for _, file := range files {
+		src := filepath.Join(workDir, file.SrcPath)
+		dst := filepath.Join(workDir, file.DstPath, filepath.Base(file.SrcPath))
+		if !util.Exists(src) {
+			if file.Required {
+				return nil, errors.Errorf("required file %s is missing", src)
+			}
+			log.Infof("Skipping missing optional file %s", src)
+			continue
+		}
+		staged = append(staged, dst)
+		if opts.dryRun() {
+			log.Infof("Dry run: would copy %s to %s", src, dst)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), os.FileMode(0755)); err != nil {
+			return nil, errors.Wrapf(err, "creating directory for %s", dst)
+		}
+		if err := util.CopyFileLocal(src, dst, file.Required); err != nil {
+			return nil, errors.Wrapf(err, "staging %s", file.SrcPath)
+		}
+	}
+	return staged, nil
+}
+
+// StageWindowsArtifacts copies the WindowsStageFiles of the build output
+// directory `workDir` into the WindowsGCSPath, like krel push does. All
+// required scripts have to exist, otherwise the returned error lists the
+// missing ones and nothing gets staged.
+func StageWindowsArtifacts(workDir string) error {
+	_, err := StageWindowsArtifactsWithOptions(workDir, nil)
+	return err
+}
+
+// StageWindowsArtifactsWithOptions is StageWindowsArtifacts, which returns
+// the paths of the staged scripts. They are only logged if `opts` enable the
+// dry run mode.
+func StageWindowsArtifactsWithOptions(workDir string, opts *StageOptions) ([]string, error) {
+	missing := []string{}
+	for _, file := range WindowsStageFiles {
+		if file.Required && !util.Exists(filepath.Join(workDir, file.SrcPath)) {
+			missing = append(missing, file.SrcPath)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.Errorf(
+			"missing Windows scripts in %s: %s", workDir, strings.Join(missing, ", "),
+		)
+	}
+
+	opts.logger().Infof(
+		"Staging Windows scripts from %s to %s",
+		filepath.Join(workDir, WindowsLocalPath), filepath.Join(workDir, WindowsGCSPath),
+	)
+	return StageFilesWithOptions(workDir, WindowsStageFiles, opts)
+}
+
+// ListWindowsArtifacts returns the files staged below the WindowsGCSPath of
+// the build output directory `workDir`, relative to it and sorted. The list is
+// empty if nothing has been staged yet.
+func ListWindowsArtifacts(workDir string) ([]string, error) {
+	gcsDir := filepath.Join(workDir, WindowsGCSPath)
+
+	artifacts := []string{}
+	if err := filepath.Walk(gcsDir, func(
+		file string, info os.FileInfo, err error,
+	) error {
+		if os.IsNotExist(err) && file == gcsDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(gcsDir, file)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, filepath.ToSlash(rel))
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "listing Windows artifacts in %s", gcsDir)
+	}
+
+	sort.Strings(artifacts)
+	return artifacts, nil
+}
+
+// GCEStageFiles are the scripts below the GCEPath and GCIPath which are
+// staged with a release for deploying clusters on GCE.
+var GCEStageFiles = []StageFile{
+	{
+		SrcPath:  filepath.Join(GCEPath, "configure-vm.sh"),
+		DstPath:  filepath.Join(GCSStagePath, "extra/gce"),
+		Required: false,
+	},
+	{
+		SrcPath:  filepath.Join(GCIPath, "node.yaml"),
+		DstPath:  filepath.Join(GCSStagePath, "extra/gce"),
+		Required: true,
+	},
+	{
+		SrcPath:  filepath.Join(GCIPath, "master.yaml"),
+		DstPath:  filepath.Join(GCSStagePath, "extra/gce"),
+		Required: true,
+	},
+	{
+		SrcPath:  filepath.Join(GCIPath, "configure.sh"),
+		DstPath:  filepath.Join(GCSStagePath, "extra/gce"),
+		Required: true,
+	},
+	{
+		SrcPath:  filepath.Join(GCIPath, "shutdown.sh"),
+		DstPath:  filepath.Join(GCSStagePath, "extra/gce"),
+		Required: false,
+	},
+}
+
+// ValidateGCEScripts checks the GCEStageFiles in the build output directory
+// `workDir`: required scripts have to exist and no existing script may be
+// empty. The returned error lists every problem found.
+func ValidateGCEScripts(workDir string) error {
+	problems := []string{}
+	for _, file := range GCEStageFiles {
+		script := file.SrcPath
+		info, err := os.Stat(filepath.Join(workDir, script))
+		switch {
+		case os.IsNotExist(err):
+			if file.Required {
+				problems = append(problems, script+" is missing")
+			}
+		case err != nil:
+			problems = append(problems, errors.Wrapf(err, "checking %s", script).Error())
+		case !info.Mode().IsRegular():
+			problems = append(problems, script+" is not a regular file")
+		case info.Size() == 0:
+			problems = append(problems, script+" is empty")
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf(
+			"invalid GCE scripts in %s: %s", workDir, strings.Join(problems, "; "),
+		)
+	}
+	return nil
+}
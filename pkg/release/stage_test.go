@@ -0,0 +1,265 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/release/pkg/util"
+)
+
+func TestEnsureCleanStage(t *testing.T) {
+	cases := map[string]struct {
+		dirs  []string
+		files []string
+		rErr  bool
+	}{
+		"NotExisting": {},
+		"Empty": {
+			dirs: []string{GCSStagePath, ReleaseStagePath, ReleaseTarsPath},
+		},
+		"StaleArtifacts": {
+			dirs:  []string{GCSStagePath},
+			files: []string{filepath.Join(ReleaseTarsPath, kubernetesTar)},
+			rErr:  true,
+		},
+		"StaleStage": {
+			dirs: []string{filepath.Join(ReleaseStagePath, "full")},
+			rErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+
+			for _, dir := range tc.dirs {
+				require.Nil(t, os.MkdirAll(filepath.Join(baseTmpDir, dir), os.ModePerm))
+			}
+			for _, file := range tc.files {
+				file = filepath.Join(baseTmpDir, file)
+				require.Nil(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+				require.Nil(t, ioutil.WriteFile(file, []byte("test"), os.FileMode(0644)))
+			}
+
+			err = EnsureCleanStage(baseTmpDir)
+			require.Equal(t, tc.rErr, err != nil)
+
+			// A dry run keeps the stage as it is
+			removed, err := CleanStageWithOptions(baseTmpDir, &StageOptions{DryRun: true})
+			require.Nil(t, err)
+			require.Len(t, removed, len(stagePaths))
+			err = EnsureCleanStage(baseTmpDir)
+			require.Equal(t, tc.rErr, err != nil)
+
+			require.Nil(t, CleanStage(baseTmpDir))
+			require.Nil(t, EnsureCleanStage(baseTmpDir))
+		})
+	}
+}
+
+func TestListStagedArtifacts(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	// The stage does not exist yet
+	res, err := ListStagedArtifacts(baseTmpDir)
+	require.Nil(t, err)
+	require.Empty(t, res)
+
+	files := map[string]string{
+		filepath.Join(ReleaseTarsPath, kubernetesTar):              "test",
+		filepath.Join(ReleaseTarsPath, kubernetesTar+".sha256"):    "checksum",
+		filepath.Join(ReleaseTarsPath, "kube-apiserver.tar"):       "image",
+		filepath.Join(GCIPath, "configure.sh"):                     "script",
+		filepath.Join(WindowsLocalPath, "k8s-node-setup.psm1"):     "script",
+		filepath.Join(GCEPath, "manifests", "kube-proxy.manifest"): "yaml",
+	}
+	for name, content := range files {
+		file := filepath.Join(baseTmpDir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		require.Nil(t, ioutil.WriteFile(file, []byte(content), os.FileMode(0644)))
+	}
+
+	res, err = ListStagedArtifacts(baseTmpDir)
+	require.Nil(t, err)
+	require.Equal(t, []StagedArtifact{
+		{Path: filepath.Join(GCEPath, "gci", "configure.sh"), Size: 6, Type: ArtifactTypeScript},
+		{Path: filepath.Join(GCEPath, "manifests", "kube-proxy.manifest"), Size: 4, Type: ArtifactTypeOther},
+		{Path: filepath.Join(GCEPath, "windows", "k8s-node-setup.psm1"), Size: 6, Type: ArtifactTypeScript},
+		{Path: filepath.Join(ReleaseTarsPath, "kube-apiserver.tar"), Size: 5, Type: ArtifactTypeImage},
+		{Path: filepath.Join(ReleaseTarsPath, kubernetesTar), Size: 4, Type: ArtifactTypeTarball},
+	}, res)
+}
+
+func TestStageWindowsArtifacts(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	res, err := ListWindowsArtifacts(baseTmpDir)
+	require.Nil(t, err)
+	require.Empty(t, res)
+
+	writeScript := func(script string) {
+		file := filepath.Join(baseTmpDir, script)
+		require.Nil(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		require.Nil(t, ioutil.WriteFile(file, []byte(script), os.FileMode(0644)))
+	}
+	common := filepath.Join(WindowsLocalPath, "common.psm1")
+	for _, file := range WindowsStageFiles {
+		if file.SrcPath != common {
+			writeScript(file.SrcPath)
+		}
+	}
+
+	// common.psm1 is missing
+	err = StageWindowsArtifacts(baseTmpDir)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "common.psm1")
+	res, err = ListWindowsArtifacts(baseTmpDir)
+	require.Nil(t, err)
+	require.Empty(t, res)
+
+	writeScript(common)
+	staged, err := StageWindowsArtifactsWithOptions(baseTmpDir, &StageOptions{DryRun: true})
+	require.Nil(t, err)
+	require.Len(t, staged, len(WindowsStageFiles))
+	require.Contains(t, staged, filepath.Join(baseTmpDir, WindowsGCSPath, "common.psm1"))
+	res, err = ListWindowsArtifacts(baseTmpDir)
+	require.Nil(t, err)
+	require.Empty(t, res)
+
+	require.Nil(t, StageWindowsArtifacts(baseTmpDir))
+
+	// All scripts are staged flat into the WindowsGCSPath
+	res, err = ListWindowsArtifacts(baseTmpDir)
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		"common.psm1",
+		"configure.ps1",
+		"install-ssh.psm1",
+		"k8s-node-setup.psm1",
+		"user-profile.psm1",
+	}, res)
+
+	content, err := ioutil.ReadFile(
+		filepath.Join(baseTmpDir, WindowsGCSPath, "install-ssh.psm1"),
+	)
+	require.Nil(t, err)
+	require.Equal(t, filepath.Join(WindowsLocalPath, "testonly/install-ssh.psm1"), string(content))
+}
+
+func TestStageFiles(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmps(t, baseTmpDir)
+
+	src := filepath.Join(baseTmpDir, "src", "file.sh")
+	require.Nil(t, os.MkdirAll(filepath.Dir(src), os.ModePerm))
+	require.Nil(t, ioutil.WriteFile(src, []byte("test"), os.FileMode(0644)))
+
+	files := []StageFile{
+		{SrcPath: "src/file.sh", DstPath: "dst", Required: true},
+		{SrcPath: "src/optional.sh", DstPath: "dst"},
+	}
+	require.Nil(t, StageFiles(baseTmpDir, files))
+	content, err := ioutil.ReadFile(filepath.Join(baseTmpDir, "dst", "file.sh"))
+	require.Nil(t, err)
+	require.Equal(t, "test", string(content))
+	require.False(t, util.Exists(filepath.Join(baseTmpDir, "dst", "optional.sh")))
+
+	files[1].Required = true
+	require.NotNil(t, StageFiles(baseTmpDir, files))
+}
+
+func TestValidateGCEScripts(t *testing.T) {
+	cases := map[string]struct {
+		files    map[string]string
+		problems []string
+		optional []string
+	}{
+		"Complete": {
+			files: map[string]string{
+				filepath.Join(GCEPath, "configure-vm.sh"): "test",
+				filepath.Join(GCIPath, "configure.sh"):    "test",
+				filepath.Join(GCIPath, "master.yaml"):     "test",
+				filepath.Join(GCIPath, "node.yaml"):       "test",
+				filepath.Join(GCIPath, "shutdown.sh"):     "test",
+			},
+		},
+		"OptionalMissing": {
+			files: map[string]string{
+				filepath.Join(GCIPath, "configure.sh"): "test",
+				filepath.Join(GCIPath, "master.yaml"):  "test",
+				filepath.Join(GCIPath, "node.yaml"):    "test",
+			},
+		},
+		"Incomplete": {
+			files: map[string]string{
+				filepath.Join(GCEPath, "configure-vm.sh"): "test",
+				filepath.Join(GCIPath, "configure.sh"):    "",
+				filepath.Join(GCIPath, "node.yaml"):       "test",
+				filepath.Join(GCIPath, "shutdown.sh"):     "",
+			},
+			problems: []string{
+				"configure.sh is empty",
+				"master.yaml is missing",
+				"shutdown.sh is empty",
+			},
+		},
+		"NotStaged": {
+			problems: []string{
+				"configure.sh is missing",
+				"master.yaml is missing",
+				"node.yaml is missing",
+			},
+			optional: []string{"configure-vm.sh", "shutdown.sh"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			baseTmpDir, err := ioutil.TempDir("", "")
+			require.Nil(t, err)
+			defer cleanupTmps(t, baseTmpDir)
+
+			for name, content := range tc.files {
+				file := filepath.Join(baseTmpDir, name)
+				require.Nil(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+				require.Nil(t, ioutil.WriteFile(file, []byte(content), os.FileMode(0644)))
+			}
+
+			err = ValidateGCEScripts(baseTmpDir)
+			require.Equal(t, len(tc.problems) > 0, err != nil)
+			for _, problem := range tc.problems {
+				require.Contains(t, err.Error(), problem)
+			}
+			for _, script := range tc.optional {
+				require.NotContains(t, err.Error(), script)
+			}
+		})
+	}
+}
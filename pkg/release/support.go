@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/release/pkg/util"
+)
+
+const (
+	// supportMatrixCacheTTL is the duration a fetched support matrix is
+	// reused.
+	supportMatrixCacheTTL = time.Hour
+
+	// supportMatrixFailureTTL is the duration the built-in support matrix is
+	// used after a failed fetch, before the published one is tried again.
+	supportMatrixFailureTTL = 5 * time.Minute
+)
+
+var (
+	// SupportMatrixURL is the location of the support matrix. It is a new
+	// artifact, which has to be published next to the release markers, like
+	// https://dl.k8s.io/release/support-matrix.json, and updated together
+	// with the stable marker of every new minor release. Its format is the
+	// JSON encoding of a SupportMatrix.
+	SupportMatrixURL = ReleaseDownloadURLBase + "/support-matrix.json"
+
+	// fallbackSupportMatrix is used if the published support matrix cannot be
+	// retrieved. It only contains minors that are known to be supported and has
+	// to be updated from time to time.
+	fallbackSupportMatrix = &SupportMatrix{
+		Supported: []string{"1.21", "1.20", "1.19"},
+	}
+
+	supportMatrixCache = struct {
+		sync.Mutex
+		matrix  *SupportMatrix
+		fetched time.Time
+		failed  time.Time
+	}{}
+)
+
+// SupportMatrix lists the currently supported minor versions, for example
+// "1.21". It is published at the SupportMatrixURL as JSON object, which lists
+// the minors newest first, with or without a "v" prefix:
+//
+//	{"supported": ["1.21", "1.20", "1.19"]}
+type SupportMatrix struct {
+	Supported []string `json:"supported"`
+}
+
+// Supports returns true if the minor version `major`.`minor` is part of the
+// support matrix.
+func (m *SupportMatrix) Supports(major, minor uint64) bool {
+	want := fmt.Sprintf("%d.%d", major, minor)
+	for _, supported := range m.Supported {
+		if util.TrimTagPrefix(supported) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSupportMatrix returns the published support matrix from the
+// SupportMatrixURL. The result is cached for an hour. If the matrix cannot be
+// retrieved, a conservative built-in matrix is returned for the next five
+// minutes.
+func GetSupportMatrix() *SupportMatrix {
+	if matrix, ok := cachedSupportMatrix(); ok {
+		return matrix
+	}
+
+	// The lock is not held while fetching, so concurrent callers may fetch
+	// the matrix more than once.
+	matrix, err := fetchSupportMatrix(SupportMatrixURL)
+
+	supportMatrixCache.Lock()
+	defer supportMatrixCache.Unlock()
+	if err != nil {
+		logrus.Warnf("Using built-in support matrix: %v", err)
+		supportMatrixCache.failed = time.Now()
+		return fallbackSupportMatrix
+	}
+	supportMatrixCache.matrix = matrix
+	supportMatrixCache.fetched = time.Now()
+	supportMatrixCache.failed = time.Time{}
+	return matrix
+}
+
+// cachedSupportMatrix returns the cached support matrix, or the built-in one
+// after a recent failed fetch, and false if the matrix has to be fetched.
+func cachedSupportMatrix() (*SupportMatrix, bool) {
+	supportMatrixCache.Lock()
+	defer supportMatrixCache.Unlock()
+
+	if time.Since(supportMatrixCache.failed) < supportMatrixFailureTTL {
+		return fallbackSupportMatrix, true
+	}
+	if supportMatrixCache.matrix != nil &&
+		time.Since(supportMatrixCache.fetched) < supportMatrixCacheTTL {
+		return supportMatrixCache.matrix, true
+	}
+	return nil, false
+}
+
+// IsVersionSupported returns true if the minor of `version` is still
+// supported according to GetSupportMatrix.
+func IsVersionSupported(version string) (bool, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing version %s", version)
+	}
+	return GetSupportMatrix().Supports(sem.Major, sem.Minor), nil
+}
+
+// fetchSupportMatrix retrieves and parses the support matrix at `url`.
+func fetchSupportMatrix(url string) (*SupportMatrix, error) {
+	resp, err := defaultHTTPClient.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving support matrix")
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, url); err != nil {
+		return nil, errors.Wrap(err, "retrieving support matrix")
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading support matrix %s", url)
+	}
+
+	matrix := &SupportMatrix{}
+	if err := json.Unmarshal(content, matrix); err != nil {
+		return nil, errors.Wrapf(err, "parsing support matrix %s", url)
+	}
+	if len(matrix.Supported) == 0 {
+		return nil, errors.Errorf("support matrix %s is empty", url)
+	}
+	return matrix, nil
+}
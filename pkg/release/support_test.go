@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newSupportMatrixServer returns a test server serving `content` as support
+// matrix, which the SupportMatrixURL is pointed to until the returned function
+// is called. The number of requests gets counted in `requests`.
+func newSupportMatrixServer(content string, status int, requests *int) func() {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			*requests++
+			w.WriteHeader(status)
+			fmt.Fprint(w, content)
+		},
+	))
+
+	url := SupportMatrixURL
+	SupportMatrixURL = server.URL
+	supportMatrixCache.matrix = nil
+	supportMatrixCache.failed = time.Time{}
+	return func() {
+		SupportMatrixURL = url
+		supportMatrixCache.matrix = nil
+		supportMatrixCache.failed = time.Time{}
+		server.Close()
+	}
+}
+
+func TestIsVersionSupported(t *testing.T) {
+	requests := 0
+	defer newSupportMatrixServer(
+		`{"supported": ["1.22", "1.21", "v1.20"]}`, http.StatusOK, &requests,
+	)()
+
+	type want struct {
+		r    bool
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Supported":       {version: "v1.22.1", want: want{r: true}},
+		"PrefixedMinor":   {version: "v1.20.10", want: want{r: true}},
+		"PreRelease":      {version: "v1.21.5-rc.0", want: want{r: true}},
+		"Unsupported":     {version: "v1.19.14"},
+		"NotYetSupported": {version: "v1.23.0-alpha.1"},
+		"Invalid":         {version: "wrong", want: want{rErr: true}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := IsVersionSupported(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+
+	// The matrix is fetched only once
+	require.Equal(t, 1, requests)
+}
+
+func TestGetSupportMatrixFallback(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content string
+		status  int
+	}{
+		"Unavailable": {status: http.StatusNotFound},
+		"Invalid":     {content: "wrong", status: http.StatusOK},
+		"Empty":       {content: `{"supported": []}`, status: http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			defer newSupportMatrixServer(tc.content, tc.status, &requests)()
+
+			require.Equal(t, fallbackSupportMatrix, GetSupportMatrix())
+
+			// Failed fetches are cached for a short time
+			require.Equal(t, fallbackSupportMatrix, GetSupportMatrix())
+			require.Equal(t, 1, requests)
+
+			supportMatrixCache.failed = time.Now().Add(-supportMatrixFailureTTL)
+			GetSupportMatrix()
+			require.Equal(t, 2, requests)
+		})
+	}
+}
@@ -0,0 +1,395 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+
+	"k8s.io/release/pkg/util"
+)
+
+var (
+	// imageTagRE matches valid container image tags.
+	imageTagRE = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+
+	// gitDescribeRE matches the output of `git describe --tags`, for example
+	// v1.21.0-rc.1-12-gabcdef0, where the distance and commit are missing for
+	// clean tags.
+	gitDescribeRE = regexp.MustCompile(
+		`^(v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z]+(\.[0-9A-Za-z]+)*)?)` +
+			`(-([0-9]+)-g([0-9a-f]+))?(-dirty)?$`,
+	)
+
+	// prereleaseOrder is the order in which the pre-releases of a Kubernetes
+	// version get cut.
+	prereleaseOrder = map[string]int{"alpha": 0, "beta": 1, "rc": 2}
+)
+
+// ImageTagForVersion returns the container image tag of the provided version.
+// Image tags do not allow the '+' of CI build versions, which is replaced by
+// an '_', for example: v1.19.0-beta.1.58+e19c4a2b1ec777 becomes
+// v1.19.0-beta.1.58_e19c4a2b1ec777.
+func ImageTagForVersion(version string) (string, error) {
+	valid, err := IsValidReleaseBuild(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "validating version %s", version)
+	}
+	if !valid {
+		return "", errors.Errorf("invalid release version: %s", version)
+	}
+
+	tag := strings.ReplaceAll(version, "+", "_")
+	if !imageTagRE.MatchString(tag) {
+		return "", errors.Errorf("version %s results in invalid image tag %s", version, tag)
+	}
+	return tag, nil
+}
+
+// ChangelogSlug returns the anchor of a version inside the release notes, for
+// example v1.21.0-rc.1 becomes v1-21-0-rc-1. CI builds have no changelog entry
+// and are rejected.
+func ChangelogSlug(version string) (string, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+	if len(sem.Build) > 0 {
+		return "", errors.Errorf("CI build %s has no changelog entry", version)
+	}
+
+	return util.AddTagPrefix(strings.ReplaceAll(sem.String(), ".", "-")), nil
+}
+
+// BranchForVersion returns the release branch a version belongs to, for
+// example v1.18.3 belongs to release-1.18.
+func BranchForVersion(version string) (string, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+	return fmt.Sprintf("release-%d.%d", sem.Major, sem.Minor), nil
+}
+
+// ValidateBranchCut verifies that a newly cut `branch` and its bumped
+// `version` align, which means that the version belongs to the branch and is
+// the first pre-release of the minor, e.g. release-1.22 and v1.22.0-alpha.0.
+func ValidateBranchCut(branch, version string) error {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return errors.Wrapf(err, "parsing version %s", version)
+	}
+
+	expectedBranch, err := BranchForVersion(version)
+	if err != nil {
+		return err
+	}
+	if branch != expectedBranch {
+		return errors.Errorf(
+			"branch %s does not match version %s, which belongs to %s",
+			branch, version, expectedBranch,
+		)
+	}
+
+	expectedVersion := fmt.Sprintf("v%d.%d.0-alpha.0", sem.Major, sem.Minor)
+	if util.AddTagPrefix(sem.String()) != expectedVersion {
+		return errors.Errorf(
+			"version %s is not the first pre-release of %s, expected %s",
+			version, branch, expectedVersion,
+		)
+	}
+	return nil
+}
+
+// DescribeVersion returns a stable one-line description of a version, for
+// example "v1.21.0-rc.1 (release candidate for 1.21)". CI builds additionally
+// mention their commit, like in "v1.21.0-rc.1.58+abc123 (release candidate
+// for 1.21, commit abc123)".
+func DescribeVersion(version string) (string, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+
+	kind := "official release"
+	if len(sem.Pre) > 0 {
+		switch sem.Pre[0].VersionStr {
+		case "alpha":
+			kind = "alpha release"
+		case "beta":
+			kind = "beta release"
+		case "rc":
+			kind = "release candidate"
+		default:
+			kind = "pre-release"
+		}
+	}
+
+	details := fmt.Sprintf("%s for %d.%d", kind, sem.Major, sem.Minor)
+	if len(sem.Build) > 0 {
+		details += ", commit " + strings.Join(sem.Build, ".")
+	}
+	return fmt.Sprintf("%s (%s)", util.AddTagPrefix(sem.String()), details), nil
+}
+
+// ValidatePatchIncrement verifies that the patch release `next` directly
+// follows the previous stable release `prev`, which means they share the
+// same major and minor version and the patch version got incremented by one.
+func ValidatePatchIncrement(prev, next string) error {
+	prevSem, err := util.TagStringToSemver(prev)
+	if err != nil {
+		return errors.Wrapf(err, "parsing previous version %s", prev)
+	}
+	nextSem, err := util.TagStringToSemver(next)
+	if err != nil {
+		return errors.Wrapf(err, "parsing next version %s", next)
+	}
+
+	for version, sem := range map[string]semver.Version{prev: prevSem, next: nextSem} {
+		if len(sem.Pre) > 0 || len(sem.Build) > 0 {
+			return errors.Errorf("%s is not an official release", version)
+		}
+	}
+
+	if prevSem.Major != nextSem.Major || prevSem.Minor != nextSem.Minor {
+		return errors.Errorf(
+			"version %s is not on the same minor release as %s", next, prev,
+		)
+	}
+
+	switch {
+	case nextSem.Patch <= prevSem.Patch:
+		return errors.Errorf("version %s does not move forward from %s", next, prev)
+	case nextSem.Patch > prevSem.Patch+1:
+		return errors.Errorf(
+			"version %s skips %d patch release(s) after %s",
+			next, nextSem.Patch-prevSem.Patch-1, prev,
+		)
+	}
+	return nil
+}
+
+// GitHubReleaseTag returns the canonical git tag of the GitHub release of
+// `version`, which always has a 'v' prefix, e.g. 1.18.3 becomes v1.18.3. CI
+// builds cannot be tagged and are rejected.
+func GitHubReleaseTag(version string) (string, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+	if len(sem.Build) > 0 {
+		return "", errors.Errorf("CI build %s cannot be tagged", version)
+	}
+	return util.SemverToTagString(sem), nil
+}
+
+// ParseGitDescribe converts the output of `git describe --tags` into a
+// Kubernetes build version like hack/lib/version.sh does. The distance to the
+// tag and the commit become the build metadata, for example
+// v1.21.0-rc.1-12-gabcdef0 becomes v1.21.0-rc.1.12+abcdef0 and
+// v1.21.0-12-gabcdef0 becomes v1.21.0-12+abcdef0. Clean tags are returned as
+// they are and a "-dirty" suffix is retained.
+func ParseGitDescribe(s string) (string, error) {
+	match := gitDescribeRE.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return "", errors.Errorf("unable to parse git describe output %q", s)
+	}
+	tag, preRelease, distance, commit, dirty := match[1], match[2], match[5], match[6], match[7]
+
+	version := util.AddTagPrefix(tag)
+	if distance != "" {
+		separator := "-"
+		if preRelease != "" {
+			separator = "."
+		}
+		version = fmt.Sprintf("%s%s%s+%s", version, separator, distance, commit)
+	}
+	version += dirty
+
+	if _, err := util.TagStringToSemver(version); err != nil {
+		return "", errors.Wrapf(err, "parsing resulting version %s", version)
+	}
+	return version, nil
+}
+
+// NormalizeVersion returns the canonical form of `version`, which has a 'v'
+// prefix and no surrounding whitespace, for example " 1.18.3\n" becomes
+// v1.18.3. Versions which are no valid semver are rejected with an error
+// wrapping ErrInvalidVersion. NormalizeKubeVersion returns the same version
+// without the prefix if SemVer is requested.
+func NormalizeVersion(version string) (string, error) {
+	sem, err := util.TagStringToSemver(strings.TrimSpace(version))
+	if err != nil {
+		return "", errors.Wrapf(ErrInvalidVersion, "parsing %q: %v", version, err)
+	}
+	return util.SemverToTagString(sem), nil
+}
+
+// CompareVersions compares the versions `a` and `b` by semver precedence. The
+// result is -1 if a < b, 0 if a == b and 1 if a > b.
+func CompareVersions(a, b string) (int, error) {
+	aSem, err := util.TagStringToSemver(a)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parsing version %s", a)
+	}
+	bSem, err := util.TagStringToSemver(b)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parsing version %s", b)
+	}
+	return aSem.Compare(bSem), nil
+}
+
+// IsNewerVersion returns true if `candidate` has a higher semver precedence
+// than `baseline`, for example to decide if a build advances a marker.
+// Pre-releases like v1.20.0-beta.1 are older than their final release.
+func IsNewerVersion(candidate, baseline string) (bool, error) {
+	res, err := CompareVersions(candidate, baseline)
+	if err != nil {
+		return false, err
+	}
+	return res > 0, nil
+}
+
+// IsPrereleaseVersion returns true if `version` has a pre-release segment,
+// like v1.21.0-rc.0 or the CI build v1.21.0-beta.1.58+e19c4a2b1ec777, and
+// false for official releases like v1.21.0.
+func IsPrereleaseVersion(version string) (bool, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing version %s", version)
+	}
+	return len(sem.Pre) > 0, nil
+}
+
+// PrereleaseType returns the kind of the pre-release `version`, which is
+// "alpha", "beta" or "rc", and an empty string for official releases. Other
+// pre-release labels are rejected.
+func PrereleaseType(version string) (string, error) {
+	sem, err := util.TagStringToSemver(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", version)
+	}
+	if len(sem.Pre) == 0 {
+		return "", nil
+	}
+
+	switch label := sem.Pre[0].VersionStr; label {
+	case "alpha", "beta", "rc":
+		return label, nil
+	default:
+		return "", errors.Errorf(
+			"unknown pre-release %q of version %s", sem.Pre[0].String(), version,
+		)
+	}
+}
+
+// NextReleaseVersion returns the version which follows `currentVersion` on the
+// release `branch` for `releaseType`, which is one of "alpha", "beta", "rc" or
+// "official". The `currentVersion` can be a CI build like
+// v1.20.3-rc.0.12+f1a2b3c4d5e6f7, whose build metadata is ignored. For
+// example, v1.20.3-rc.0 is followed by v1.20.3-rc.1 and the official release
+// v1.20.3, whereas v1.20.2 is followed by v1.20.3-rc.0 and v1.20.3. Moving
+// back to an earlier kind of pre-release, like from rc to beta, is rejected.
+func NextReleaseVersion(branch, currentVersion, releaseType string) (string, error) {
+	if !releaseBranchRE.MatchString(branch) {
+		return "", errors.Errorf("invalid release branch %q, expected release-X.Y", branch)
+	}
+	order, ok := prereleaseOrder[releaseType]
+	if !ok && releaseType != "official" {
+		return "", errors.Errorf(
+			"unknown release type %q, expected alpha, beta, rc or official", releaseType,
+		)
+	}
+
+	expectedBranch, err := BranchForVersion(currentVersion)
+	if err != nil {
+		return "", err
+	}
+	if branch != expectedBranch {
+		return "", errors.Errorf(
+			"version %s belongs to %s instead of %s", currentVersion, expectedBranch, branch,
+		)
+	}
+	currentType, err := PrereleaseType(currentVersion)
+	if err != nil {
+		return "", err
+	}
+
+	sem, err := util.TagStringToSemver(currentVersion)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing version %s", currentVersion)
+	}
+	next := semver.Version{Major: sem.Major, Minor: sem.Minor, Patch: sem.Patch}
+
+	if releaseType == "official" {
+		if currentType == "" {
+			next.Patch++
+		}
+		return util.SemverToTagString(next), nil
+	}
+
+	var number uint64
+	switch {
+	case currentType == "":
+		// Only release candidates are cut for patch releases
+		if releaseType != "rc" {
+			return "", errors.Errorf(
+				"no %s release follows the official release %s", releaseType, currentVersion,
+			)
+		}
+		next.Patch++
+	case currentType == releaseType:
+		if len(sem.Pre) < 2 || !sem.Pre[1].IsNum {
+			return "", errors.Errorf("pre-release of version %s is not numbered", currentVersion)
+		}
+		number = sem.Pre[1].VersionNum + 1
+	case prereleaseOrder[currentType] > order:
+		return "", errors.Errorf(
+			"no %s release follows the %s release %s", releaseType, currentType, currentVersion,
+		)
+	}
+
+	next.Pre = []semver.PRVersion{
+		{VersionStr: releaseType},
+		{VersionNum: number, IsNum: true},
+	}
+	return util.SemverToTagString(next), nil
+}
+
+// SortVersions returns a copy of `versions` sorted ascending by semver
+// precedence. The versions keep their original formatting.
+func SortVersions(versions []string) ([]string, error) {
+	sems := make(map[string]semver.Version, len(versions))
+	for _, version := range versions {
+		sem, err := util.TagStringToSemver(version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing version %s", version)
+		}
+		sems[version] = sem
+	}
+
+	sorted := append([]string{}, versions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sems[sorted[i]].LT(sems[sorted[j]])
+	})
+	return sorted, nil
+}
@@ -0,0 +1,687 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageTagForVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Release": {
+			version: "v1.18.3",
+			want:    want{r: "v1.18.3"},
+		},
+		"PreRelease": {
+			version: "v1.19.0-rc.1",
+			want:    want{r: "v1.19.0-rc.1"},
+		},
+		"CIBuild": {
+			version: "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			want:    want{r: "v1.19.0-beta.1.58_e19c4a2b1ec777"},
+		},
+		"InvalidVersion": {
+			version: "1.18.3",
+			want:    want{rErr: true},
+		},
+		"InvalidTag": {
+			version: "v1.18.3 latest",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ImageTagForVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestChangelogSlug(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Release": {
+			version: "v1.21.0",
+			want:    want{r: "v1-21-0"},
+		},
+		"ReleaseWithoutPrefix": {
+			version: "1.21.0",
+			want:    want{r: "v1-21-0"},
+		},
+		"PreRelease": {
+			version: "v1.21.0-rc.1",
+			want:    want{r: "v1-21-0-rc-1"},
+		},
+		"CIBuild": {
+			version: "v1.21.0-beta.1.58+e19c4a2b1ec777",
+			want:    want{rErr: true},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ChangelogSlug(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestBranchForVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Official": {
+			version: "v1.18.3",
+			want:    want{r: "release-1.18"},
+		},
+		"PreRelease": {
+			version: "v1.22.0-alpha.0",
+			want:    want{r: "release-1.22"},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := BranchForVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestValidateBranchCut(t *testing.T) {
+	cases := map[string]struct {
+		branch  string
+		version string
+		rErr    bool
+	}{
+		"Aligned": {
+			branch:  "release-1.22",
+			version: "v1.22.0-alpha.0",
+		},
+		"WrongBranch": {
+			branch:  "release-1.21",
+			version: "v1.22.0-alpha.0",
+			rErr:    true,
+		},
+		"NotFirstPreRelease": {
+			branch:  "release-1.22",
+			version: "v1.22.0-alpha.1",
+			rErr:    true,
+		},
+		"Official": {
+			branch:  "release-1.22",
+			version: "v1.22.0",
+			rErr:    true,
+		},
+		"CIBuild": {
+			branch:  "release-1.22",
+			version: "v1.22.0-alpha.0.1+e19c4a2b1ec777",
+			rErr:    true,
+		},
+		"Invalid": {
+			branch:  "release-1.22",
+			version: "wrong",
+			rErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateBranchCut(tc.branch, tc.version)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
+func TestDescribeVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Official": {
+			version: "v1.21.0",
+			want:    want{r: "v1.21.0 (official release for 1.21)"},
+		},
+		"Alpha": {
+			version: "v1.21.0-alpha.3",
+			want:    want{r: "v1.21.0-alpha.3 (alpha release for 1.21)"},
+		},
+		"Beta": {
+			version: "v1.21.0-beta.0",
+			want:    want{r: "v1.21.0-beta.0 (beta release for 1.21)"},
+		},
+		"ReleaseCandidate": {
+			version: "v1.21.0-rc.1",
+			want:    want{r: "v1.21.0-rc.1 (release candidate for 1.21)"},
+		},
+		"CIBuild": {
+			version: "v1.21.0-rc.1.58+abc123",
+			want: want{
+				r: "v1.21.0-rc.1.58+abc123 (release candidate for 1.21, commit abc123)",
+			},
+		},
+		"WithoutPrefix": {
+			version: "1.18.3",
+			want:    want{r: "v1.18.3 (official release for 1.18)"},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := DescribeVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestValidatePatchIncrement(t *testing.T) {
+	cases := map[string]struct {
+		prev string
+		next string
+		rErr bool
+	}{
+		"Sequential": {
+			prev: "v1.18.3",
+			next: "v1.18.4",
+		},
+		"FirstPatch": {
+			prev: "v1.18.0",
+			next: "v1.18.1",
+		},
+		"Skipped": {
+			prev: "v1.18.3",
+			next: "v1.18.5",
+			rErr: true,
+		},
+		"Same": {
+			prev: "v1.18.3",
+			next: "v1.18.3",
+			rErr: true,
+		},
+		"Regressed": {
+			prev: "v1.18.3",
+			next: "v1.18.2",
+			rErr: true,
+		},
+		"CrossMinor": {
+			prev: "v1.18.3",
+			next: "v1.19.0",
+			rErr: true,
+		},
+		"CrossMajor": {
+			prev: "v1.18.3",
+			next: "v2.18.4",
+			rErr: true,
+		},
+		"PreRelease": {
+			prev: "v1.18.3",
+			next: "v1.18.4-rc.0",
+			rErr: true,
+		},
+		"Invalid": {
+			prev: "v1.18.3",
+			next: "wrong",
+			rErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidatePatchIncrement(tc.prev, tc.next)
+			require.Equal(t, tc.rErr, err != nil)
+		})
+	}
+}
+
+func TestGitHubReleaseTag(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Official": {
+			version: "v1.18.3",
+			want:    want{r: "v1.18.3"},
+		},
+		"WithoutPrefix": {
+			version: "1.18.3",
+			want:    want{r: "v1.18.3"},
+		},
+		"PreRelease": {
+			version: "v1.19.0-rc.1",
+			want:    want{r: "v1.19.0-rc.1"},
+		},
+		"CIBuild": {
+			version: "v1.19.0-beta.1.58+e19c4a2b1ec777",
+			want:    want{rErr: true},
+		},
+		"Invalid": {
+			version: "v1.19",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := GitHubReleaseTag(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestParseGitDescribe(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		describe string
+		want     want
+	}{
+		"CleanTag": {
+			describe: "v1.21.0",
+			want:     want{r: "v1.21.0"},
+		},
+		"CleanPreReleaseTag": {
+			describe: "v1.21.0-rc.1\n",
+			want:     want{r: "v1.21.0-rc.1"},
+		},
+		"DistanceToTag": {
+			describe: "v1.21.0-12-gabcdef0",
+			want:     want{r: "v1.21.0-12+abcdef0"},
+		},
+		"DistanceToPreReleaseTag": {
+			describe: "v1.21.0-alpha.0-12-ge19c4a2b1ec777",
+			want:     want{r: "v1.21.0-alpha.0.12+e19c4a2b1ec777"},
+		},
+		"Dirty": {
+			describe: "v1.21.0-beta.1-3-gabcdef0-dirty",
+			want:     want{r: "v1.21.0-beta.1.3+abcdef0-dirty"},
+		},
+		"WithoutPrefix": {
+			describe: "1.21.0-12-gabcdef0",
+			want:     want{r: "v1.21.0-12+abcdef0"},
+		},
+		"OnlyCommit": {
+			describe: "abcdef0",
+			want:     want{rErr: true},
+		},
+		"Invalid": {
+			describe: "v1.21-12-gabcdef0",
+			want:     want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParseGitDescribe(tc.describe)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Canonical": {
+			version: "v1.18.3",
+			want:    want{r: "v1.18.3"},
+		},
+		"WithoutPrefix": {
+			version: "1.18.3",
+			want:    want{r: "v1.18.3"},
+		},
+		"Whitespace": {
+			version: " v1.19.0-beta.1.58+e19c4a2b1ec777\n",
+			want:    want{r: "v1.19.0-beta.1.58+e19c4a2b1ec777"},
+		},
+		"Invalid": {
+			version: "v1.18",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := NormalizeVersion(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.rErr, errors.Is(err, ErrInvalidVersion))
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	type want struct {
+		r    int
+		rErr bool
+	}
+	cases := map[string]struct {
+		a, b string
+		want want
+	}{
+		"Less": {
+			a: "v1.18.2", b: "v1.18.10",
+			want: want{r: -1},
+		},
+		"Equal": {
+			a: "v1.18.3", b: "1.18.3",
+			want: want{r: 0},
+		},
+		"Greater": {
+			a: "v1.19.0", b: "v1.19.0-rc.1",
+			want: want{r: 1},
+		},
+		"PreRelease": {
+			a: "v1.20.0-beta.1", b: "v1.20.0",
+			want: want{r: -1},
+		},
+		"Invalid": {
+			a: "v1.18.3", b: "wrong",
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := CompareVersions(tc.a, tc.b)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestPrereleaseType(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		version string
+		want    want
+	}{
+		"Official": {
+			version: "v1.21.0",
+		},
+		"RC": {
+			version: "v1.21.0-rc.0",
+			want:    want{r: "rc"},
+		},
+		"Beta": {
+			version: "v1.21.0-beta.2",
+			want:    want{r: "beta"},
+		},
+		"Alpha": {
+			version: "1.21.0-alpha.1",
+			want:    want{r: "alpha"},
+		},
+		"CIBuild": {
+			version: "v1.21.0-beta.1.58+e19c4a2b1ec777",
+			want:    want{r: "beta"},
+		},
+		"OfficialWithBuild": {
+			version: "v1.21.0+e19c4a2b1ec777",
+		},
+		"UnknownLabel": {
+			version: "v1.21.0-preview.1",
+			want:    want{rErr: true},
+		},
+		"Invalid": {
+			version: "wrong",
+			want:    want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := PrereleaseType(tc.version)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestIsPrereleaseVersion(t *testing.T) {
+	for version, want := range map[string]bool{
+		"v1.21.0":                          false,
+		"v1.21.0-rc.0":                     true,
+		"v1.21.0-alpha.1":                  true,
+		"v1.21.0-beta.1.58+e19c4a2b1ec777": true,
+	} {
+		res, err := IsPrereleaseVersion(version)
+		require.Nil(t, err)
+		require.Equal(t, want, res, version)
+	}
+
+	_, err := IsPrereleaseVersion("wrong")
+	require.NotNil(t, err)
+}
+
+func TestIsNewerVersion(t *testing.T) {
+	type want struct {
+		r    bool
+		rErr bool
+	}
+	cases := map[string]struct {
+		candidate, baseline string
+		want                want
+	}{
+		"Newer": {
+			candidate: "v1.18.4", baseline: "v1.18.3",
+			want: want{r: true},
+		},
+		"Same": {
+			candidate: "1.18.3", baseline: "v1.18.3",
+		},
+		"Older": {
+			candidate: "v1.18.2", baseline: "v1.18.3",
+		},
+		"PreRelease": {
+			candidate: "v1.20.0-beta.1", baseline: "v1.20.0",
+		},
+		"FinalRelease": {
+			candidate: "v1.20.0", baseline: "v1.20.0-rc.1",
+			want: want{r: true},
+		},
+		"CIBuild": {
+			candidate: "v1.20.0-beta.1.58+e19c4a2b1ec777", baseline: "v1.20.0-beta.1",
+			want: want{r: true},
+		},
+		"Invalid": {
+			candidate: "wrong", baseline: "v1.18.3",
+			want: want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := IsNewerVersion(tc.candidate, tc.baseline)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestNextReleaseVersion(t *testing.T) {
+	type want struct {
+		r    string
+		rErr bool
+	}
+	cases := map[string]struct {
+		branch      string
+		version     string
+		releaseType string
+		want        want
+	}{
+		"NextRC": {
+			branch:      "release-1.20",
+			version:     "v1.20.3-rc.0",
+			releaseType: "rc",
+			want:        want{r: "v1.20.3-rc.1"},
+		},
+		"NextRCFromCIBuild": {
+			branch:      "release-1.20",
+			version:     "v1.20.3-rc.0.12+f1a2b3c4d5e6f7",
+			releaseType: "rc",
+			want:        want{r: "v1.20.3-rc.1"},
+		},
+		"OfficialFromRC": {
+			branch:      "release-1.20",
+			version:     "v1.20.3-rc.1.5+f1a2b3c4d5e6f7",
+			releaseType: "official",
+			want:        want{r: "v1.20.3"},
+		},
+		"NextPatch": {
+			branch:      "release-1.20",
+			version:     "v1.20.2",
+			releaseType: "official",
+			want:        want{r: "v1.20.3"},
+		},
+		"FirstRCOfPatch": {
+			branch:      "release-1.20",
+			version:     "1.20.2",
+			releaseType: "rc",
+			want:        want{r: "v1.20.3-rc.0"},
+		},
+		"NextBeta": {
+			branch:      "release-1.21",
+			version:     "v1.21.0-beta.1.58+e19c4a2b1ec777",
+			releaseType: "beta",
+			want:        want{r: "v1.21.0-beta.2"},
+		},
+		"BetaFromAlpha": {
+			branch:      "release-1.21",
+			version:     "v1.21.0-alpha.3",
+			releaseType: "beta",
+			want:        want{r: "v1.21.0-beta.0"},
+		},
+		"RCFromBeta": {
+			branch:      "release-1.21",
+			version:     "v1.21.0-beta.2",
+			releaseType: "rc",
+			want:        want{r: "v1.21.0-rc.0"},
+		},
+		"AlphaFromBeta": {
+			branch:      "release-1.21",
+			version:     "v1.21.0-beta.2",
+			releaseType: "alpha",
+			want:        want{rErr: true},
+		},
+		"BetaFromOfficial": {
+			branch:      "release-1.20",
+			version:     "v1.20.2",
+			releaseType: "beta",
+			want:        want{rErr: true},
+		},
+		"BranchMismatch": {
+			branch:      "release-1.19",
+			version:     "v1.20.2",
+			releaseType: "official",
+			want:        want{rErr: true},
+		},
+		"InvalidBranch": {
+			branch:      "master",
+			version:     "v1.20.2",
+			releaseType: "official",
+			want:        want{rErr: true},
+		},
+		"UnknownReleaseType": {
+			branch:      "release-1.20",
+			version:     "v1.20.2",
+			releaseType: "patch",
+			want:        want{rErr: true},
+		},
+		"InvalidVersion": {
+			branch:      "release-1.20",
+			version:     "wrong",
+			releaseType: "rc",
+			want:        want{rErr: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := NextReleaseVersion(tc.branch, tc.version, tc.releaseType)
+			require.Equal(t, tc.want.rErr, err != nil)
+			require.Equal(t, tc.want.r, res)
+		})
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	versions := []string{"v1.18.10", "v1.19.0-rc.1", "v1.18.2", "v1.19.0"}
+	res, err := SortVersions(versions)
+	require.Nil(t, err)
+	require.Equal(t, []string{"v1.18.2", "v1.18.10", "v1.19.0-rc.1", "v1.19.0"}, res)
+	require.Equal(t, "v1.18.10", versions[0])
+
+	_, err = SortVersions([]string{"v1.18.3", "wrong"})
+	require.NotNil(t, err)
+}
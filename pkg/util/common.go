@@ -20,7 +20,11 @@ import (
 	"archive/tar"
 	"bufio"
 	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -417,3 +421,55 @@ func Exists(path string) bool {
 
 	return true
 }
+
+// SHA256ForFile returns the hex-encoded sha256 hash of the file at `path`. The
+// file is streamed, which keeps the memory footprint low for large artifacts.
+func SHA256ForFile(path string) (string, error) {
+	return hashFile(path, sha256.New())
+}
+
+// SHA512ForFile returns the hex-encoded sha512 hash of the file at `path`.
+func SHA512ForFile(path string) (string, error) {
+	return hashFile(path, sha512.New())
+}
+
+func hashFile(path string, hasher hash.Hash) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "opening file %s", path)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", errors.Wrapf(err, "hashing file %s", path)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// ProgressFunc is called while downloading with the number of bytes read so
+// far and the total size, which is -1 if unknown, for example by a writer of
+// NewProgressWriter.
+type ProgressFunc func(read, total int64)
+
+// NewProgressWriter returns a writer passing all data to `w`, which reports
+// the amount of written bytes on top of `offset` together with the `total`
+// size to `progress`. A non-zero offset reports the progress of resumed
+// downloads, which already have `offset` bytes available.
+func NewProgressWriter(w io.Writer, offset, total int64, progress ProgressFunc) io.Writer {
+	return &progressWriter{w: w, read: offset, total: total, progress: progress}
+}
+
+// progressWriter reports the amount of written bytes to a ProgressFunc.
+type progressWriter struct {
+	w        io.Writer
+	read     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.read += int64(n)
+	p.progress(p.read, p.total)
+	return n, err
+}
@@ -583,3 +583,56 @@ func TestTrimTagPrefix(t *testing.T) {
 	require.Equal(t, "0.0.0", TrimTagPrefix("0.0.0"))
 	require.Equal(t, "1.0.0", TrimTagPrefix("1.0.0"))
 }
+
+func TestSHA256ForFile(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmp(t, baseTmpDir)
+
+	testFile := filepath.Join(baseTmpDir, "test.txt")
+	require.Nil(t, ioutil.WriteFile(testFile, []byte("test"), os.FileMode(0644)))
+
+	// Success
+	sha, err := SHA256ForFile(testFile)
+	require.Nil(t, err)
+	require.Equal(t, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", sha)
+
+	// File does not exist
+	sha, err = SHA256ForFile(filepath.Join(baseTmpDir, "notexisting"))
+	require.NotNil(t, err)
+	require.Empty(t, sha)
+}
+
+func TestSHA512ForFile(t *testing.T) {
+	baseTmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer cleanupTmp(t, baseTmpDir)
+
+	testFile := filepath.Join(baseTmpDir, "test.txt")
+	require.Nil(t, ioutil.WriteFile(testFile, []byte("test"), os.FileMode(0644)))
+
+	// Success
+	sha, err := SHA512ForFile(testFile)
+	require.Nil(t, err)
+	require.Equal(t, "ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff", sha)
+
+	// File does not exist
+	sha, err = SHA512ForFile(filepath.Join(baseTmpDir, "notexisting"))
+	require.NotNil(t, err)
+	require.Empty(t, sha)
+}
+
+func TestNewProgressWriter(t *testing.T) {
+	var buf bytes.Buffer
+	reported := [][2]int64{}
+	w := NewProgressWriter(&buf, 10, 16, func(read, total int64) {
+		reported = append(reported, [2]int64{read, total})
+	})
+
+	_, err := w.Write([]byte("abc"))
+	require.Nil(t, err)
+	_, err = w.Write([]byte("def"))
+	require.Nil(t, err)
+	require.Equal(t, "abcdef", buf.String())
+	require.Equal(t, [][2]int64{{13, 16}, {16, 16}}, reported)
+}